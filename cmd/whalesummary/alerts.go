@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertsConfig sends the net flow of a symbol right away once it reaches a tier instead of waiting for the summary
+type AlertsConfig struct {
+	Tiers       []AlertTier `json:"tiers"`        //severities from lowest to highest threshold. none disables alerts
+	Sections    []string    `json:"sections"`     //sections like supply or transfers to alert on. defaults to transfers
+	Trigger     float64     `json:"trigger"`      //share a flow must exceed a tier by to reach it like 0.1. defaults to 0.1
+	Reset       float64     `json:"reset"`        //share below a tier a flow must drop to before the tier alerts again like 0.2. defaults to 0.2
+	Cooldown    string      `json:"cooldown"`     //least time between alerts of a symbol like 2h unless it reaches a higher tier. defaults to 1h
+	RecipientID string      `json:"recipient_id"` //defaults to telegram.recipient_id
+	State       string      `json:"state"`        //file the reached tiers are kept in between runs. defaults to only while running
+}
+
+// AlertTier is a severity of alerts like high or critical
+type AlertTier struct {
+	Name      string  `json:"name"`
+	Threshold float64 `json:"threshold"` //usd of net flow in one window
+}
+
+func (config AlertsConfig) enabled() bool {
+	return len(config.Tiers) > 0
+}
+
+func (config AlertsConfig) sections() []string {
+	if len(config.Sections) > 0 {
+		return config.Sections
+	}
+	return []string{transferSection.name}
+}
+
+func (config AlertsConfig) trigger() float64 {
+	if config.Trigger > 0 {
+		return config.Trigger
+	}
+	return 0.1
+}
+
+func (config AlertsConfig) reset() float64 {
+	if config.Reset > 0 {
+		return config.Reset
+	}
+	return 0.2
+}
+
+func (config AlertsConfig) cooldown() time.Duration {
+	cooldown, err := time.ParseDuration(config.Cooldown)
+	if err != nil || cooldown <= 0 {
+		return time.Hour
+	}
+	return cooldown
+}
+
+// validate explains what is wrong with the alerts config
+func (config AlertsConfig) validate() error {
+	for i, tier := range config.Tiers {
+		if tier.Name == "" || tier.Threshold <= 0 {
+			return fmt.Errorf("tier %d needs a name and a positive threshold", i+1)
+		}
+		if i > 0 && tier.Threshold <= config.Tiers[i-1].Threshold {
+			return fmt.Errorf("tier %s must have a higher threshold than %s", tier.Name, config.Tiers[i-1].Name)
+		}
+	}
+	if config.Trigger < 0 || config.Reset < 0 || config.Reset >= 1 {
+		return fmt.Errorf("trigger must be positive and reset between 0 and 1")
+	}
+	if config.Cooldown != "" {
+		cooldown, err := time.ParseDuration(config.Cooldown)
+		if err != nil || cooldown <= 0 {
+			return fmt.Errorf("invalid cooldown %s", config.Cooldown)
+		}
+	}
+	return nil
+}
+
+// alertLevel is how far the flow of a symbol got
+type alertLevel struct {
+	Tier     int   `json:"tier"`     //tiers reached and not reset yet. 0 for none
+	Increase bool  `json:"increase"` //direction of the flow that reached them
+	Alerted  int64 `json:"alerted"`  //unix time of the last alert
+	Peak     int   `json:"peak"`     //highest tier alerted since then
+}
+
+// alertState is the level of every section and symbol like transfers:btc
+// so a flow hovering around a threshold alerts once instead of every window
+type alertState struct {
+	mu     sync.Mutex
+	Levels map[string]alertLevel `json:"levels"`
+}
+
+func newAlertState() *alertState {
+	return &alertState{Levels: map[string]alertLevel{}}
+}
+
+// tierAlert is a flow that reached a tier
+type tierAlert struct {
+	section string
+	symbol  string
+	value   float64
+	tier    AlertTier
+}
+
+// reached is how many tiers the flow would reach and how many it still holds
+// a flow has to exceed a tier by trigger to reach it and drop below it by reset to lose it
+func (config AlertsConfig) reached(value float64, held int) (int, int) {
+	reached := 0
+	for i, tier := range config.Tiers {
+		if value >= tier.Threshold*(1+config.trigger()) {
+			reached = i + 1
+		}
+	}
+	for held > 0 && value < config.Tiers[held-1].Threshold*(1-config.reset()) {
+		held--
+	}
+	return reached, held
+}
+
+// check moves the levels to the flows of a window and returns the flows that should alert
+// symbols missing from the window have no flow so they drop back too
+func (s *alertState) check(config AlertsConfig, sections map[string]map[string]float64, now time.Time) []tierAlert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := map[string]float64{}
+	for key := range s.Levels {
+		values[key] = 0
+	}
+	for _, section := range config.sections() {
+		for symbol, value := range sections[section] {
+			values[section+":"+symbol] = value
+		}
+	}
+	var alerts []tierAlert
+	for key, value := range values {
+		level := s.Levels[key]
+		if level.Tier > 0 && (value > 0) != level.Increase {
+			// the other direction is another flow
+			level.Tier = 0
+		}
+		reached, held := config.reached(math.Abs(value), level.Tier)
+		level.Tier = held
+		if reached > level.Tier {
+			level.Tier, level.Increase = reached, value > 0
+			if now.Sub(time.Unix(level.Alerted, 0)) >= config.cooldown() || reached > level.Peak {
+				level.Alerted, level.Peak = now.Unix(), reached
+				parts := strings.SplitN(key, ":", 2)
+				alerts = append(alerts, tierAlert{section: parts[0], symbol: parts[1], value: value, tier: config.Tiers[reached-1]})
+			}
+		}
+		if level.Tier == 0 && now.Sub(time.Unix(level.Alerted, 0)) >= config.cooldown() {
+			delete(s.Levels, key)
+			continue
+		}
+		s.Levels[key] = level
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if math.Abs(alerts[i].value) != math.Abs(alerts[j].value) {
+			return math.Abs(alerts[i].value) > math.Abs(alerts[j].value)
+		}
+		return alerts[i].symbol < alerts[j].symbol
+	})
+	return alerts
+}
+
+// load replaces the levels with the file. a missing file keeps them
+func (s *alertState) load(path string) error {
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := newAlertState()
+	err = json.Unmarshal(body, saved)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Levels = saved.Levels
+	if s.Levels == nil {
+		s.Levels = map[string]alertLevel{}
+	}
+	return nil
+}
+
+// save replaces the file atomically like writeSeedState
+func (s *alertState) save(path string) error {
+	s.mu.Lock()
+	body, err := json.Marshal(s)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, body, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// alertTiers sends the flows of the window that reached a tier
+func alertTiers(config Config, sections map[string]map[string]float64, now time.Time) {
+	if !config.Alerts.enabled() || config.alerts == nil {
+		return
+	}
+	path := config.Alerts.State
+	if config.readOnly {
+		// a shadow keeps its levels while running instead of sharing the file production saves
+		path = ""
+	}
+	if path != "" {
+		err := config.alerts.load(path)
+		if err != nil {
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("alerts state %s: %s", path, err))
+		}
+	}
+	alerts := config.alerts.check(config.Alerts, sections, now)
+	if path != "" && !config.preview {
+		err := config.alerts.save(path)
+		if err != nil {
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("alerts state %s: %s", path, err))
+		}
+	}
+	if len(alerts) < 1 {
+		return
+	}
+	recipient := config.Alerts.RecipientID
+	if recipient == "" {
+		recipient = config.Telegram.RecipientID
+	}
+	r := newRenderer(Job{Name: "alerts", Format: config.recipientFormat(config.Telegram.Format, recipient)}, Enrichment{}, config)
+	var msg []string
+	for _, alert := range alerts {
+		section, ok := config.flowSection(alert.section)
+		title := alert.section
+		if ok {
+			title = section.decrease
+			if alert.value > 0 {
+				title = section.increase
+			}
+		}
+		msg = append(msg, r.p.Sprintf("⚡ %s %s %s %s over %s", r.bold(alert.tier.Name), strings.TrimSuffix(title, ":"),
+			r.code(strings.ToUpper(alert.symbol)), r.amount(math.Abs(alert.value)), r.amount(alert.tier.Threshold)))
+	}
+	config.Telegram.SendFormatted(recipient, strings.Join(msg, "\n"), r.format.telegramParseMode())
+}
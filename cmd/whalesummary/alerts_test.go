@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertStateCheck(t *testing.T) {
+	config := AlertsConfig{
+		Tiers:    []AlertTier{{Name: "high", Threshold: 100}, {Name: "critical", Threshold: 1000}},
+		Trigger:  0.1,
+		Reset:    0.2,
+		Cooldown: "1h",
+	}
+	start := time.Unix(1672531200, 0)
+	steps := []struct {
+		name    string
+		minutes int
+		value   float64
+		want    string // tier that alerts. empty for none
+	}{
+		{"below the trigger", 0, 105, ""},
+		{"crossing fires once", 10, 120, "high"},
+		{"staying above is quiet", 20, 130, ""},
+		{"dropping inside the band keeps the tier", 30, 85, ""},
+		{"crossing again from the band is quiet", 40, 120, ""},
+		{"dropping below the band rearms", 50, 70, ""},
+		{"crossing inside the cooldown is quiet", 60, 120, ""},
+		{"a higher tier alerts inside the cooldown", 65, 1200, "critical"},
+		{"dropping below every band", 70, 10, ""},
+		{"crossing after the cooldown fires", 130, 120, "high"},
+		{"the other direction is another flow", 200, -120, "high"},
+	}
+	state := newAlertState()
+	for _, step := range steps {
+		sections := map[string]map[string]float64{transferSection.name: {"btc": step.value}}
+		alerts := state.check(config, sections, start.Add(time.Duration(step.minutes)*time.Minute))
+		got := ""
+		if len(alerts) > 0 {
+			got = alerts[0].tier.Name
+		}
+		if got != step.want || len(alerts) > 1 {
+			t.Fatalf("%s: alerted %q in %d alerts, want %q", step.name, got, len(alerts), step.want)
+		}
+	}
+}
+
+func TestAlertLevelsReached(t *testing.T) {
+	config := AlertsConfig{Tiers: []AlertTier{{Name: "high", Threshold: 100}, {Name: "critical", Threshold: 1000}}}
+	tests := []struct {
+		value         float64
+		held          int
+		reached, kept int
+	}{
+		{109, 0, 0, 0},
+		{111, 0, 1, 0},
+		{1200, 0, 2, 0},
+		{81, 1, 0, 1},
+		{79, 1, 0, 0},
+		{900, 2, 1, 2},
+		{500, 2, 1, 1},
+	}
+	for _, test := range tests {
+		reached, kept := config.reached(test.value, test.held)
+		if reached != test.reached || kept != test.kept {
+			t.Errorf("%g holding %d: reached %d and kept %d, want %d and %d", test.value, test.held, reached, kept, test.reached, test.kept)
+		}
+	}
+}
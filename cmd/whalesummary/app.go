@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+)
+
+// appPage lays out /report as json and lists the stored transactions of a symbol when it is tapped
+// telegram-web-app.js only themes and expands it so the page also works in a browser
+const appPage = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>whalesummary</title>
+<script src="https://telegram.org/js/telegram-web-app.js"></script>
+<style>
+body { font-family: -apple-system, system-ui, sans-serif; margin: 12px; background: var(--tg-theme-bg-color, #fff); color: var(--tg-theme-text-color, #222); }
+h3 { margin: 0 0 8px; }
+h4 { margin: 16px 0 4px; }
+.line { padding: 2px 0; white-space: pre-wrap; }
+.symbol { cursor: pointer; color: var(--tg-theme-link-color, #2a7ae2); }
+.transactions { font-size: 13px; color: var(--tg-theme-hint-color, #777); margin: 2px 0 6px 12px; }
+</style>
+</head>
+<body>
+<h3 id="title">Loading…</h3>
+<div id="sections"></div>
+<script>
+const app = window.Telegram && Telegram.WebApp;
+if (app) { app.ready(); app.expand(); }
+const query = new URLSearchParams(location.search);
+const usd = value => "$" + (Math.abs(value) / 1e6).toLocaleString(undefined, {maximumFractionDigits: 2}) + "M";
+const time = unix => new Date(unix * 1000).toISOString().slice(5, 16).replace("T", " ");
+function element(tag, className, text) {
+	const e = document.createElement(tag);
+	e.className = className;
+	e.textContent = text;
+	return e;
+}
+async function load(path) {
+	const res = await fetch(path);
+	if (!res.ok) throw new Error(res.status + " " + await res.text());
+	return res.json();
+}
+async function drill(line, symbol) {
+	if (line.nextSibling && line.nextSibling.className === "transactions") {
+		line.nextSibling.remove();
+		return;
+	}
+	const list = element("div", "transactions", "Loading…");
+	line.after(list);
+	const params = new URLSearchParams({start: query.get("start"), end: query.get("end"), symbol: symbol, limit: 50});
+	try {
+		const body = await load("transactions?" + params);
+		list.textContent = body.transactions.length ? "" : "No stored transactions.";
+		for (const t of body.transactions) {
+			list.append(element("div", "", time(t.timestamp) + " " + usd(t.amount_usd) + " " + t.transaction_type + " " +
+				(t.from.owner || t.from.address || "unknown") + " → " + (t.to.owner || t.to.address || "unknown")));
+		}
+	} catch (err) {
+		list.textContent = err.message;
+	}
+}
+(async () => {
+	const title = document.getElementById("title");
+	title.textContent = (query.get("job") || "summary") + " " + time(query.get("start")) + " to " + time(query.get("end")) + " UTC";
+	try {
+		const message = await load("report?" + query + "&format=json");
+		const sections = document.getElementById("sections");
+		if (!message.sections || !message.sections.length) sections.textContent = "Nothing to report.";
+		for (const section of message.sections || []) {
+			if (section.title) sections.append(element("h4", "", section.title));
+			for (const line of section.lines) {
+				const row = element("div", "line" + (line.symbol ? " symbol" : ""), " ".repeat(line.indent) + line.text);
+				if (line.symbol) row.onclick = () => drill(row, line.symbol);
+				sections.append(row);
+			}
+		}
+	} catch (err) {
+		title.textContent = err.message;
+	}
+})();
+</script>
+</body>
+</html>
+`
+
+// app is the page the Explore button of a summary opens with the job, start, and end of the window
+// it only reads the log through the other endpoints so it is as public as they are
+func (s *apiServer) app(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(appPage))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// backfillDay is the windows of one utc day of a backfill
+type backfillDay struct {
+	day     timeWindow
+	windows []timeWindow
+}
+
+// backfillDays splits the range into windows of interval that never cross midnight utc
+// so every day can be summarized once its windows are logged
+func backfillDays(from, to int64, interval time.Duration) []backfillDay {
+	length := int64(interval / time.Second)
+	var days []backfillDay
+	for dayStart := time.Unix(from, 0).UTC().Truncate(24 * time.Hour).Unix(); dayStart < to; dayStart += 24 * 60 * 60 {
+		day := timeWindow{start: dayStart, end: dayStart + 24*60*60}
+		if day.start < from {
+			day.start = from
+		}
+		if day.end > to {
+			day.end = to
+		}
+		b := backfillDay{day: day}
+		for start := day.start; start < day.end; start += length {
+			end := start + length
+			if end > day.end {
+				end = day.end
+			}
+			b.windows = append(b.windows, timeWindow{start: start, end: end})
+		}
+		days = append(days, b)
+	}
+	return days
+}
+
+// runBackfill is the backfill subcommand
+// it walks a range oldest first and logs the transactions and summary of every window like import
+// windows already in the log are skipped so running it again continues where a budget stopped it
+// with -daily, the summary of each finished day is printed as a line of json like -output json
+func runBackfill(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	configPath := flags.String("c", "config.json", "config file")
+	fromFlag := flags.String("from", "", "inclusive start like 2023-01-01 or anything -start takes")
+	toFlag := flags.String("to", "", "exclusive end like 2023-02-01 or anything -end takes. defaults to now")
+	interval := flags.Int64("interval", 48, "minutes of each window. match the interval of the reports")
+	budget := flags.Int("budget", -1, "max whale alert calls of the whole backfill including retries. defaults to whale_alert.budget")
+	daily := flags.Bool("daily", false, "print the summary of every finished day to stdout as a line of json")
+	statePath := flags.String("state", "backfill_state.json", "file to remember how far the backfill got in")
+	flags.Parse(args)
+
+	if *fromFlag == "" {
+		log.Fatal("backfill needs -from")
+	}
+	if *toFlag == "" {
+		*toFlag = "now"
+	}
+	now := time.Now().Truncate(time.Minute)
+	window, err := resolveWindow(*fromFlag, *toFlag, 0, now)
+	if err != nil {
+		log.Fatal("Invalid range: ", err)
+	}
+	if *interval < 1 || time.Duration(*interval)*time.Minute > maxChunk {
+		log.Fatal("Invalid backfill: interval must be between 1 and 60 minutes")
+	}
+	var payloads *payloadWriter
+	if *daily {
+		payloads = &payloadWriter{w: os.Stdout}
+		// everything else printed goes to stderr so stdout stays json
+		os.Stdout = os.Stderr
+	}
+	config := parseConfig(*configPath, os.Environ())
+	if !config.db.Enabled() {
+		log.Fatal("backfill needs log_db_url")
+	}
+	history, err := historyLimit(config.WhaleAlert)
+	if err != nil {
+		log.Fatal("Invalid whale_alert history: ", err)
+	}
+	if oldest := now.Add(-history).Unix(); window.start < oldest {
+		if window.end <= oldest {
+			log.Fatalf("whale alert only fetches %s back. the range ends before that", history)
+		}
+		fmt.Printf("whale alert only fetches %s back. backfilling from %s\n", history, time.Unix(oldest, 0).UTC().Format(time.RFC3339))
+		window.start = oldest
+	}
+	if *budget >= 0 {
+		config.WhaleAlert.Budget = *budget
+	}
+	ctx := context.Background()
+	// a period logged the day before like a digest can still reach into the range
+	periods, err := store.FetchPeriods(ctx, config.db, window.start-24*60*60, window.end)
+	if err != nil {
+		log.Fatal(err)
+	}
+	state, err := readSeedState(*statePath)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if state.From != window.start || state.To != window.end || state.Interval != *interval {
+		// progress of another range
+		state = seedState{From: window.start, To: window.end, Interval: *interval}
+	}
+	entities, err := loadEntities(ctx, config.db, config.Entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	session := whalealert.NewSession(config.WhaleAlert)
+	backfilled, skipped, transactions := 0, 0, 0
+	var stopped error
+	days := backfillDays(window.start, window.end, time.Duration(*interval)*time.Minute)
+	for _, day := range days {
+		for _, w := range day.windows {
+			if overlapsPeriod(periods, w) || w.end <= state.Reached {
+				skipped++
+				continue
+			}
+			label := time.Unix(w.start, 0).UTC().Format("Jan 2 2006 15:04") + " - " + time.Unix(w.end, 0).UTC().Format("15:04 UTC")
+			n, err := seedWindow(ctx, config, session, w, entities)
+			if err != nil {
+				stopped = fmt.Errorf("%s: %w", label, err)
+				break
+			}
+			backfilled++
+			transactions += n
+			fmt.Printf("backfilled %s: %d transactions\n", label, n)
+			state.Reached = w.end
+			err = writeSeedState(*statePath, state)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+		if stopped != nil {
+			break
+		}
+		if payloads != nil {
+			err = printDay(ctx, config, payloads, day.day, entities)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("backfilled %d windows with %d transactions in %d whale alert calls from %s to %s", backfilled, transactions, session.Calls(),
+		time.Unix(window.start, 0).UTC().Format("Jan 2 2006 15:04"), time.Unix(window.end, 0).UTC().Format("Jan 2 2006 15:04 UTC"))
+	if skipped > 0 {
+		msg += fmt.Sprintf(". %d windows were done already", skipped)
+	}
+	if stopped != nil {
+		var truncated *whalealert.BudgetError
+		if errors.As(stopped, &truncated) {
+			msg += ". the budget ran out. run backfill again to continue"
+		}
+		msg += "\nstopped at " + stopped.Error()
+	}
+	fmt.Println(msg)
+	config.Telegram.SendMessage(config.Telegram.LogID, msg)
+}
+
+// overlapsPeriod is whether any logged period covers part of the window
+// so windows logged with another interval aren't fetched and counted again
+func overlapsPeriod(periods []store.Period, window timeWindow) bool {
+	for _, period := range periods {
+		if period.Start < window.end && period.End > window.start {
+			return true
+		}
+	}
+	return false
+}
+
+// printDay prints the summary of the day from its logged transactions
+// so days partly logged before the backfill are complete too
+func printDay(ctx context.Context, config Config, payloads *payloadWriter, day timeWindow, entities map[string]string) error {
+	stored, err := store.FetchTransactions(ctx, config.db, day.start, day.end, "", reportLimit)
+	if err != nil {
+		return err
+	}
+	transactions, _ := classifyOwners(config, normalizeOwners(stored, entities), entities)
+	daySummary, unhandled := summarize(transactions, config)
+	return payloads.write(config.windowPayload(day, daySummary, unhandled))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// runBench is the bench subcommand
+// it replays a stored dataset through the aggregation path as many times as asked
+// and prints how long and how much memory each stage took so regressions can be measured
+// nothing is fetched, logged, or sent
+func runBench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	configPath := flags.String("c", "config.json", "config file")
+	file := flags.String("transactions", "", "json file of whale alert transactions to replay instead of the log")
+	startFlag := flags.String("start", "-24h", "inclusive start of the stored transactions to replay")
+	endFlag := flags.String("end", "", "exclusive end of the stored transactions to replay. defaults to now")
+	limit := flags.Int("limit", 1000000, "most stored transactions to replay")
+	scale := flags.Int("scale", 1, "times to repeat the dataset as distinct transactions for a larger one")
+	runs := flags.Int("runs", 5, "times to run every stage")
+	cpuProfile := flags.String("cpuprofile", "", "file to write a cpu profile of the runs to")
+	memProfile := flags.String("memprofile", "", "file to write a heap profile to after the runs")
+	flags.Parse(args)
+
+	config := parseConfig(*configPath, os.Environ())
+	window, err := resolveWindow(*startFlag, *endFlag, 24*time.Hour, time.Now())
+	if err != nil {
+		log.Fatal("Invalid window: ", err)
+	}
+	dataset, err := benchDataset(config, *file, window, *limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(dataset) < 1 {
+		log.Fatal("No transactions to replay")
+	}
+	if *file != "" {
+		window = datasetWindow(dataset)
+	}
+	dataset = scaleDataset(dataset, *scale)
+	if *runs < 1 {
+		*runs = 1
+	}
+	entities, err := loadEntities(context.Background(), config.db, config.Entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+	config.flagged, _ = loadFlagged(config.Flagged, config.Watchlist)
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+	var results [3]benchResult
+	results[0].name, results[1].name, results[2].name = "normalize", "summarize", "analyze"
+	for run := 0; run < *runs; run++ {
+		// every run starts from its own copy since normalizing rewrites the transactions
+		transactions := append([]whalealert.Transaction(nil), dataset...)
+		var windowSummary Summary
+		results[0].measure(func() {
+			transactions, _ = normalizeAmounts(transactions, newTokenRegistry(config.Tokens))
+			transactions, _ = summary.Dedupe(transactions)
+			transactions = normalizeOwners(transactions, entities)
+		})
+		results[1].measure(func() {
+			windowSummary, _ = summarize(transactions, config)
+		})
+		results[2].measure(func() {
+			for _, job := range config.jobs() {
+				job = job.adapt(transactions, config.Remap)
+				jobSummary := windowSummary
+				jobTransactions := transactions
+				if job.filters() {
+					jobTransactions = job.filter(transactions, config.Remap)
+					jobSummary, _ = summarize(jobTransactions, config)
+				}
+				jobSummary.Buckets = bucketFlows(jobTransactions, window, config)
+				analyzeSummary(jobSummary, Enrichment{Season: season(window.start)}, job, config)
+			}
+		})
+	}
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		runtime.GC()
+		err = pprof.WriteHeapProfile(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("%d transactions × %d runs\n", len(dataset), *runs)
+	fmt.Printf("%-10s %12s %12s %12s %14s\n", "stage", "time/run", "allocs/run", "bytes/run", "transactions/s")
+	var total benchResult
+	total.name = "total"
+	for _, result := range results {
+		total.elapsed += result.elapsed
+		total.allocs += result.allocs
+		total.bytes += result.bytes
+		fmt.Println(result.line(*runs, len(dataset)))
+	}
+	fmt.Println(total.line(*runs, len(dataset)))
+}
+
+// benchDataset is the transactions of the file if any or the stored ones of the window
+func benchDataset(config Config, file string, window timeWindow, limit int) ([]whalealert.Transaction, error) {
+	if file != "" {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var transactions []whalealert.Transaction
+		err = json.Unmarshal(body, &transactions)
+		return transactions, err
+	}
+	if !config.db.Enabled() {
+		return nil, fmt.Errorf("bench needs -transactions or log_db_url")
+	}
+	return store.FetchTransactions(context.Background(), config.db, window.start, window.end, "", limit)
+}
+
+// datasetWindow is the span of the transactions so buckets split them like the window they came from
+func datasetWindow(transactions []whalealert.Transaction) timeWindow {
+	window := timeWindow{start: int64(transactions[0].Timestamp), end: int64(transactions[0].Timestamp)}
+	for _, transaction := range transactions {
+		timestamp := int64(transaction.Timestamp)
+		if timestamp < window.start {
+			window.start = timestamp
+		}
+		if timestamp >= window.end {
+			window.end = timestamp + 1
+		}
+	}
+	return window
+}
+
+// scaleDataset repeats the transactions with their hashes suffixed so dedupe keeps every copy
+func scaleDataset(transactions []whalealert.Transaction, scale int) []whalealert.Transaction {
+	if scale < 2 {
+		return transactions
+	}
+	scaled := make([]whalealert.Transaction, 0, len(transactions)*scale)
+	for i := 0; i < scale; i++ {
+		for _, transaction := range transactions {
+			if i > 0 {
+				transaction.Hash = fmt.Sprintf("%s-%d", transaction.Hash, i)
+			}
+			scaled = append(scaled, transaction)
+		}
+	}
+	return scaled
+}
+
+// benchResult is what a stage took over every run
+type benchResult struct {
+	name    string
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+func (r *benchResult) measure(stage func()) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	stage()
+	r.elapsed += time.Since(started)
+	runtime.ReadMemStats(&after)
+	r.allocs += after.Mallocs - before.Mallocs
+	r.bytes += after.TotalAlloc - before.TotalAlloc
+}
+
+func (r benchResult) line(runs, transactions int) string {
+	perRun := r.elapsed / time.Duration(runs)
+	rate := 0.0
+	if perRun > 0 {
+		rate = float64(transactions) / perRun.Seconds()
+	}
+	return fmt.Sprintf("%-10s %12s %12d %12d %14.0f", r.name, perRun.Round(time.Microsecond), r.allocs/uint64(runs), r.bytes/uint64(runs), rate)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/notify"
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// BotConfig is who can use commands of the bot and how much they return
+type BotConfig struct {
+	Chats []string `json:"chats"` //chat ids that can use commands. defaults to any
+	Top   int      `json:"top"`   //transactions listed by /top. defaults to 10
+}
+
+// command answers the arguments after a command like /top btc 6
+type command func(ctx context.Context, config Config, r renderer, args []string) (string, error)
+
+var commands = map[string]command{
+	"/top":      topCommand,
+	"/whale":    whaleCommand,
+	"/timeline": timelineCommand,
+}
+
+// runBot long polls telegram and answers commands until the process is stopped
+func runBot(config Config) {
+	if !config.db.Enabled() {
+		log.Fatal("the bot needs log_db_url")
+	}
+	var offset int64
+	for {
+		updates, err := config.Telegram.Updates(context.Background(), offset, 30*time.Second)
+		if err != nil {
+			fmt.Println(err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, update := range updates {
+			offset = update.ID + 1
+			answer(config, update)
+		}
+	}
+}
+
+// answer replies to a command in the chat it was sent in
+func answer(config Config, update notify.Update) {
+	post := update.Post()
+	if post == nil || !strings.HasPrefix(post.Text, "/") {
+		return
+	}
+	chat := strconv.FormatInt(post.Chat.ID, 10)
+	if len(config.Bot.Chats) > 0 && !containsString(config.Bot.Chats, chat) {
+		return
+	}
+	fields := strings.Fields(post.Text)
+	// commands in groups can be addressed like /top@whalesummarybot
+	name := strings.ToLower(strings.SplitN(fields[0], "@", 2)[0])
+	run, ok := commands[name]
+	if !ok {
+		return
+	}
+	format := config.recipientFormat(config.Telegram.Format, chat)
+	r := newRenderer(Job{Name: name, Format: format}, Enrichment{}, config)
+	reply, err := run(context.Background(), config, r, fields[1:])
+	if err != nil {
+		// the details are for the log channel
+		config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("%s: %s", post.Text, err))
+		reply = "Something went wrong. Try again later."
+	}
+	config.Telegram.SendFormatted(chat, reply, format.telegramParseMode())
+}
+
+// topCommand lists the largest stored transactions like /top, /top btc, /top 6, or /top btc 6
+func topCommand(ctx context.Context, config Config, r renderer, args []string) (string, error) {
+	symbol, hours := "", 24
+	for _, arg := range args {
+		if value, err := strconv.Atoi(arg); err == nil {
+			if value < 1 || value > 24*30 {
+				return "Hours must be between 1 and 720.", nil
+			}
+			hours = value
+			continue
+		}
+		symbol = strings.ToLower(arg)
+	}
+	limit := config.Bot.Top
+	if limit < 1 {
+		limit = 10
+	}
+	until := time.Now()
+	transactions, err := store.FetchLargestTransactions(ctx, config.db, until.Add(-time.Duration(hours)*time.Hour).Unix(), until.Unix(), symbol, limit)
+	if err != nil {
+		return "", err
+	}
+	subject := "transactions"
+	if symbol != "" {
+		subject = strings.ToUpper(symbol) + " transactions"
+	}
+	if len(transactions) < 1 {
+		return fmt.Sprintf("No %s in the last %dh.", subject, hours), nil
+	}
+	msg := []string{fmt.Sprintf("Largest %s in the last %dh:", subject, hours)}
+	for i, transaction := range transactions {
+		msg = append(msg, r.p.Sprintf("%d. %s %s %s → %s %s", i+1,
+			r.code(strings.ToUpper(transaction.Symbol)), r.amount(transaction.AmountUsd),
+			r.escape(walletName(transaction.From)), r.escape(walletName(transaction.To)),
+			r.link(time.Unix(int64(transaction.Timestamp), 0).UTC().Format("Jan 2 15:04"), config.explorerURL(transaction.Blockchain, transaction.Hash))))
+	}
+	return strings.Join(msg, "\n"), nil
+}
+
+// whaleCommand describes an address like /whale 0xabc from the whales and transactions tables
+func whaleCommand(ctx context.Context, config Config, r renderer, args []string) (string, error) {
+	if len(args) != 1 {
+		return "Usage: /whale <address>", nil
+	}
+	address := args[0]
+	whales, err := store.FetchWhales(ctx, config.db, address)
+	if err != nil {
+		return "", err
+	}
+	if len(whales) < 1 {
+		return "No stored transactions of " + r.code(address) + ".", nil
+	}
+	msg := []string{r.code(address)}
+	for _, whale := range whales {
+		label := whale.Owner
+		if label == "" {
+			label = "unknown owner"
+		}
+		if whale.OwnerType != "" && whale.OwnerType != "unknown" {
+			label += " (" + whale.OwnerType + ")"
+		}
+		line := r.p.Sprintf("%s: %s", whale.Blockchain, r.escape(label))
+		if whale.Count > 0 {
+			line += r.p.Sprintf(". %d transactions worth %s from %s to %s", whale.Count, r.amount(whale.VolumeUsd),
+				time.Unix(whale.FirstSeen, 0).UTC().Format("Jan 2 2006"), time.Unix(whale.LastSeen, 0).UTC().Format("Jan 2 2006"))
+		}
+		msg = append(msg, line)
+	}
+	labels, err := addressLabels(ctx, config, address, whales)
+	if err != nil {
+		return "", err
+	}
+	if len(labels) > 0 {
+		msg = append(msg, "", "Labels by confidence:")
+	}
+	for _, label := range labels {
+		name := label.Owner
+		if name == "" {
+			name = "unnamed"
+		}
+		if label.OwnerType != "" {
+			name += " (" + label.OwnerType + ")"
+		}
+		chain := label.Blockchain
+		if chain == "" {
+			chain = "every blockchain"
+		}
+		msg = append(msg, r.p.Sprintf("%s on %s from %s: %.0f%%", r.escape(name), chain, r.escape(strings.ReplaceAll(label.Source, "_", " ")), label.Confidence*100))
+	}
+	limit := config.Bot.Top
+	if limit < 1 {
+		limit = 10
+	}
+	transactions, err := store.FetchAddressTransactions(ctx, config.db, address, time.Now().AddDate(0, 0, -30).Unix(), limit)
+	if err != nil {
+		return "", err
+	}
+	if len(transactions) > 0 {
+		msg = append(msg, "", "Largest movements in the last 30 days:")
+	}
+	for i, transaction := range transactions {
+		direction, counterparty := "→", transaction.To
+		if !strings.EqualFold(transaction.From.Address, address) {
+			direction, counterparty = "←", transaction.From
+		}
+		msg = append(msg, r.p.Sprintf("%d. %s %s %s %s %s", i+1,
+			r.code(strings.ToUpper(transaction.Symbol)), r.amount(transaction.AmountUsd), direction,
+			r.escape(walletName(counterparty)),
+			r.link(time.Unix(int64(transaction.Timestamp), 0).UTC().Format("Jan 2 15:04"), config.explorerURL(transaction.Blockchain, transaction.Hash))))
+	}
+	return strings.Join(msg, "\n"), nil
+}
+
+// addressLabels is what every source says about the address, most confident first
+// whale alert's from the whales table, configured manual labels, and the labels kept in wallet_labels
+func addressLabels(ctx context.Context, config Config, address string, whales []store.Whale) ([]store.Label, error) {
+	var labels []store.Label
+	for _, whale := range whales {
+		if whale.OwnerType != "" && whale.OwnerType != "unknown" {
+			labels = append(labels, store.Label{Blockchain: whale.Blockchain, Source: store.LabelWhaleAlert, Owner: whale.Owner, OwnerType: whale.OwnerType,
+				Confidence: config.Classify.confidence(store.LabelWhaleAlert)})
+		}
+	}
+	configured := false
+	for _, manual := range config.Classify.Manual {
+		if strings.EqualFold(manual.Address, address) {
+			configured = true
+			labels = append(labels, store.Label{Blockchain: strings.ToLower(manual.Blockchain), Source: store.LabelManual, Owner: manual.Owner, OwnerType: strings.ToLower(manual.OwnerType),
+				Confidence: config.Classify.confidence(store.LabelManual)})
+		}
+	}
+	stored, err := store.FetchWalletLabels(ctx, config.db, []string{address})
+	if err != nil {
+		return nil, err
+	}
+	for _, label := range stored {
+		if (configured && label.Source == store.LabelManual) || (label.Owner == "" && label.OwnerType == "") {
+			// configured manual labels are already listed. nameless ens lookups say nothing
+			continue
+		}
+		labels = append(labels, label)
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Confidence > labels[j].Confidence
+	})
+	return labels, nil
+}
+
+// walletName is the owner or a shortened address
+func walletName(wallet whalealert.Wallet) string {
+	if wallet.Owner != "" {
+		return wallet.Owner
+	}
+	if wallet.Address == "" {
+		return "unknown"
+	}
+	return shortMover(wallet.Address)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
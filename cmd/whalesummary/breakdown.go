@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// BreakdownConfig is how many exchanges to list by net flow
+type BreakdownConfig struct {
+	Top     int `json:"top"`     //exchanges to list. 0 disables the breakdown
+	Symbols int `json:"symbols"` //largest symbols to show per exchange. defaults to 3
+}
+
+const breakdownAnalyzer = "exchanges"
+
+// exchangeSymbolFlows nets inflow per exchange entity then symbol
+// the same transfers as exchangeFlows
+func exchangeSymbolFlows(transactions []whalealert.Transaction, tickermap map[string]string) map[string]map[string]float64 {
+	flows := map[string]map[string]float64{}
+	add := func(exchange, symbol string, value float64) {
+		if flows[exchange] == nil {
+			flows[exchange] = map[string]float64{}
+		}
+		flows[exchange][symbol] += value
+	}
+	for _, transaction := range transactions {
+		if transaction.TransactionType != whalealert.TRANSFER.String() || transaction.From.OwnerType == transaction.To.OwnerType {
+			continue
+		}
+		symbol := summary.RemapSymbol(transaction.Symbol, tickermap)
+		if transaction.From.OwnerType == "exchange" && transaction.From.Owner != "" {
+			add(transaction.From.Owner, symbol, -transaction.AmountUsd)
+			continue
+		}
+		if transaction.To.OwnerType == "exchange" && transaction.To.Owner != "" {
+			add(transaction.To.Owner, symbol, transaction.AmountUsd)
+		}
+	}
+	return flows
+}
+
+// breakdownSections lists the exchanges with the largest net flow with their venue and largest symbols
+// inflow to a derivatives venue means something different from inflow to a spot exchange
+func (r renderer) breakdownSections(totals map[string]float64, flows map[string]map[string]float64) []summary.Section {
+	top := r.config.Breakdown.Top
+	if top < 1 || !r.job.analyzes(breakdownAnalyzer) {
+		return nil
+	}
+	var exchanges []string
+	for exchange, value := range totals {
+		if math.Abs(value) < r.job.threshold() {
+			continue
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if len(exchanges) < 1 {
+		return nil
+	}
+	sort.Slice(exchanges, func(i, j int) bool {
+		return math.Abs(totals[exchanges[i]]) > math.Abs(totals[exchanges[j]])
+	})
+	if len(exchanges) > top {
+		exchanges = exchanges[:top]
+	}
+	limit := r.config.Breakdown.Symbols
+	if limit < 1 {
+		limit = 3
+	}
+	section := summary.Section{Name: breakdownAnalyzer, Title: "Top Exchanges by Net Flow:"}
+	for _, exchange := range exchanges {
+		symbols := make([]string, 0, len(flows[exchange]))
+		for symbol := range flows[exchange] {
+			symbols = append(symbols, symbol)
+		}
+		sort.Slice(symbols, func(i, j int) bool {
+			return math.Abs(flows[exchange][symbols[i]]) > math.Abs(flows[exchange][symbols[j]])
+		})
+		if len(symbols) > limit {
+			symbols = symbols[:limit]
+		}
+		parts := make([]string, len(symbols))
+		for i, symbol := range symbols {
+			parts[i] = strings.ToUpper(symbol) + " " + r.signed(flows[exchange][symbol])
+		}
+		section.Lines = append(section.Lines, summary.Line{
+			Text: fmt.Sprintf("%s (%s): %s (%s)",
+				r.escape(strings.Title(exchange)), r.config.venue(exchange), r.signed(totals[exchange]), r.escape(strings.Join(parts, ", "))),
+			Indent: 2,
+			Group:  exchange,
+			Value:  totals[exchange],
+		})
+	}
+	return []summary.Section{section}
+}
+
+// signed is an amount with + for inflow and - for outflow
+func (r renderer) signed(value float64) string {
+	if value < 0 {
+		return "-" + r.amount(-value)
+	}
+	return "+" + r.amount(value)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// BucketsConfig splits the window into equal parts to tell when within it the flows happened
+type BucketsConfig struct {
+	Count int `json:"count"` //parts of the window like 4 for 12 minute parts of 48 minutes. 0 or 1 disables
+}
+
+const paceAnalyzer = "pace"
+
+// buckets is the net flow by section then symbol of each equal part of a window
+type buckets struct {
+	Flows  map[string]map[string][]float64
+	Length time.Duration
+}
+
+// bucketFlows summarizes each part of the window on its own like the whole window
+func bucketFlows(transactions []whalealert.Transaction, window timeWindow, config Config) buckets {
+	n := int64(config.Buckets.Count)
+	if n < 2 || window.end-window.start < n {
+		return buckets{}
+	}
+	length := (window.end - window.start) / n
+	parts := make([][]whalealert.Transaction, n)
+	for _, transaction := range transactions {
+		i := (int64(transaction.Timestamp) - window.start) / length
+		if i < 0 {
+			i = 0
+		}
+		if i >= n {
+			i = n - 1
+		}
+		parts[i] = append(parts[i], transaction)
+	}
+	flows := map[string]map[string][]float64{}
+	for i, part := range parts {
+		partSummary, _ := summarize(part, config)
+		for section, partFlows := range partSummary.sections() {
+			for symbol, value := range partFlows {
+				if flows[section] == nil {
+					flows[section] = map[string][]float64{}
+				}
+				if flows[section][symbol] == nil {
+					flows[section][symbol] = make([]float64, n)
+				}
+				flows[section][symbol][i] = value
+			}
+		}
+	}
+	return buckets{Flows: flows, Length: time.Duration(length) * time.Second}
+}
+
+// pace is whether most of a net flow came early or late in the window
+// from the share of the total in each half
+func pace(values []float64) string {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	if total == 0 {
+		return "steady"
+	}
+	half := len(values) / 2
+	first := 0.0
+	for _, value := range values[:half] {
+		first += value
+	}
+	last := 0.0
+	for _, value := range values[len(values)-half:] {
+		last += value
+	}
+	switch {
+	case first/total >= 2.0/3:
+		return "front-loaded"
+	case last/total >= 2.0/3:
+		return "accelerating"
+	}
+	return "steady"
+}
+
+// paceSections show the share of each reported flow in each part of the window like
+// Exchange Inflow `BTC`: 10% 20% 30% 40% accelerating
+func (r renderer) paceSections(sections map[string]map[string]float64, parts buckets) []summary.Section {
+	if len(parts.Flows) < 1 || !r.job.analyzes(paceAnalyzer) {
+		return nil
+	}
+	var lines []summary.Line
+	for name, flows := range sections {
+		section, ok := r.config.flowSection(name)
+		if !ok {
+			continue
+		}
+		analyzer := section.analyzer
+		if analyzer == "" {
+			analyzer = section.name
+		}
+		if !r.job.analyzes(analyzer) {
+			continue
+		}
+		for symbol, value := range flows {
+			values := parts.Flows[name][symbol]
+			if math.Abs(value) < r.job.threshold() || len(values) < 2 {
+				continue
+			}
+			title := section.increase
+			if value < 0 {
+				title = section.decrease
+			}
+			shares := make([]string, len(values))
+			for i, part := range values {
+				// adding zero turns the -0 of an empty part of an outflow into 0
+				shares[i] = fmt.Sprintf("%.0f%%", part/value*100+0)
+			}
+			verdict := pace(values)
+			lines = append(lines, summary.Line{
+				Text:    fmt.Sprintf("%s %s: %s %s", strings.TrimSuffix(title, ":"), r.code(strings.ToUpper(symbol)), strings.Join(shares, " "), verdict),
+				Indent:  2,
+				Group:   name,
+				Symbol:  symbol,
+				Value:   value,
+				Verdict: verdict,
+			})
+		}
+	}
+	if len(lines) < 1 {
+		return nil
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := math.Abs(lines[i].Value), math.Abs(lines[j].Value)
+		if a == b {
+			return lines[i].Text < lines[j].Text
+		}
+		return a > b
+	})
+	return []summary.Section{{Name: paceAnalyzer, Title: fmt.Sprintf("Pace over %d × %s:", r.config.Buckets.Count, spanLabel(parts.Length)), Lines: lines}}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+const chainAnalyzer = "chains"
+
+// nativeCoins are the coins blockchains pay fees in as named by whale alert
+// tokens without a contract in the config add to them
+var nativeCoins = map[string]string{
+	"bitcoin":   "btc",
+	"ethereum":  "eth",
+	"tron":      "trx",
+	"solana":    "sol",
+	"ripple":    "xrp",
+	"binance":   "bnb",
+	"polygon":   "matic",
+	"avalanche": "avax",
+	"cardano":   "ada",
+	"dogecoin":  "doge",
+	"litecoin":  "ltc",
+	"stellar":   "xlm",
+}
+
+// chainFlow is the exchange net flow of a blockchain split into its native coin and its tokens
+type chainFlow struct {
+	Native float64
+	Tokens float64
+}
+
+// nativeCoin is the lowercase native symbol of the chain. empty if unknown
+func (config Config) nativeCoin(chain string) string {
+	chain = strings.ToLower(chain)
+	for _, token := range config.Tokens {
+		if token.Contract == "" && strings.EqualFold(token.Chain, chain) {
+			return strings.ToLower(token.Symbol)
+		}
+	}
+	return nativeCoins[chain]
+}
+
+// chainFlows nets transfers into and out of exchanges per blockchain
+// chains with an unknown native coin are skipped
+func chainFlows(transactions []whalealert.Transaction, config Config) map[string]chainFlow {
+	flows := map[string]chainFlow{}
+	for _, transaction := range transactions {
+		if transaction.TransactionType != whalealert.TRANSFER.String() || transaction.From.OwnerType == transaction.To.OwnerType {
+			continue
+		}
+		value := transaction.AmountUsd
+		if transaction.From.OwnerType == "exchange" {
+			value = -value
+		} else if transaction.To.OwnerType != "exchange" {
+			continue
+		}
+		chain := strings.ToLower(transaction.Blockchain)
+		native := config.nativeCoin(chain)
+		if native == "" {
+			continue
+		}
+		flow := flows[chain]
+		if transaction.Contract == "" && strings.EqualFold(transaction.Symbol, native) {
+			flow.Native += value
+		} else {
+			flow.Tokens += value
+		}
+		flows[chain] = flow
+	}
+	return flows
+}
+
+// chainLines compares native and token exchange flows of chains with both
+// largest chains first
+func (r renderer) chainSections(flows map[string]chainFlow) []summary.Section {
+	if !r.job.analyzes(chainAnalyzer) {
+		return nil
+	}
+	var chains []string
+	for chain, flow := range flows {
+		if flow.Native == 0 || flow.Tokens == 0 {
+			// nothing to compare. already in the exchange flows
+			continue
+		}
+		if math.Max(math.Abs(flow.Native), math.Abs(flow.Tokens)) < r.job.threshold() {
+			continue
+		}
+		chains = append(chains, chain)
+	}
+	if len(chains) < 1 {
+		return nil
+	}
+	volume := func(flow chainFlow) float64 {
+		return math.Abs(flow.Native) + math.Abs(flow.Tokens)
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return volume(flows[chains[i]]) > volume(flows[chains[j]])
+	})
+	section := summary.Section{Name: chainAnalyzer, Title: "Chain Flows:"}
+	for _, chain := range chains {
+		flow := flows[chain]
+		m := r.p.Sprintf("%s: %s %s %s | tokens %s %s", r.escape(chain),
+			r.code(strings.ToUpper(r.config.nativeCoin(chain))), direction(flow.Native), r.amount(math.Abs(flow.Native)),
+			direction(flow.Tokens), r.amount(math.Abs(flow.Tokens)))
+		if flow.Tokens > 0 && flow.Native < 0 {
+			// tokens readied for selling while the native coin is withdrawn
+			m += " (tokens in, native out)"
+		}
+		section.Lines = append(section.Lines, summary.Line{Text: m, Indent: 2, Group: chain, Symbol: r.config.nativeCoin(chain), Value: flow.Native})
+	}
+	return []summary.Section{section}
+}
+
+// direction is whether an exchange net flow is inflow or outflow
+func direction(value float64) string {
+	if value < 0 {
+		return "out"
+	}
+	return "in"
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// resolveChats replaces every configured telegram chat with the numeric id telegram resolves it to
+// so a wrong recipient is reported once at startup instead of failing every send
+// chats that can't be resolved are kept as configured
+func resolveChats(config Config) Config {
+	if config.Telegram.BotID == "" {
+		return config
+	}
+	cache := map[string]string{}
+	if config.Telegram.ChatCache != "" {
+		body, err := ioutil.ReadFile(config.Telegram.ChatCache)
+		if err == nil {
+			err = json.Unmarshal(body, &cache)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("chat cache %s: %s\n", config.Telegram.ChatCache, err)
+		}
+	}
+	cached := len(cache)
+	var errs []string
+	// not asked again for every place they're configured
+	failed := map[string]bool{}
+	resolve := func(configured string) string {
+		if configured == "" || failed[configured] {
+			return configured
+		}
+		if id, ok := cache[configured]; ok {
+			return id
+		}
+		id, err := config.Telegram.ResolveChat(context.Background(), configured)
+		if err != nil {
+			errs = append(errs, err.Error())
+			failed[configured] = true
+			return configured
+		}
+		cache[configured] = id
+		return id
+	}
+	config.Telegram.LogID = resolve(config.Telegram.LogID)
+	config.Telegram.RecipientID = resolve(config.Telegram.RecipientID)
+	config.Flagged.RecipientID = resolve(config.Flagged.RecipientID)
+	config.Watch.RecipientID = resolve(config.Watch.RecipientID)
+	config.Alerts.RecipientID = resolve(config.Alerts.RecipientID)
+	config.Reconcile.RecipientID = resolve(config.Reconcile.RecipientID)
+	categories := make([]Category, len(config.Categories))
+	for i, category := range config.Categories {
+		category.RecipientID = resolve(category.RecipientID)
+		categories[i] = category
+	}
+	config.Categories = categories
+	rules := make([]Rule, len(config.Rules))
+	for i, rule := range config.Rules {
+		rule.RecipientID = resolve(rule.RecipientID)
+		rules[i] = rule
+	}
+	config.Rules = rules
+	jobs := make([]Job, len(config.Jobs))
+	for i, job := range config.Jobs {
+		recipients := make([]string, len(job.Recipients))
+		for j, recipient := range job.Recipients {
+			recipients[j] = resolve(recipient)
+		}
+		job.Recipients = recipients
+		jobs[i] = job
+	}
+	config.Jobs = jobs
+	locales := map[string]string{}
+	for recipient, locale := range config.Locales {
+		locales[resolve(recipient)] = locale
+	}
+	if config.Locales != nil {
+		config.Locales = locales
+	}
+	quotes := map[string]string{}
+	for recipient, quote := range config.Quotes {
+		quotes[resolve(recipient)] = quote
+	}
+	if config.Quotes != nil {
+		config.Quotes = quotes
+	}
+
+	if len(errs) > 0 {
+		msg := "telegram chats:\n" + strings.Join(errs, "\n")
+		fmt.Println(msg)
+		config.Telegram.SendMessage(config.Telegram.LogID, msg)
+	}
+	if config.Telegram.ChatCache == "" || len(cache) == cached {
+		return config
+	}
+	body, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(config.Telegram.ChatCache, body, 0644)
+	}
+	if err != nil {
+		fmt.Printf("chat cache %s: %s\n", config.Telegram.ChatCache, err)
+	}
+	return config
+}
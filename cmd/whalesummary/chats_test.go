@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/enzosv/whalesummary/notify"
+	"github.com/enzosv/whalesummary/retry"
+)
+
+// a quote keyed by username has to follow it to the numeric id recipientFormat looks up
+func TestResolveChatsRekeysQuotes(t *testing.T) {
+	ids := map[string]int64{"@whalechannel": -1001234567890, "@logchannel": -1009876543210}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := ids[r.URL.Query().Get("chat_id")]
+		response := map[string]interface{}{"ok": ok, "description": "Bad Request: chat not found"}
+		if ok {
+			response["result"] = map[string]interface{}{"id": id, "type": "channel"}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	config := Config{
+		Telegram: TelegramConfig{
+			Telegram:    notify.Telegram{BotID: "x", URL: server.URL, Policy: retry.Policy{Attempts: 1, Timeout: time.Second}},
+			RecipientID: "https://t.me/whalechannel",
+			LogID:       "@logchannel",
+		},
+		Quotes:  map[string]string{"@whalechannel": "btc"},
+		Locales: map[string]string{"t.me/whalechannel": "de-DE"},
+	}
+	config = resolveChats(config)
+	if config.Telegram.RecipientID != "-1001234567890" {
+		t.Fatalf("recipient resolved to %s", config.Telegram.RecipientID)
+	}
+	if quote := config.Quotes["-1001234567890"]; quote != "btc" {
+		t.Errorf("quote of the resolved recipient is %q, want btc", quote)
+	}
+	if locale := config.Locales["-1001234567890"]; locale != "de-DE" {
+		t.Errorf("locale of the resolved recipient is %q, want de-DE", locale)
+	}
+	if format := config.recipientFormat(Format{}, config.Telegram.RecipientID); format.quote() != "btc" {
+		t.Errorf("recipient format quotes %q, want btc", format.quote())
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// ClassifyConfig is how wallets whale alert reports as unknown are classified so their transfers aren't ignored
+type ClassifyConfig struct {
+	Enabled bool          `json:"enabled"`
+	Manual  []ManualLabel `json:"manual"`
+	Labels  []string      `json:"labels"`  //paths or urls of csv files with address,owner,owner_type,blockchain,confidence rows. the last two are optional
+	ENSURL  string        `json:"ens_url"` //url with %s for an ethereum address that answers json with its reverse ens name. optional
+	// trust from 0 to 1 per label source like manual or list. the most trusted label of an address is used
+	Confidence map[string]float64 `json:"confidence"`
+	Days       int                `json:"days"` //history in the log considered. defaults to 30
+	// share of what an address sent that went to one exchange for it to be treated as a deposit address of that exchange. defaults to 0.9
+	DepositShare    float64 `json:"deposit_share"`
+	WhaleUSD        float64 `json:"whale_usd"`        //usd an address moved in the history to be a whale. defaults to 50000000
+	ContractSymbols int     `json:"contract_symbols"` //symbols an address moved in the history to be a contract like a router or bridge. defaults to 5
+	MinTransactions int     `json:"min_transactions"` //stored transactions of an address before it is classified. defaults to 3
+}
+
+// ManualLabel is a configured owner of an address
+type ManualLabel struct {
+	Address    string `json:"address"`
+	Owner      string `json:"owner"`
+	OwnerType  string `json:"owner_type"`
+	Blockchain string `json:"blockchain"` //defaults to every blockchain
+}
+
+// owner types of classified wallets besides exchange
+// added to owner_types unless configured so their flows are reported
+const (
+	whaleOwnerType    = "whale"
+	contractOwnerType = "contract"
+)
+
+var classifiedOwnerTypes = map[string]OwnerType{
+	// accumulation by large holders
+	whaleOwnerType:    {Title: "Whale", InflowBullish: true},
+	contractOwnerType: {Title: "Contract", Neutral: true},
+}
+
+// defaultConfidence is how far each label source is trusted unless configured
+// whale alert is trusted over imported lists so they only label what it doesn't know
+var defaultConfidence = map[string]float64{
+	store.LabelManual:     1,
+	store.LabelWhaleAlert: 0.8,
+	store.LabelList:       0.7,
+	store.LabelENS:        0.5,
+	store.LabelHistory:    0.3,
+}
+
+func (config ClassifyConfig) confidence(source string) float64 {
+	if confidence, ok := config.Confidence[source]; ok {
+		return confidence
+	}
+	return defaultConfidence[source]
+}
+
+func (config ClassifyConfig) days() int {
+	if config.Days > 0 {
+		return config.Days
+	}
+	return 30
+}
+
+func (config ClassifyConfig) depositShare() float64 {
+	if config.DepositShare > 0 {
+		return config.DepositShare
+	}
+	return 0.9
+}
+
+func (config ClassifyConfig) whaleUSD() float64 {
+	if config.WhaleUSD > 0 {
+		return config.WhaleUSD
+	}
+	return 50000000
+}
+
+func (config ClassifyConfig) contractSymbols() int {
+	if config.ContractSymbols > 0 {
+		return config.ContractSymbols
+	}
+	return 5
+}
+
+func (config ClassifyConfig) minTransactions() int {
+	if config.MinTransactions > 0 {
+		return config.MinTransactions
+	}
+	return 3
+}
+
+// labels are every label of an address from any source by lowercase address
+type walletLabels map[string][]store.Label
+
+func (labels walletLabels) add(label store.Label) {
+	label.Blockchain = strings.ToLower(label.Blockchain)
+	label.Address = strings.ToLower(label.Address)
+	labels[label.Address] = append(labels[label.Address], label)
+}
+
+// best is the most confident label of the address on the blockchain or on every blockchain
+// the first added wins a tie
+func (labels walletLabels) best(blockchain, address string) (store.Label, bool) {
+	var best store.Label
+	found := false
+	for _, label := range labels[strings.ToLower(address)] {
+		if label.Blockchain != "" && !strings.EqualFold(label.Blockchain, blockchain) {
+			continue
+		}
+		if label.Owner == "" && label.OwnerType == "" {
+			// like an address without an ens name
+			continue
+		}
+		if !found || label.Confidence > best.Confidence {
+			best, found = label, true
+		}
+	}
+	return best, found
+}
+
+func importLabels(list string, confidence float64, labels walletLabels) error {
+	reader, err := openList(list)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	rows := csvRows(reader)
+	for {
+		row, err := rows.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(row) < 3 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		label := store.Label{Address: strings.TrimSpace(row[0]), Source: store.LabelList, Owner: strings.TrimSpace(row[1]),
+			OwnerType: strings.ToLower(strings.TrimSpace(row[2])), Confidence: confidence}
+		if label.OwnerType == "" || label.OwnerType == "unknown" {
+			continue
+		}
+		if len(row) > 3 {
+			label.Blockchain = strings.TrimSpace(row[3])
+		}
+		if len(row) > 4 && strings.TrimSpace(row[4]) != "" {
+			label.Confidence, err = strconv.ParseFloat(strings.TrimSpace(row[4]), 64)
+			if err != nil {
+				return fmt.Errorf("confidence of %s: %w", label.Address, err)
+			}
+		}
+		labels.add(label)
+	}
+}
+
+// ensName is the reverse ens name of an ethereum address. empty if it has none
+func ensName(ensURL, address string) (string, error) {
+	res, err := http.Get(fmt.Sprintf(ensURL, url.PathEscape(address)))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("returned %s", res.Status)
+	}
+	var response struct {
+		Name string `json:"name"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	return response.Name, err
+}
+
+// classifyOwners labels wallets with the most confident of what every source says about them
+// sources are manual labels, imported lists, what whale alert says in the transaction or said before in the whales table,
+// ens names, and for wallets nothing else knows the history of the address in the log:
+// an address that sent nearly everything to one exchange is a deposit address of that exchange
+// one that moved many symbols is a contract. one that moved a lot is a whale
+// the log keeps what whale alert said. only the summary sees the labels
+// labels other than whale alert's that were applied are kept in wallet_labels for /whale
+func classifyOwners(config Config, transactions []whalealert.Transaction, entities map[string]string) ([]whalealert.Transaction, []error) {
+	if !config.Classify.Enabled {
+		return transactions, nil
+	}
+	var errs []error
+	labels := walletLabels{}
+	for _, manual := range config.Classify.Manual {
+		labels.add(store.Label{Blockchain: manual.Blockchain, Address: manual.Address, Source: store.LabelManual,
+			Owner: manual.Owner, OwnerType: strings.ToLower(manual.OwnerType), Confidence: config.Classify.confidence(store.LabelManual)})
+	}
+	for _, list := range config.Classify.Labels {
+		err := importLabels(list, config.Classify.confidence(store.LabelList), labels)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("labels %s: %w", list, err))
+		}
+	}
+	unknown := func(wallet whalealert.Wallet) bool {
+		return wallet.OwnerType == "" || strings.EqualFold(wallet.OwnerType, "unknown")
+	}
+	var addresses, unknowns []string
+	seen := map[string]bool{}
+	unknownChains := map[string]string{}
+	for _, transaction := range transactions {
+		for _, wallet := range []whalealert.Wallet{transaction.From, transaction.To} {
+			if wallet.Address == "" {
+				continue
+			}
+			if !unknown(wallet) {
+				labels.add(store.Label{Blockchain: transaction.Blockchain, Address: wallet.Address, Source: store.LabelWhaleAlert,
+					Owner: wallet.Owner, OwnerType: strings.ToLower(wallet.OwnerType), Confidence: config.Classify.confidence(store.LabelWhaleAlert)})
+			} else if _, ok := unknownChains[wallet.Address]; !ok {
+				unknownChains[wallet.Address] = strings.ToLower(transaction.Blockchain)
+				unknowns = append(unknowns, wallet.Address)
+			}
+			if !seen[wallet.Address] {
+				seen[wallet.Address] = true
+				addresses = append(addresses, wallet.Address)
+			}
+		}
+	}
+	ctx := context.Background()
+	var updated []store.Label
+	asked := map[string]bool{}
+	if config.db.Enabled() {
+		stored, err := store.FetchWalletLabels(ctx, config.db, addresses)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wallet labels: %w", err))
+		}
+		for _, label := range stored {
+			if label.Source == store.LabelENS {
+				asked[label.Address] = true
+			}
+			if label.Source != store.LabelHistory {
+				// history is classified again below with what the log has now
+				labels.add(label)
+			}
+		}
+		whales, err := store.FetchWhaleLabels(ctx, config.db, unknowns)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("whale labels: %w", err))
+		}
+		for _, label := range whales {
+			label.Confidence = config.Classify.confidence(store.LabelWhaleAlert)
+			labels.add(label)
+		}
+	}
+	if config.Classify.ENSURL != "" {
+		for _, address := range unknowns {
+			if unknownChains[address] != "ethereum" || asked[strings.ToLower(address)] {
+				continue
+			}
+			name, err := ensName(config.Classify.ENSURL, address)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ens %s: %w", address, err))
+				continue
+			}
+			// kept even without a name so it isn't asked again
+			label := store.Label{Blockchain: "ethereum", Address: address, Source: store.LabelENS, Owner: name, Confidence: config.Classify.confidence(store.LabelENS)}
+			labels.add(label)
+			updated = append(updated, label)
+		}
+	}
+	if config.db.Enabled() {
+		var unlabeled []string
+		for _, address := range unknowns {
+			if _, ok := labels.best(unknownChains[address], address); !ok {
+				unlabeled = append(unlabeled, address)
+			}
+		}
+		since := time.Now().AddDate(0, 0, -config.Classify.days()).Unix()
+		counterparties, err := store.FetchCounterparties(ctx, config.db, since, unlabeled)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wallet history: %w", err))
+		}
+		for _, label := range config.Classify.heuristics(counterparties, entities) {
+			labels.add(label)
+		}
+	}
+	classified := make([]whalealert.Transaction, len(transactions))
+	applied := map[[3]string]bool{}
+	count := 0
+	for i, transaction := range transactions {
+		for _, wallet := range []*whalealert.Wallet{&transaction.From, &transaction.To} {
+			label, ok := labels.best(transaction.Blockchain, wallet.Address)
+			if !ok || wallet.Address == "" || (label.Source == store.LabelWhaleAlert && !unknown(*wallet)) {
+				continue
+			}
+			if label.Owner != "" {
+				wallet.Owner = ownerEntity(label.Owner, entities)
+			}
+			if label.OwnerType != "" {
+				wallet.OwnerType = label.OwnerType
+			}
+			count++
+			key := [3]string{strings.ToLower(transaction.Blockchain), label.Address, label.Source}
+			if label.Source != store.LabelWhaleAlert && label.Source != store.LabelENS && !applied[key] {
+				applied[key] = true
+				label.Blockchain = key[0]
+				updated = append(updated, label)
+			}
+		}
+		classified[i] = transaction
+	}
+	if count > 0 {
+		fmt.Printf("classified %d wallets\n", count)
+	}
+	if len(updated) > 0 && config.logs(func() []string { return []string{fmt.Sprintf("%d wallet labels", len(updated))} }) {
+		err := store.LogWalletLabels(ctx, config.db, time.Now().Unix(), updated)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return classified, errs
+}
+
+// heuristics classify addresses by what they sent and received
+func (config ClassifyConfig) heuristics(counterparties []store.Counterparty, entities map[string]string) []store.Label {
+	type activity struct {
+		count     int
+		volume    float64
+		sent      float64
+		exchanges map[string]float64 // usd sent per exchange entity
+		symbols   map[string]bool
+	}
+	addresses := map[[2]string]*activity{}
+	for _, counterparty := range counterparties {
+		key := [2]string{counterparty.Blockchain, counterparty.Address}
+		a := addresses[key]
+		if a == nil {
+			a = &activity{exchanges: map[string]float64{}, symbols: map[string]bool{}}
+			addresses[key] = a
+		}
+		a.count += counterparty.Count
+		a.volume += counterparty.AmountUsd
+		a.symbols[strings.ToLower(counterparty.Symbol)] = true
+		if !counterparty.Sent {
+			continue
+		}
+		a.sent += counterparty.AmountUsd
+		if strings.EqualFold(counterparty.OwnerType, "exchange") && counterparty.Owner != "" {
+			a.exchanges[ownerEntity(counterparty.Owner, entities)] += counterparty.AmountUsd
+		}
+	}
+	var labels []store.Label
+	for key, a := range addresses {
+		if a.count < config.minTransactions() {
+			continue
+		}
+		exchange, deposited := "", 0.0
+		for entity, value := range a.exchanges {
+			if value > deposited || (value == deposited && entity < exchange) {
+				exchange, deposited = entity, value
+			}
+		}
+		label := store.Label{Blockchain: key[0], Address: key[1], Source: store.LabelHistory, Confidence: config.confidence(store.LabelHistory)}
+		switch {
+		case a.sent > 0 && deposited >= a.sent*config.depositShare():
+			// forwards what it receives. depositing into it is depositing into the exchange
+			label.Owner, label.OwnerType = exchange, "exchange"
+		case len(a.symbols) >= config.contractSymbols():
+			label.OwnerType = contractOwnerType
+		case a.volume >= config.whaleUSD():
+			label.OwnerType = whaleOwnerType
+		default:
+			continue
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
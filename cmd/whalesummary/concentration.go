@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+type ConcentrationConfig struct {
+	Top      int     `json:"top"`      //entities to sum. defaults to 3
+	Dominant float64 `json:"dominant"` //share of volume from one entity that flags the period. defaults to 0.5
+}
+
+const concentrationAnalyzer = "concentration"
+
+// moverVolumes is the usd volume sent by each entity
+// owners when known and addresses otherwise. mints count for the recipient
+func moverVolumes(transactions []whalealert.Transaction) map[string]float64 {
+	volumes := map[string]float64{}
+	for _, transaction := range transactions {
+		wallet := transaction.From
+		if transaction.TransactionType == whalealert.MINT.String() {
+			wallet = transaction.To
+		}
+		mover := wallet.Owner
+		if mover == "" {
+			mover = wallet.Address
+		}
+		if mover == "" {
+			continue
+		}
+		volumes[mover] += transaction.AmountUsd
+	}
+	return volumes
+}
+
+// concentrationSections describes how much of the volume came from the biggest movers
+func (r renderer) concentrationSections(volumes map[string]float64) []summary.Section {
+	if !r.job.analyzes(concentrationAnalyzer) || len(volumes) < 2 {
+		return nil
+	}
+	top := r.config.Concentration.Top
+	if top < 1 {
+		top = 3
+	}
+	dominant := r.config.Concentration.Dominant
+	if dominant <= 0 {
+		dominant = 0.5
+	}
+	total := 0.0
+	movers := make([]string, 0, len(volumes))
+	for mover, volume := range volumes {
+		total += volume
+		movers = append(movers, mover)
+	}
+	if total < r.job.threshold() {
+		return nil
+	}
+	sort.Slice(movers, func(i, j int) bool {
+		return volumes[movers[i]] > volumes[movers[j]]
+	})
+	if len(movers) < top {
+		top = len(movers)
+	}
+	topVolume := 0.0
+	for _, mover := range movers[:top] {
+		topVolume += volumes[mover]
+	}
+	section := summary.Section{Name: concentrationAnalyzer, Title: r.p.Sprintf("Concentration: top %d entities moved %.0f%% of %s", top, topVolume/total*100, r.amount(total))}
+	if share := volumes[movers[0]] / total; share >= dominant {
+		section.Lines = append(section.Lines, summary.Line{
+			Text:   r.p.Sprintf("⚠️ dominated by %s (%.0f%%)", r.code(shortMover(movers[0])), math.Floor(share*100)),
+			Indent: 2,
+			Group:  movers[0],
+			Value:  volumes[movers[0]],
+		})
+	}
+	return []summary.Section{section}
+}
+
+// shortMover abbreviates addresses so the line stays readable
+func shortMover(mover string) string {
+	if len(mover) > 16 && !strings.Contains(mover, " ") {
+		return mover[:6] + "…" + mover[len(mover)-4:]
+	}
+	return mover
+}
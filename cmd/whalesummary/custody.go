@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+const (
+	stakingCategory = "staking"
+	custodyCategory = "custody"
+)
+
+// custodians are matched as substrings of the normalized owner
+// the config adds to them with owner_categories
+var custodians = map[string]string{
+	"coinbase custody":        custodyCategory,
+	"coinbase prime":          custodyCategory,
+	"bitgo":                   custodyCategory,
+	"fidelity":                custodyCategory,
+	"anchorage":               custodyCategory,
+	"beacon deposit contract": stakingCategory,
+	"eth2 deposit":            stakingCategory,
+	"lido":                    stakingCategory,
+	"rocket pool":             stakingCategory,
+	"figment":                 stakingCategory,
+	"kiln":                    stakingCategory,
+}
+
+var (
+	// crypto staked is locked away and earning. bullish
+	// crypto unstaked may only be rotating providers. neutral
+	stakingSection = flowSection{name: stakingCategory, increase: "Staked:", decrease: "Unstaked:", increaseBullish: true, decreaseLabel: "neutral"}
+	// crypto into etf and institutional custody is bought to hold. bullish
+	// crypto out of custody is often settlement between custodians. neutral
+	custodySection = flowSection{name: custodyCategory, increase: "Custody Inflow:", decrease: "Custody Outflow:", increaseBullish: true, decreaseLabel: "neutral"}
+)
+
+// ownerCategory is staking or custody if the owner is a known provider
+func (config Config) ownerCategory(owner string) string {
+	list := map[string]string{}
+	for entity, category := range custodians {
+		list[entity] = category
+	}
+	for entity, category := range config.OwnerCategories {
+		list[strings.ToLower(strings.TrimSpace(entity))] = strings.ToLower(category)
+	}
+	_, category := watchCategory(owner, list)
+	return category
+}
+
+// custodyFlows nets transfers into and out of staking providers and custodians per symbol
+// the rest of the transactions are returned for the other sections
+func custodyFlows(transactions []whalealert.Transaction, config Config) (map[string]float64, map[string]float64, []whalealert.Transaction) {
+	flows := map[string]map[string]float64{stakingCategory: {}, custodyCategory: {}}
+	var others []whalealert.Transaction
+	for _, transaction := range transactions {
+		if transaction.TransactionType != whalealert.TRANSFER.String() {
+			others = append(others, transaction)
+			continue
+		}
+		from := config.ownerCategory(transaction.From.Owner)
+		to := config.ownerCategory(transaction.To.Owner)
+		symbol := summary.RemapSymbol(transaction.Symbol, config.Remap)
+		switch {
+		case from != "" && from == to:
+			// internal
+		case flows[to] != nil:
+			flows[to][symbol] += transaction.AmountUsd
+		case flows[from] != nil:
+			flows[from][symbol] -= transaction.AmountUsd
+		default:
+			others = append(others, transaction)
+		}
+	}
+	return flows[stakingCategory], flows[custodyCategory], others
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+type DaemonConfig struct {
+	State string `json:"state"` //file remembering the last processed window. defaults to daemon_state.json
+}
+
+// runDaemon summarizes consecutive windows of interval forever
+// the end of the last processed window is persisted so restarts resume where they stopped
+func runDaemon(config Config, interval time.Duration) {
+	path := config.Daemon.State
+	if path == "" {
+		path = "daemon_state.json"
+	}
+	state, err := readDaemonState(path)
+	if err != nil {
+		config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("daemon state %s: %s", path, err))
+	}
+	last := state.End
+	config.today = &state.Today
+	if config.shadow != nil {
+		config.shadow.today = &state.Shadow
+	}
+	for {
+		window := nextWindow(last, interval, time.Now())
+		if config.Sessions.Enabled {
+			window = config.Sessions.nextSession(last, time.Now())
+		}
+		wait := time.Until(time.Unix(window.end, 0))
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+		transactions, err := fetchWindow(config, window)
+		var truncated *whalealert.BudgetError
+		if err != nil && !errors.As(err, &truncated) {
+			// the window is tried again after waiting for another interval
+			// so what was fetched is not reported until the whole window is
+			time.Sleep(interval)
+			continue
+		}
+		if len(transactions) > 0 {
+			reportTransactions(config, window, transactions, err)
+		}
+		last = window.end
+		state.End = last
+		if config.preview {
+			// a dry run picks up from the same window as the daemon it previews
+			continue
+		}
+		err = writeDaemonState(path, state)
+		if err != nil {
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("daemon state %s: %s", path, err))
+		}
+	}
+}
+
+// nextWindow continues from the last processed end
+// without one, the first window ends at the next minute
+func nextWindow(last int64, interval time.Duration, now time.Time) timeWindow {
+	if last == 0 {
+		end := now.Truncate(time.Minute).Add(time.Minute)
+		return timeWindow{start: end.Add(-interval).Unix(), end: end.Unix()}
+	}
+	return timeWindow{start: last, end: time.Unix(last, 0).Add(interval).Unix()}
+}
+
+// daemonState is what the daemon remembers across restarts
+type daemonState struct {
+	End    int64         `json:"end"`    //end of the last processed window
+	Today  runningTotals `json:"today"`  //totals of the day so far
+	Shadow runningTotals `json:"shadow"` //totals of the day so far of the shadow config
+}
+
+// readDaemonState has an end of 0 if nothing was processed yet
+func readDaemonState(path string) (daemonState, error) {
+	var state daemonState
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(body, &state)
+	return state, err
+}
+
+// writeDaemonState replaces the file atomically so a crash never leaves it half written
+func writeDaemonState(path string, state daemonState) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, body, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
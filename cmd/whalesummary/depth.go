@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/enzosv/whalesummary/summary"
+)
+
+// DepthConfig is a public order book used to estimate the price impact of exchange inflows
+type DepthConfig struct {
+	URL   string `json:"url"`   //binance compatible depth endpoint like https://api.binance.com/api/v3/depth
+	Quote string `json:"quote"` //quote asset of the pairs. defaults to USDT
+	Limit int    `json:"limit"` //order book levels. defaults to 100
+}
+
+// fetchLiquidity is the usd value of bids in the order book of each symbol
+// symbols without a pair on the exchange are left out
+func fetchLiquidity(config DepthConfig, symbols []string, l *lookups) map[string]float64 {
+	quote := config.Quote
+	if quote == "" {
+		quote = "USDT"
+	}
+	limit := config.Limit
+	if limit < 1 {
+		limit = 100
+	}
+	var mu sync.Mutex
+	liquidity := map[string]float64{}
+	l.each(len(symbols), func(i int) {
+		symbol := symbols[i]
+		params := url.Values{}
+		params.Add("symbol", strings.ToUpper(symbol+quote))
+		params.Add("limit", strconv.Itoa(limit))
+		requestURL := config.URL + "?" + params.Encode()
+		var total float64
+		if !l.get(requestURL, &total) {
+			var book struct {
+				Bids [][2]string `json:"bids"`
+			}
+			err := getJSON(requestURL, &book)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			for _, bid := range book.Bids {
+				price, err := strconv.ParseFloat(bid[0], 64)
+				if err != nil {
+					continue
+				}
+				quantity, err := strconv.ParseFloat(bid[1], 64)
+				if err != nil {
+					continue
+				}
+				total += price * quantity
+			}
+			l.put(requestURL, total)
+		}
+		if total > 0 {
+			mu.Lock()
+			liquidity[symbol] = total
+			mu.Unlock()
+		}
+	})
+	return liquidity
+}
+
+// inflowSymbols are the crypto symbols whose exchange inflow is large enough to report
+func inflowSymbols(windowSummary Summary, threshold float64, config Config) []string {
+	var symbols []string
+	for symbol, value := range windowSummary.Transfers {
+		if value >= threshold && !summary.IsStableCoin(symbol, config.StableCoins) && nonUSDPeg(symbol, config) == "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+const (
+	spotVenue        = "spot"
+	derivativesVenue = "derivatives"
+)
+
+// derivativesExchanges are venues mostly used for futures and options
+var derivativesExchanges = []string{"deribit", "bitmex", "bybit", "phemex"}
+
+// inflow of crypto to a derivatives venue is usually posted as collateral rather than sold
+// so crypto reads neutral while stable coins keep the usual labels
+var derivativesSection = flowSection{name: "derivatives", increase: "Derivatives Exchange Inflow:", decrease: "Derivatives Exchange Outflow:", cryptoLabel: "collateral"}
+
+// venue is whether an exchange entity is spot or derivatives focused
+// the config wins over the built in derivatives exchanges
+func (config Config) venue(entity string) string {
+	entity = strings.ToLower(entity)
+	for exchange, venue := range config.Exchanges {
+		if strings.EqualFold(exchange, entity) {
+			return strings.ToLower(venue)
+		}
+	}
+	if summary.ContainsSymbol(entity, derivativesExchanges) {
+		return derivativesVenue
+	}
+	return spotVenue
+}
+
+// splitDerivatives separates transfers to or from derivatives venues from everything else
+func splitDerivatives(transactions []whalealert.Transaction, config Config) ([]whalealert.Transaction, []whalealert.Transaction) {
+	var derivatives, others []whalealert.Transaction
+	for _, transaction := range transactions {
+		exchange := ""
+		if transaction.To.OwnerType == "exchange" {
+			exchange = transaction.To.Owner
+		}
+		if transaction.From.OwnerType == "exchange" {
+			exchange = transaction.From.Owner
+		}
+		if transaction.TransactionType == whalealert.TRANSFER.String() && exchange != "" && config.venue(exchange) == derivativesVenue {
+			derivatives = append(derivatives, transaction)
+			continue
+		}
+		others = append(others, transaction)
+	}
+	return derivatives, others
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// dryRun writes every message to stdout instead of sending it
+// and prints what would have been written to the log instead of writing it
+func (config Config) dryRun() Config {
+	config.preview = true
+	config.Telegram.Preview = os.Stdout
+	config.Slack.Preview = os.Stdout
+	config.Email.Preview = os.Stdout
+	config.Sheets.Preview = os.Stdout
+	if config.shadow != nil {
+		shadow := config.shadow.dryRun()
+		config.shadow = &shadow
+	}
+	return config
+}
+
+// logs is whether to write to the log
+// a dry run prints the writes it describes instead. a shadow never writes
+func (config Config) logs(describe func() []string) bool {
+	if !config.db.Enabled() || config.readOnly {
+		return false
+	}
+	if !config.preview {
+		return true
+	}
+	for _, line := range describe() {
+		fmt.Println("dry run: would log " + line)
+	}
+	return false
+}
+
+// summaryWrites are the rows of the summaries table a period would have, sorted
+func summaryWrites(window timeWindow, sections map[string]map[string]float64) []string {
+	label := time.Unix(window.start, 0).UTC().Format("Jan 2 15:04") + " - " + time.Unix(window.end, 0).UTC().Format("15:04 UTC")
+	var lines []string
+	for section, flows := range sections {
+		for symbol, value := range flows {
+			lines = append(lines, fmt.Sprintf("summary of %s: %s %s %s", label, section, symbol, strconv.FormatFloat(value, 'f', -1, 64)))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
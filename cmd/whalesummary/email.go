@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/enzosv/whalesummary/notify"
+)
+
+// reportEmail renders the job again in html for the email and as plain text for its alternative
+// and sends it to the job's addresses
+func reportEmail(config Config, job Job, summary Summary, enrichment Enrichment, header headerContext, window timeWindow) error {
+	to := job.EmailTo
+	if len(to) < 1 {
+		to = config.Email.To
+	}
+	if len(to) < 1 {
+		return nil
+	}
+	htmlJob, textJob := job, job
+	htmlJob.Format.ParseMode = "html"
+	textJob.Format.ParseMode = "none"
+	html, err := renderJob(config, htmlJob, summary, enrichment, header, window)
+	if err != nil {
+		return err
+	}
+	text, err := renderJob(config, textJob, summary, enrichment, header, window)
+	if err != nil {
+		return err
+	}
+	subject := config.Email.Subject
+	if subject == "" {
+		subject = "Whale Summary"
+	}
+	if job.Name != "" && job.Name != "default" {
+		subject += " " + job.Name
+	}
+	subject = fmt.Sprintf("%s %s", subject, time.Unix(window.end, 0).UTC().Format("Jan 2 15:04 UTC"))
+	return config.Email.Send(to, subject, text, notify.EmailHTML(html))
+}
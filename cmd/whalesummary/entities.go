@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// ownerEntity is the entity behind a label like binance for "Binance US"
+// unmapped labels are only lowercased so they stay distinct
+func ownerEntity(owner string, entities map[string]string) string {
+	label := strings.ToLower(strings.TrimSpace(owner))
+	if entity, ok := entities[label]; ok {
+		return entity
+	}
+	return label
+}
+
+// normalizeOwners replaces owner labels with their entity so related labels are treated the same
+func normalizeOwners(transactions []whalealert.Transaction, entities map[string]string) []whalealert.Transaction {
+	normalized := make([]whalealert.Transaction, len(transactions))
+	for i, transaction := range transactions {
+		transaction.From.Owner = ownerEntity(transaction.From.Owner, entities)
+		transaction.To.Owner = ownerEntity(transaction.To.Owner, entities)
+		normalized[i] = transaction
+	}
+	return normalized
+}
+
+// loadEntities merges the entity map in the log with the config
+// the config wins when both map the same label
+func loadEntities(ctx context.Context, db store.DB, configured map[string]string) (map[string]string, error) {
+	entities, err := store.FetchEntities(ctx, db)
+	if entities == nil {
+		entities = map[string]string{}
+	}
+	for label, entity := range configured {
+		entities[strings.ToLower(strings.TrimSpace(label))] = entity
+	}
+	return entities, err
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts environment variables that set any config field by its json path
+// like WHALESUMMARY_WHALE_ALERT_MIN or WHALESUMMARY_JOBS_0_THRESHOLD
+const envPrefix = "WHALESUMMARY_"
+
+// envAliases are shorter names for the settings containers set most
+// a WHALESUMMARY_ variable of the same field wins
+var envAliases = map[string]string{
+	"WHALE_API_KEY":     "whale_alert_api_key",
+	"WHALE_MIN":         "whale_alert_min",
+	"TG_BOT_ID":         "telegram_bot_id",
+	"TG_RECIPIENT_ID":   "telegram_recipient_id",
+	"TG_LOG_ID":         "telegram_log_id",
+	"LOG_DB_URL":        "log_db_url",
+	"COINGECKO_API_KEY": "coingecko_api_key",
+	"SLACK_WEBHOOK_URL": "slack_webhook_url",
+	"SLACK_BOT_TOKEN":   "slack_bot_token",
+	"SMTP_PASSWORD":     "email_password",
+}
+
+// configEnv is the config path and value of every setting in the environment
+// aliases first so the prefixed variables are applied after them
+func configEnv(environ []string) [][3]string {
+	var aliased, prefixed [][3]string
+	for _, variable := range environ {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		if path, ok := envAliases[parts[0]]; ok {
+			aliased = append(aliased, [3]string{parts[0], path, parts[1]})
+		} else if strings.HasPrefix(parts[0], envPrefix) && len(parts[0]) > len(envPrefix) {
+			prefixed = append(prefixed, [3]string{parts[0], strings.ToLower(strings.TrimPrefix(parts[0], envPrefix)), parts[1]})
+		}
+	}
+	return append(aliased, prefixed...)
+}
+
+// overrideConfig sets the config fields named by the environment
+func overrideConfig(config *Config, environ []string) error {
+	for _, setting := range configEnv(environ) {
+		err := setField(reflect.ValueOf(config).Elem(), strings.Split(setting[1], "_"), setting[2])
+		if err != nil {
+			return fmt.Errorf("%s: %w", setting[0], err)
+		}
+	}
+	return nil
+}
+
+// setField follows the words of a json path into v and sets what it reaches
+// a field is the most words that join into its json name since names have underscores too
+func setField(v reflect.Value, words []string, value string) error {
+	if len(words) < 1 {
+		return setValue(v, value)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setField(v.Elem(), words, value)
+	case reflect.Struct:
+		for n := len(words); n > 0; n-- {
+			field, ok := jsonField(v, strings.Join(words[:n], "_"))
+			if ok {
+				return setField(field, words[n:], value)
+			}
+		}
+		return fmt.Errorf("no setting %s", strings.Join(words, "_"))
+	case reflect.Slice:
+		i, err := strconv.Atoi(words[0])
+		if err != nil || i < 0 || i >= v.Len() {
+			return fmt.Errorf("no item %s among %d", words[0], v.Len())
+		}
+		return setField(v.Index(i), words[1:], value)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || !scalar(v.Type().Elem().Kind()) {
+			return fmt.Errorf("set the whole %s as json", v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		// keys like binance us can't be spelled this way and need the whole map as json
+		elem := reflect.New(v.Type().Elem()).Elem()
+		err := setValue(elem, value)
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(strings.Join(words, "_")).Convert(v.Type().Key()), elem)
+		return nil
+	}
+	return fmt.Errorf("%s has no setting %s", v.Type(), strings.Join(words, "_"))
+}
+
+// jsonField is the exported field of a struct with the json name
+// fields of embedded structs count as the struct's own like encoding/json does
+func jsonField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if tag == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if embedded, ok := jsonField(v.Field(i), name); ok {
+				return embedded, true
+			}
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func scalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setValue parses the text of an environment variable as the kind of v
+// lists of strings may be comma separated and anything else is json
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items).Convert(v.Type()))
+			return nil
+		}
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverrideConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   []string
+		get   func(Config) interface{}
+		want  interface{}
+		error string
+	}{
+		{"nested struct", []string{"WHALESUMMARY_WHALE_ALERT_MIN=1000000"},
+			func(c Config) interface{} { return c.WhaleAlert.Min }, "1000000", ""},
+		{"field of an embedded struct", []string{"WHALESUMMARY_TELEGRAM_BOT_ID=123:abc"},
+			func(c Config) interface{} { return c.Telegram.BotID }, "123:abc", ""},
+		{"alias", []string{"TG_BOT_ID=123:abc"},
+			func(c Config) interface{} { return c.Telegram.BotID }, "123:abc", ""},
+		{"prefixed wins over alias", []string{"WHALESUMMARY_TELEGRAM_BOT_ID=456:def", "TG_BOT_ID=123:abc"},
+			func(c Config) interface{} { return c.Telegram.BotID }, "456:def", ""},
+		{"name with underscores", []string{"WHALESUMMARY_LOG_DB_URL=postgres://x"},
+			func(c Config) interface{} { return c.LogDBURL }, "postgres://x", ""},
+		{"item of a slice", []string{"WHALESUMMARY_JOBS_0_THRESHOLD=5000000"},
+			func(c Config) interface{} { return c.Jobs[0].Threshold }, 5000000.0, ""},
+		{"comma separated list", []string{"WHALESUMMARY_STABLE_COINS=usdt, usdc"},
+			func(c Config) interface{} { return c.StableCoins }, []string{"usdt", "usdc"}, ""},
+		{"json list", []string{`WHALESUMMARY_JOBS_0_SYMBOLS=["btc","eth"]`},
+			func(c Config) interface{} { return c.Jobs[0].Symbols }, []string{"btc", "eth"}, ""},
+		{"map entry", []string{"WHALESUMMARY_EXPLORERS_ETHEREUM=https://etherscan.io/tx/%s"},
+			func(c Config) interface{} { return c.Explorers["ethereum"] }, "https://etherscan.io/tx/%s", ""},
+		{"whole map as json", []string{`WHALESUMMARY_ENTITIES={"binance us": "binance"}`},
+			func(c Config) interface{} { return c.Entities["binance us"] }, "binance", ""},
+		{"unknown setting", []string{"WHALESUMMARY_TELEGRAM_NOPE=1"}, nil, nil, "no setting nope"},
+		{"item past the slice", []string{"WHALESUMMARY_JOBS_3_THRESHOLD=1"}, nil, nil, "no item 3 among 1"},
+		{"invalid value", []string{"WHALESUMMARY_JOBS_0_THRESHOLD=lots"}, nil, nil, "invalid syntax"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Config{Jobs: []Job{{Name: "main"}}}
+			err := overrideConfig(&config, append([]string{"HOME=/root"}, test.env...))
+			if test.error != "" {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Fatalf("error %v, want %s", err, test.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := test.get(config); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// classification is where a transaction ended up in the summary and why
+type classification struct {
+	reason   string
+	analyzer string
+	// net flow of the job the threshold is compared to. nil if nothing is reported for it
+	value func(Summary) float64
+}
+
+// runExplain prints how every transaction of the window with the hash was classified
+// for when users dispute a label
+func runExplain(config Config, window timeWindow, hash string) error {
+	transactions, err := whalealert.FetchTransactions(config.WhaleAlert, window.start, window.end)
+	if err != nil {
+		// the transaction may still be in what was fetched
+		fmt.Println(err)
+	}
+	transactions, _ = normalizeAmounts(transactions, newTokenRegistry(config.Tokens))
+	transactions, _ = summary.Dedupe(transactions)
+	raw := transactions
+	entities, err := loadEntities(context.Background(), config.db, config.Entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+	transactions = config.overrideOwners(normalizeOwners(transactions, entities))
+	config.flagged, _ = loadFlagged(config.Flagged, config.Watchlist)
+	config.StableCoins, err = withCategoryStableCoins(config.CoinGecko, config.StableCoins, time.Now())
+	if err != nil {
+		fmt.Println(err)
+	}
+	r := newRenderer(Job{Name: "explain", Format: Format{ParseMode: "none"}}, Enrichment{}, config)
+	lines := r.explainLines(raw, transactions, hash)
+	if len(lines) < 1 {
+		return fmt.Errorf("no transaction %s in the %d transactions of the window", hash, len(transactions))
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+	return nil
+}
+
+// explainLines traces each transaction with the hash through the steps of summarize then the threshold of each job
+// raw is the same transactions before owners were normalized
+func (r renderer) explainLines(raw, transactions []whalealert.Transaction, hash string) []string {
+	config := r.config
+	var msg []string
+	for i, transaction := range transactions {
+		if !strings.EqualFold(transaction.Hash, hash) {
+			continue
+		}
+		key := summary.Key(transaction)
+		msg = append(msg, r.p.Sprintf("%s on %s: %s %s %s from %s to %s", transaction.Hash, transaction.Blockchain,
+			r.amount(transaction.AmountUsd), strings.ToUpper(transaction.Symbol), transaction.TransactionType,
+			explainWallet(raw[i].From, transaction.From), explainWallet(raw[i].To, transaction.To)))
+		reclassified := summary.Reclassify([]whalealert.Transaction{transaction}, config.Unhandled)[0]
+		if reclassified.TransactionType != transaction.TransactionType {
+			msg = append(msg, fmt.Sprintf("  type: %s treated as %s by the unhandled policy", transaction.TransactionType, reclassified.TransactionType))
+		}
+		symbol := summary.RemapSymbol(transaction.Symbol, config.Remap)
+		if symbol != transaction.Symbol {
+			msg = append(msg, fmt.Sprintf("  symbol: %s remapped to %s", transaction.Symbol, symbol))
+		}
+		if matches := matchFlagged([]whalealert.Transaction{transaction}, config.flagged); len(matches) > 0 {
+			list := "flagged"
+			if matches[0].Flag.watchlist {
+				list = "watchlist"
+			}
+			msg = append(msg, "  "+list+": "+matches[0].Flag.Label)
+		}
+		if matches := matchWatched([]whalealert.Transaction{transaction}, config.Watch.watchList()); len(matches) > 0 {
+			msg = append(msg, fmt.Sprintf("  watched: %s (%s)", matches[0].Entity, matches[0].Category))
+		}
+		classified := classify(key, summary.Reclassify(transactions, config.Unhandled), config)
+		msg = append(msg, "  "+classified.reason)
+		for _, job := range config.jobs() {
+			msg = append(msg, "  "+r.explainJob(job.adapt(transactions, config.Remap), key, transactions, classified))
+		}
+	}
+	return msg
+}
+
+// explainJob is whether the job reports the net flow the transaction is part of
+func (r renderer) explainJob(job Job, key string, transactions []whalealert.Transaction, classified classification) string {
+	prefix := "job " + job.Name + ": "
+	if job.filters() {
+		transactions = job.filter(transactions, r.config.Remap)
+		if !containsTransaction(transactions, key) {
+			return prefix + "left out by its blockchains, symbols, min_usd, or mode"
+		}
+		// pairing depends on what else the job kept
+		if jobClassified := classify(key, summary.Reclassify(transactions, r.config.Unhandled), r.config); jobClassified.reason != classified.reason {
+			prefix += jobClassified.reason + ". "
+			classified = jobClassified
+		}
+	}
+	if classified.value == nil {
+		return prefix + "not reported"
+	}
+	if !job.analyzes(classified.analyzer) {
+		return prefix + classified.analyzer + " is not analyzed"
+	}
+	jobSummary, _ := summarize(transactions, r.config)
+	value := classified.value(jobSummary)
+	if math.Abs(value) < job.threshold() {
+		return prefix + r.p.Sprintf("net %s is below the %s threshold. not reported", r.signed(value), r.amount(job.threshold()))
+	}
+	return prefix + r.p.Sprintf("net %s meets the %s threshold. reported", r.signed(value), r.amount(job.threshold()))
+}
+
+// classify follows the order of summarize to find the first step that took the transaction with the key
+// transactions are the whole reclassified window since pairing depends on the rest
+func classify(key string, transactions []whalealert.Transaction, config Config) classification {
+	var transaction whalealert.Transaction
+	for _, t := range transactions {
+		if summary.Key(t) == key {
+			transaction = t
+		}
+	}
+	symbol := summary.RemapSymbol(transaction.Symbol, config.Remap)
+	sectionValue := func(name string) func(Summary) float64 {
+		return func(s Summary) float64 {
+			return s.sections()[name][symbol]
+		}
+	}
+	_, transactions = summary.MatchBridges(transactions, config.Remap)
+	if !containsTransaction(transactions, key) {
+		return classification{
+			reason:   "bridge: paired with a " + pairedType(transaction) + " of the same amount on another blockchain",
+			analyzer: bridgeAnalyzer,
+			value: func(s Summary) float64 {
+				total := 0.0
+				for flow, value := range s.Bridges {
+					if flow.Symbol == symbol && (flow.From == transaction.Blockchain || flow.To == transaction.Blockchain) {
+						total += value
+					}
+				}
+				return total
+			},
+		}
+	}
+	_, transactions = summary.MatchRotations(transactions, config.Remap, config.StableCoins)
+	if !containsTransaction(transactions, key) {
+		return classification{
+			reason:   "rotation: paired with a " + pairedType(transaction) + " of another stable coin by the same holder",
+			analyzer: rotationAnalyzer,
+			value: func(s Summary) float64 {
+				total := 0.0
+				for rotation, value := range s.Rotations {
+					if rotation.From == symbol || rotation.To == symbol {
+						total += value
+					}
+				}
+				return total
+			},
+		}
+	}
+	_, _, transactions = custodyFlows(transactions, config)
+	if !containsTransaction(transactions, key) {
+		from := config.ownerCategory(transaction.From.Owner)
+		to := config.ownerCategory(transaction.To.Owner)
+		if from == to {
+			return classification{reason: fmt.Sprintf("%s: internal between %s wallets. ignored", from, from)}
+		}
+		if to == stakingCategory || to == custodyCategory {
+			return classification{reason: fmt.Sprintf("%s: inflow to %s", to, transaction.To.Owner), analyzer: to, value: sectionValue(to)}
+		}
+		return classification{reason: fmt.Sprintf("%s: outflow from %s", from, transaction.From.Owner), analyzer: from, value: sectionValue(from)}
+	}
+	_, transactions = ownerTypeFlows(transactions, config)
+	if !containsTransaction(transactions, key) {
+		from := strings.ToLower(transaction.From.OwnerType)
+		to := strings.ToLower(transaction.To.OwnerType)
+		if from == to {
+			return classification{reason: fmt.Sprintf("owner type %s: internal. ignored", from)}
+		}
+		if _, ok := config.ownerType(to); ok {
+			return classification{reason: fmt.Sprintf("owner type %s: inflow", to), analyzer: transferSection.name, value: sectionValue(to)}
+		}
+		return classification{reason: fmt.Sprintf("owner type %s: outflow", from), analyzer: transferSection.name, value: sectionValue(from)}
+	}
+	derivatives, _ := splitDerivatives([]whalealert.Transaction{transaction}, config)
+	section, analyzer := transferSection, transferSection.name
+	venue := ""
+	if len(derivatives) > 0 {
+		section, analyzer = derivativesSection, derivativesSection.name
+		venue = " of a derivatives venue"
+	}
+	switch transaction.TransactionType {
+	case whalealert.MINT.String():
+		return classification{reason: "supply: mint", analyzer: supplySection.name, value: sectionValue(supplySection.name)}
+	case whalealert.BURN.String():
+		return classification{reason: "supply: burn", analyzer: supplySection.name, value: sectionValue(supplySection.name)}
+	case whalealert.LOCK.String(), whalealert.UNLOCK.String():
+		return classification{reason: "locks: " + transaction.TransactionType, analyzer: lockSection.name, value: sectionValue(lockSection.name)}
+	case whalealert.TRANSFER.String():
+	default:
+		return classification{reason: "unhandled: " + transaction.TransactionType + " is listed in the log chat unless its policy is ignore"}
+	}
+	switch {
+	case transaction.From.OwnerType == transaction.To.OwnerType:
+		return classification{reason: fmt.Sprintf("%s: internal between %s wallets. ignored", section.name, ownerTypeName(transaction.From.OwnerType))}
+	case transaction.From.OwnerType == "exchange":
+		return classification{reason: section.name + ": outflow" + venue, analyzer: analyzer, value: sectionValue(section.name)}
+	case transaction.To.OwnerType == "exchange":
+		return classification{reason: section.name + ": inflow" + venue, analyzer: analyzer, value: sectionValue(section.name)}
+	}
+	return classification{reason: "transfers: neither side is an exchange. ignored"}
+}
+
+// explainWallet is the owner and owner type of a wallet with what whale alert reported if it was normalized or overridden
+func explainWallet(raw, normalized whalealert.Wallet) string {
+	owner := normalized.Owner
+	if owner == "" {
+		owner = strings.TrimSpace("unknown " + normalized.Address)
+	}
+	if raw.Owner != normalized.Owner {
+		owner += fmt.Sprintf(" (labeled %s)", raw.Owner)
+	}
+	ownerType := ownerTypeName(normalized.OwnerType)
+	if !strings.EqualFold(raw.OwnerType, normalized.OwnerType) {
+		ownerType += " overriding " + ownerTypeName(raw.OwnerType)
+	}
+	return fmt.Sprintf("%s [%s]", owner, ownerType)
+}
+
+func ownerTypeName(ownerType string) string {
+	if ownerType == "" {
+		return "unknown"
+	}
+	return ownerType
+}
+
+// pairedType is the transaction type a bridge or rotation pairs the transaction with
+func pairedType(transaction whalealert.Transaction) string {
+	switch transaction.TransactionType {
+	case whalealert.LOCK.String():
+		return whalealert.UNLOCK.String()
+	case whalealert.UNLOCK.String():
+		return whalealert.LOCK.String()
+	case whalealert.MINT.String():
+		return whalealert.BURN.String()
+	}
+	return whalealert.MINT.String()
+}
+
+func containsTransaction(transactions []whalealert.Transaction, key string) bool {
+	for _, transaction := range transactions {
+		if summary.Key(transaction) == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// explorers are transaction pages per blockchain as named by whale alert
+// the config adds to them with explorers
+var explorers = map[string]string{
+	"bitcoin":   "https://mempool.space/tx/%s",
+	"ethereum":  "https://etherscan.io/tx/%s",
+	"tron":      "https://tronscan.org/#/transaction/%s",
+	"solana":    "https://solscan.io/tx/%s",
+	"ripple":    "https://xrpscan.com/tx/%s",
+	"binance":   "https://bscscan.com/tx/%s",
+	"polygon":   "https://polygonscan.com/tx/%s",
+	"avalanche": "https://snowtrace.io/tx/%s",
+	"litecoin":  "https://blockchair.com/litecoin/transaction/%s",
+	"dogecoin":  "https://blockchair.com/dogecoin/transaction/%s",
+}
+
+// explorerURL is the page of the transaction hash. empty if the blockchain has no known explorer
+func (config Config) explorerURL(blockchain, hash string) string {
+	blockchain = strings.ToLower(blockchain)
+	explorer, ok := config.Explorers[blockchain]
+	if !ok {
+		explorer = explorers[blockchain]
+	}
+	if explorer == "" || hash == "" {
+		return ""
+	}
+	return fmt.Sprintf(explorer, hash)
+}
+
+func (r renderer) link(text, url string) string {
+	if url == "" {
+		return r.escape(text)
+	}
+	switch r.format.markup() {
+	case "HTML":
+		return `<a href="` + html.EscapeString(url) + `">` + html.EscapeString(text) + "</a>"
+	case "markdown":
+		return "[" + r.escape(text) + "](" + url + ")"
+	}
+	return text + " " + url
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+var exportHeader = []string{"time", "timestamp", "blockchain", "hash", "symbol", "type",
+	"from_address", "from_owner", "from_owner_type", "to_address", "to_owner", "to_owner_type", "amount", "amount_usd"}
+
+// exportTransactions appends the transactions to a csv file for spreadsheets
+// the header is only written to a new file so the daemon and stream keep adding to the same one
+// owners are as whale alert labeled them
+func exportTransactions(path string, transactions []whalealert.Transaction) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	if info.Size() == 0 {
+		w.Write(exportHeader)
+	}
+	for _, transaction := range transactions {
+		w.Write([]string{
+			time.Unix(int64(transaction.Timestamp), 0).UTC().Format(time.RFC3339),
+			strconv.Itoa(transaction.Timestamp),
+			transaction.Blockchain,
+			transaction.Hash,
+			transaction.Symbol,
+			transaction.TransactionType,
+			transaction.From.Address,
+			transaction.From.Owner,
+			transaction.From.OwnerType,
+			transaction.To.Address,
+			transaction.To.Owner,
+			transaction.To.OwnerType,
+			strconv.FormatFloat(transaction.Amount, 'f', -1, 64),
+			strconv.FormatFloat(transaction.AmountUsd, 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+var sheetHeader = []string{"period_start", "period_end", "section", "symbol", "amount_usd"}
+
+// sheetRows are the rows of the summaries table of a period for a google sheet, sorted
+// times are utc in a format sheets reads as a date
+func sheetRows(window timeWindow, sections map[string]map[string]float64) [][]interface{} {
+	start := time.Unix(window.start, 0).UTC().Format("2006-01-02 15:04:05")
+	end := time.Unix(window.end, 0).UTC().Format("2006-01-02 15:04:05")
+	var rows [][]interface{}
+	for section, flows := range sections {
+		for symbol, value := range flows {
+			rows = append(rows, []interface{}{start, end, section, symbol, value})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i][2] != rows[j][2] {
+			return rows[i][2].(string) < rows[j][2].(string)
+		}
+		return rows[i][3].(string) < rows[j][3].(string)
+	})
+	return rows
+}
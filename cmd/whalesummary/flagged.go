@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// FlaggedAddress is an address whose movements always deserve attention
+type FlaggedAddress struct {
+	Address  string `json:"address"`
+	Label    string `json:"label"`    //like ronin bridge exploiter
+	Category string `json:"category"` //like hack or sanctioned
+	// followed rather than suspicious. alerted and listed apart from flagged addresses
+	watchlist bool
+}
+
+type FlaggedConfig struct {
+	Addresses   []FlaggedAddress `json:"addresses"`
+	Lists       []string         `json:"lists"`        //paths or urls of csv files with address,label,category rows
+	RecipientID string           `json:"recipient_id"` //chat for immediate alerts. defaults to telegram.recipient_id
+}
+
+// flaggedTransaction is a transaction that touched a flagged address
+type flaggedTransaction struct {
+	Transaction whalealert.Transaction
+	Flag        FlaggedAddress
+}
+
+const flaggedAnalyzer = "flagged"
+
+// loadFlagged indexes the watchlist then configured and imported addresses by lowercase address
+// an address that is both is treated as flagged
+// unreadable lists are reported but don't stop the others from loading
+func loadFlagged(config FlaggedConfig, watchlist []FlaggedAddress) (map[string]FlaggedAddress, []error) {
+	flagged := map[string]FlaggedAddress{}
+	for _, address := range watchlist {
+		address.watchlist = true
+		flagged[strings.ToLower(address.Address)] = address
+	}
+	var errs []error
+	for _, list := range config.Lists {
+		addresses, err := importFlagged(list)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("flagged list %s: %w", list, err))
+		}
+		for _, address := range addresses {
+			flagged[strings.ToLower(address.Address)] = address
+		}
+	}
+	for _, address := range config.Addresses {
+		flagged[strings.ToLower(address.Address)] = address
+	}
+	return flagged, errs
+}
+
+// openList reads a path or an http url
+func openList(list string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(list, "http://") && !strings.HasPrefix(list, "https://") {
+		return os.Open(list)
+	}
+	res, err := http.Get(list)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("returned %s", res.Status)
+	}
+	return res.Body, nil
+}
+
+// csvRows reads rows of any length skipping # comments
+func csvRows(reader io.Reader) *csv.Reader {
+	rows := csv.NewReader(reader)
+	rows.FieldsPerRecord = -1
+	rows.Comment = '#'
+	return rows
+}
+
+func importFlagged(list string) ([]FlaggedAddress, error) {
+	reader, err := openList(list)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	rows := csvRows(reader)
+	var addresses []FlaggedAddress
+	for {
+		row, err := rows.Read()
+		if err == io.EOF {
+			return addresses, nil
+		}
+		if err != nil {
+			return addresses, err
+		}
+		address := FlaggedAddress{Address: strings.TrimSpace(row[0])}
+		if len(row) > 1 {
+			address.Label = strings.TrimSpace(row[1])
+		}
+		if len(row) > 2 {
+			address.Category = strings.TrimSpace(row[2])
+		}
+		if address.Address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+}
+
+// matchFlagged finds the transactions that touched a flagged address
+func matchFlagged(transactions []whalealert.Transaction, flagged map[string]FlaggedAddress) []flaggedTransaction {
+	var matches []flaggedTransaction
+	for _, transaction := range transactions {
+		for _, address := range []string{transaction.From.Address, transaction.To.Address} {
+			if flag, ok := flagged[strings.ToLower(address)]; ok && address != "" {
+				matches = append(matches, flaggedTransaction{Transaction: transaction, Flag: flag})
+				break
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Transaction.AmountUsd > matches[j].Transaction.AmountUsd
+	})
+	return matches
+}
+
+// flaggedSections are the distinct sections for flagged and watchlist movements regardless of threshold
+func (r renderer) flaggedSections(matches []flaggedTransaction) []summary.Section {
+	if !r.job.analyzes(flaggedAnalyzer) || len(matches) < 1 {
+		return nil
+	}
+	flagged, watched := splitWatchlist(matches)
+	var sections []summary.Section
+	for _, group := range []struct {
+		name    string
+		title   string
+		matches []flaggedTransaction
+	}{{flaggedAnalyzer, "⚠️ Flagged Addresses:", flagged}, {"watchlist", "👀 Watchlist:", watched}} {
+		if len(group.matches) < 1 {
+			continue
+		}
+		section := summary.Section{Name: group.name, Title: group.title}
+		for _, match := range group.matches {
+			section.Lines = append(section.Lines, summary.Line{
+				Text:   r.flaggedLine(match),
+				Indent: 2,
+				Group:  match.Flag.Label,
+				Symbol: strings.ToLower(match.Transaction.Symbol),
+				Value:  match.Transaction.AmountUsd,
+			})
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// splitWatchlist separates movements of flagged addresses from those of the watchlist
+func splitWatchlist(matches []flaggedTransaction) ([]flaggedTransaction, []flaggedTransaction) {
+	var flagged, watched []flaggedTransaction
+	for _, match := range matches {
+		if match.Flag.watchlist {
+			watched = append(watched, match)
+			continue
+		}
+		flagged = append(flagged, match)
+	}
+	return flagged, watched
+}
+
+func (r renderer) flaggedLine(match flaggedTransaction) string {
+	transaction := match.Transaction
+	direction := "from"
+	if strings.EqualFold(transaction.To.Address, match.Flag.Address) {
+		direction = "to"
+	}
+	label := match.Flag.Label
+	if label == "" {
+		label = shortMover(match.Flag.Address)
+	}
+	if match.Flag.Category != "" {
+		label += " (" + match.Flag.Category + ")"
+	}
+	return r.p.Sprintf("%s %s %s: %s %s",
+		strings.ToUpper(transaction.Symbol), direction, r.escape(label), r.amount(transaction.AmountUsd), transaction.Blockchain)
+}
+
+// alertFlagged sends every flagged movement right away instead of waiting for the summary
+func alertFlagged(config Config, matches []flaggedTransaction) {
+	if len(matches) < 1 {
+		return
+	}
+	recipient := config.Flagged.RecipientID
+	if recipient == "" {
+		recipient = config.Telegram.RecipientID
+	}
+	r := newRenderer(Job{Name: "flagged", Format: config.recipientFormat(config.Telegram.Format, recipient)}, Enrichment{}, config)
+	flagged, watched := splitWatchlist(matches)
+	// a watched whale moving is news but not an incident so it gets its own message
+	for _, group := range []struct {
+		title   string
+		matches []flaggedTransaction
+	}{{"🚨 " + r.bold("Flagged address activity"), flagged}, {"👀 " + r.bold("Watchlist activity"), watched}} {
+		if len(group.matches) < 1 {
+			continue
+		}
+		msg := []string{group.title}
+		for _, match := range group.matches {
+			msg = append(msg, "  "+r.flaggedLine(match))
+		}
+		config.Telegram.SendFormatted(recipient, strings.Join(msg, "\n"), r.format.telegramParseMode())
+	}
+}
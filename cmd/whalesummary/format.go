@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Format controls how the analysis is rendered for a recipient
+type Format struct {
+	Decimals   *int    `json:"decimals"`   //decimal places. defaults to 2
+	Separator  *string `json:"separator"`  //thousands separator. defaults to the locale's
+	Rounding   string  `json:"rounding"`   //nearest, down, or up. defaults to nearest
+	Abbreviate *bool   `json:"abbreviate"` //render as millions or billions. defaults to true
+	Template   string  `json:"template"`   //text/template with .Job .Start .End .Header and .Analysis
+	ParseMode  string  `json:"parse_mode"` //markdown, markdownv2, html, or none. defaults to markdown
+	Locale     string  `json:"locale"`     //BCP 47 tag for number formatting. defaults to en
+	Currency   string  `json:"currency"`   //fiat currency to convert usd values into. defaults to usd
+	Quote      string  `json:"quote"`      //btc or eth to express flows in at the average price of the window instead. optional
+}
+
+const defaultTemplate = "{{if .Header}}{{.Header}}\n\n{{end}}{{.Analysis}}"
+
+// messageData is what a Format's template can use
+type messageData struct {
+	Job      string
+	Start    time.Time
+	End      time.Time
+	Header   string
+	Analysis string
+}
+
+var currencySymbols = map[string]string{
+	"usd": "$",
+	"eur": "€",
+	"gbp": "£",
+	"jpy": "¥",
+	"krw": "₩",
+	"php": "₱",
+}
+
+func (f Format) printer() *message.Printer {
+	if f.Locale == "" {
+		return message.NewPrinter(language.English)
+	}
+	return message.NewPrinter(language.Make(f.Locale))
+}
+
+func (f Format) currency() string {
+	if f.Currency == "" {
+		return "usd"
+	}
+	return strings.ToLower(f.Currency)
+}
+
+// telegramParseMode is the value telegram expects. empty for plain text
+func (f Format) telegramParseMode() string {
+	switch strings.ToLower(f.ParseMode) {
+	case "html":
+		return "HTML"
+	case "markdownv2":
+		return "MarkdownV2"
+	case "none", slackParseMode:
+		return ""
+	}
+	return "markdown"
+}
+
+// markup is what the renderer writes. HTML, markdown, slack, or empty for plain text
+// markdownv2 is written as markdown and escaped when sent
+func (f Format) markup() string {
+	switch mode := f.telegramParseMode(); {
+	case strings.EqualFold(f.ParseMode, slackParseMode):
+		return slackParseMode
+	case mode == "MarkdownV2":
+		return "markdown"
+	default:
+		return mode
+	}
+}
+
+func (f Format) template() (*template.Template, error) {
+	text := f.Template
+	if text == "" {
+		text = defaultTemplate
+	}
+	return template.New("message").Parse(text)
+}
+
+// renderMessage places the header and analysis into the template of the job
+func (r renderer) renderMessage(header headerContext, analysis string, window timeWindow) (string, error) {
+	tmpl, err := r.format.template()
+	if err != nil {
+		return "", err
+	}
+	lines := r.headerLines(header)
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = r.escape(line)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, messageData{
+		Job:      r.job.Name,
+		Start:    time.Unix(window.start, 0),
+		End:      time.Unix(window.end, 0),
+		Header:   strings.Join(escaped, "\n"),
+		Analysis: analysis,
+	})
+	return buf.String(), err
+}
+
+// markdownEscaper escapes what legacy markdown reserves outside of entities
+var markdownEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+
+// escape keeps text like owner names from being read as formatting
+func (r renderer) escape(text string) string {
+	switch r.format.markup() {
+	case "HTML":
+		return html.EscapeString(text)
+	case "markdown":
+		return markdownEscaper.Replace(text)
+	}
+	return text
+}
+
+// code is monospaced text like the ticker column
+func (r renderer) code(text string) string {
+	switch r.format.markup() {
+	case "HTML":
+		return "<code>" + html.EscapeString(text) + "</code>"
+	case "markdown", slackParseMode:
+		return "`" + strings.ReplaceAll(text, "`", "'") + "`"
+	}
+	return text
+}
+
+// bold is a title like the heading of an alert. text is escaped
+func (r renderer) bold(text string) string {
+	switch r.format.markup() {
+	case "HTML":
+		return "<b>" + html.EscapeString(text) + "</b>"
+	case "markdown", slackParseMode:
+		return "*" + r.escape(text) + "*"
+	}
+	return text
+}
+
+// italic is an aside like a category or owner name. text is escaped
+func (r renderer) italic(text string) string {
+	switch r.format.markup() {
+	case "HTML":
+		return "<i>" + html.EscapeString(text) + "</i>"
+	case "markdown", slackParseMode:
+		return "_" + r.escape(text) + "_"
+	}
+	return text
+}
+
+// amount converts an absolute usd value into the recipient's currency
+func (r renderer) amount(usd float64) string {
+	if quote := r.format.quote(); quote != "" {
+		if price := r.enrichment.QuotePrices[quote]; price > 0 {
+			// like ₿1,945.12. too small to abbreviate into millions
+			return quoteAssets[quote].symbol + r.digits(usd/price)
+		}
+	}
+	currency := r.format.currency()
+	rate, ok := r.enrichment.Rates[currency]
+	if currency == "usd" || !ok {
+		// without a rate it's better to show usd than a wrong number
+		return "$" + r.number(usd)
+	}
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = strings.ToUpper(currency) + " "
+	}
+	return symbol + r.number(usd*rate)
+}
+
+// number renders an absolute value according to the recipient's format
+// abbreviated into millions or billions unless disabled
+func (r renderer) number(abs float64) string {
+	value, unit := abs, ""
+	if r.format.Abbreviate == nil || *r.format.Abbreviate {
+		value, unit = abs/1000000, "M"
+		if abs >= 1000000000 {
+			value, unit = abs/1000000000, "B"
+		}
+	}
+	return r.digits(value) + unit
+}
+
+// digits renders a value with the decimals, rounding, and separator of the recipient's format
+func (r renderer) digits(value float64) string {
+	decimals := 2
+	if r.format.Decimals != nil {
+		decimals = *r.format.Decimals
+	}
+	scale := math.Pow10(decimals)
+	switch r.format.Rounding {
+	case "down":
+		value = math.Floor(value*scale) / scale
+	case "up":
+		value = math.Ceil(value*scale) / scale
+	default:
+		value = math.Round(value*scale) / scale
+	}
+	if r.format.Separator == nil {
+		// locale separators
+		return r.p.Sprintf("%.*f", decimals, value)
+	}
+	return groupThousands(strconv.FormatFloat(value, 'f', decimals, 64), *r.format.Separator)
+}
+
+// groupThousands inserts the separator between every three digits of the whole part
+func groupThousands(number, separator string) string {
+	whole, fraction := number, ""
+	if i := strings.Index(number, "."); i >= 0 {
+		whole, fraction = number[:i], number[i:]
+	}
+	var grouped []string
+	for len(whole) > 3 {
+		grouped = append([]string{whole[len(whole)-3:]}, grouped...)
+		whole = whole[:len(whole)-3]
+	}
+	grouped = append([]string{whole}, grouped...)
+	return strings.Join(grouped, separator) + fraction
+}
+
+// fetchExchangeRates is how much of each currency one usd is worth
+func fetchExchangeRates(config CoinGeckoConfig) (map[string]float64, error) {
+	var response struct {
+		// relative to btc
+		Rates map[string]struct {
+			Value float64 `json:"value"`
+		} `json:"rates"`
+	}
+	err := getCoinGecko(config, "/exchange_rates", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	usd := response.Rates["usd"].Value
+	if usd == 0 {
+		return nil, fmt.Errorf("no usd exchange rate")
+	}
+	rates := map[string]float64{}
+	for currency, rate := range response.Rates {
+		rates[currency] = rate.Value / usd
+	}
+	return rates, nil
+}
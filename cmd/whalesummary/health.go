@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthConfig is when /healthz reports an instance as unhealthy
+type HealthConfig struct {
+	// time without a successful fetch like 3h. defaults to 3 intervals for -daemon and -stream
+	// schedules are only checked with it set since they may not summarize often
+	Stale string `json:"stale"`
+}
+
+// stale is how long without a fetch is unhealthy. 0 never is
+func (config HealthConfig) stale(interval time.Duration, fetches bool) time.Duration {
+	stale, err := time.ParseDuration(config.Stale)
+	if err == nil && stale > 0 {
+		return stale
+	}
+	if fetches {
+		return 3 * interval
+	}
+	return 0
+}
+
+// healthState is when a long running instance last fetched from whale alert and delivered to telegram
+// served on /healthz so orchestrators can restart an instance that stopped doing either
+type healthState struct {
+	mu        sync.Mutex
+	started   time.Time
+	fetched   time.Time
+	delivered time.Time
+	stale     time.Duration // how long without a fetch is unhealthy. 0 for instances that don't fetch
+}
+
+// health is the json of /healthz. times are unix seconds and 0 for never
+type health struct {
+	OK           bool   `json:"ok"`
+	Started      int64  `json:"started"`
+	LastFetch    int64  `json:"last_fetch"`
+	LastDelivery int64  `json:"last_delivery"`
+	DB           string `json:"db"` //ok, disabled, or the error connecting
+}
+
+// fetchedNow and deliveredNow are nil safe so callers don't check whether health is served
+func (h *healthState) fetchedNow() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.fetched = time.Now()
+	h.mu.Unlock()
+}
+
+func (h *healthState) deliveredNow() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.delivered = time.Now()
+	h.mu.Unlock()
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// check is healthy while the log can be connected to and a fetch happened within stale
+// counting from the start so an instance isn't unhealthy before its first window ends
+func (h *healthState) check(ctx context.Context, config Config, now time.Time) health {
+	h.mu.Lock()
+	status := health{OK: true, Started: h.started.Unix(), LastFetch: unixOrZero(h.fetched), LastDelivery: unixOrZero(h.delivered), DB: "disabled"}
+	last := h.fetched
+	if last.IsZero() {
+		last = h.started
+	}
+	if h.stale > 0 && now.Sub(last) > h.stale {
+		status.OK = false
+	}
+	h.mu.Unlock()
+	if config.db.Enabled() {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		conn, err := config.db.Connect(ctx)
+		if err != nil {
+			status.OK = false
+			status.DB = err.Error()
+		} else {
+			conn.Close()
+			status.DB = "ok"
+		}
+	}
+	return status
+}
+
+// serveHealth serves /healthz on addr in the background
+// 200 while healthy and 503 otherwise
+func serveHealth(addr string, config Config, h *healthState) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status := h.check(r.Context(), config, time.Now())
+		code := http.StatusOK
+		if !status.OK {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, status)
+	})
+	go func() {
+		log.Println(http.ListenAndServe(addr, mux))
+	}()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+type DigestConfig struct {
+	Hours   int         `json:"hours"`   //periods to include. defaults to 24
+	Symbols int         `json:"symbols"` //rows in the heatmap. defaults to 15
+	Phases  PhaseConfig `json:"phases"`
+}
+
+const (
+	cellWidth    = 20
+	cellHeight   = 16
+	labelWidth   = 48
+	headerHeight = 16
+	// telegram rejects longer captions of photos
+	captionLimit = 1024
+)
+
+// sendDigest renders the exchange net flow of recent periods as a heatmap and sends it as a photo
+func sendDigest(ctx context.Context, config Config, now time.Time) error {
+	hours := config.Digest.Hours
+	if hours < 1 {
+		hours = 24
+	}
+	symbols := config.Digest.Symbols
+	if symbols < 1 {
+		symbols = 15
+	}
+	since := now.Add(-time.Duration(hours) * time.Hour)
+	periods, flows, err := store.FetchPeriodFlows(ctx, config.db, transferSection.name, since.Unix(), now.Unix())
+	if err != nil {
+		return err
+	}
+	if len(periods) < 1 {
+		return nil
+	}
+	img, err := renderHeatmap(periods, flows, symbols)
+	if err != nil {
+		return err
+	}
+	caption := fmt.Sprintf("Exchange net flow from %s to %s\nred is inflow. green is outflow",
+		since.Format("Jan 2 3:04PM"), now.Format("Jan 2 3:04PM"))
+	for i, line := range phaseLines(periods, flows, config) {
+		if i == 0 {
+			line = "\n" + line
+		}
+		if len(caption)+len(line)+1 > captionLimit {
+			break
+		}
+		caption += "\n" + line
+	}
+	return config.Telegram.SendPhoto(config.Telegram.RecipientID, caption, img)
+}
+
+// renderHeatmap draws symbols as rows and periods as columns
+// only the symbols with the most total movement are included
+func renderHeatmap(periods []int64, flows map[string]map[int64]float64, limit int) ([]byte, error) {
+	totals := map[string]float64{}
+	var symbols []string
+	max := 0.0
+	for symbol, values := range flows {
+		symbols = append(symbols, symbol)
+		for _, value := range values {
+			totals[symbol] += math.Abs(value)
+			max = math.Max(max, math.Abs(value))
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return totals[symbols[i]] > totals[symbols[j]]
+	})
+	if len(symbols) > limit {
+		symbols = symbols[:limit]
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, labelWidth+len(periods)*cellWidth, headerHeight+len(symbols)*cellHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	drawer := &font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	for i, period := range periods {
+		if i%3 != 0 {
+			continue
+		}
+		drawer.Dot = fixed.P(labelWidth+i*cellWidth, headerHeight-4)
+		drawer.DrawString(time.Unix(period, 0).Format("15:04"))
+	}
+	for row, symbol := range symbols {
+		y := headerHeight + row*cellHeight
+		drawer.Dot = fixed.P(4, y+cellHeight-4)
+		drawer.DrawString(strings.ToUpper(symbol))
+		for column, period := range periods {
+			x := labelWidth + column*cellWidth
+			cell := image.Rect(x+1, y+1, x+cellWidth-1, y+cellHeight-1)
+			draw.Draw(img, cell, &image.Uniform{flowColor(flows[symbol][period], max)}, image.Point{}, draw.Src)
+		}
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+// flowColor shades inflow red and outflow green
+// square root so smaller flows are still visible next to large ones
+func flowColor(value, max float64) color.Color {
+	if max == 0 || value == 0 {
+		return color.RGBA{235, 235, 235, 255}
+	}
+	intensity := uint8(math.Sqrt(math.Abs(value)/max) * 200)
+	if value > 0 {
+		return color.RGBA{255, 235 - intensity, 235 - intensity, 255}
+	}
+	return color.RGBA{235 - intensity, 255, 235 - intensity, 255}
+}
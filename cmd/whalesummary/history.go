@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// HistoryConfig compares each flow to the same summary of the windows before it
+// summarized from the transaction log so remaps and classification changes apply to the past too
+type HistoryConfig struct {
+	Windows int `json:"windows"` //previous windows of the same length to compare to. 0 disables
+}
+
+// history is the average magnitude of net flow of the previous windows by section then symbol
+type history struct {
+	Averages store.Averages
+	Label    string // what the average covers like 24h avg or prev window
+}
+
+// fetchHistory summarizes the stored transactions of each previous window
+// windows without transactions count as zero
+func fetchHistory(ctx context.Context, config Config, window timeWindow, entities map[string]string) (history, error) {
+	n := int64(config.History.Windows)
+	length := window.end - window.start
+	if n < 1 || length <= 0 {
+		return history{}, nil
+	}
+	since := window.start - n*length
+	windows := make([][]whalealert.Transaction, n)
+	for i := range windows {
+		// one query per window so the cap of a busy window doesn't drop the oldest ones
+		start := since + int64(i)*length
+		transactions, err := store.FetchTransactions(ctx, config.db, start, start+length, "", reportLimit)
+		if err != nil {
+			return history{}, err
+		}
+		windows[i] = normalizeOwners(transactions, entities)
+	}
+	averages := store.Averages{}
+	for _, transactions := range windows {
+		windowSummary, _ := summarize(transactions, config)
+		for section, flows := range windowSummary.sections() {
+			for symbol, value := range flows {
+				if averages[section] == nil {
+					averages[section] = map[string]float64{}
+				}
+				averages[section][symbol] += math.Abs(value) / float64(n)
+			}
+		}
+	}
+	label := "prev window"
+	if n > 1 {
+		label = spanLabel(time.Duration(n*length)*time.Second) + " avg"
+	}
+	return history{Averages: averages, Label: label}, nil
+}
+
+// spanLabel is a duration in its largest whole unit like 24h, 2d, or 12m
+func spanLabel(span time.Duration) string {
+	switch {
+	case span >= 48*time.Hour && span%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", span/(24*time.Hour))
+	case span%time.Hour == 0:
+		return fmt.Sprintf("%dh", span/time.Hour)
+	case span%time.Minute == 0:
+		return fmt.Sprintf("%dm", span/time.Minute)
+	}
+	return fmt.Sprintf("%ds", span/time.Second)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// IgnoreRule leaves out transactions that match every field it sets
+// like {"owner": "binance", "symbol": "usdt", "type": "transfer"} for sweeps whale alert mislabels
+type IgnoreRule struct {
+	Owner      string `json:"owner"`      //owner or entity on either side
+	Address    string `json:"address"`    //address on either side
+	Symbol     string `json:"symbol"`     //before or after remap
+	Type       string `json:"type"`       //transaction type like transfer or mint
+	Blockchain string `json:"blockchain"` //optional
+	Hash       string `json:"hash"`
+}
+
+// empty rules would leave out everything
+func (rule IgnoreRule) empty() bool {
+	return rule.Owner == "" && rule.Address == "" && rule.Symbol == "" && rule.Type == "" && rule.Blockchain == "" && rule.Hash == ""
+}
+
+func (rule IgnoreRule) matches(transaction whalealert.Transaction, tickermap map[string]string) bool {
+	if rule.empty() {
+		return false
+	}
+	if rule.Hash != "" && !strings.EqualFold(rule.Hash, transaction.Hash) {
+		return false
+	}
+	if rule.Blockchain != "" && !strings.EqualFold(rule.Blockchain, transaction.Blockchain) {
+		return false
+	}
+	if rule.Type != "" && !strings.EqualFold(rule.Type, transaction.TransactionType) {
+		return false
+	}
+	if rule.Symbol != "" && !strings.EqualFold(rule.Symbol, transaction.Symbol) && !strings.EqualFold(rule.Symbol, summary.RemapSymbol(transaction.Symbol, tickermap)) {
+		return false
+	}
+	if rule.Owner != "" && !sameOwner(rule.Owner, transaction.From.Owner) && !sameOwner(rule.Owner, transaction.To.Owner) {
+		return false
+	}
+	if rule.Address != "" && !strings.EqualFold(rule.Address, transaction.From.Address) && !strings.EqualFold(rule.Address, transaction.To.Address) {
+		return false
+	}
+	return true
+}
+
+func sameOwner(a, b string) bool {
+	return b != "" && strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// ignore leaves out the transactions matched by any ignore rule
+func (config Config) ignore(transactions []whalealert.Transaction) []whalealert.Transaction {
+	if len(config.Ignore) < 1 {
+		return transactions
+	}
+	kept := make([]whalealert.Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		ignored := false
+		for _, rule := range config.Ignore {
+			if rule.matches(transaction, config.Remap) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			kept = append(kept, transaction)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// planHistory is how far back whale alert plans fetch unless whale_alert.history says otherwise
+const planHistory = 30 * 24 * time.Hour
+
+func historyLimit(config whalealert.Config) (time.Duration, error) {
+	if config.History == "" {
+		return planHistory, nil
+	}
+	return time.ParseDuration(config.History)
+}
+
+// maxChunk is the longest range whale alert answers in one request
+const maxChunk = time.Hour
+
+// importWindows are the windows of interval from newest back to oldest, newest first
+// the oldest is cut at oldest so nothing before it is fetched
+func importWindows(oldest, newest int64, interval time.Duration) []timeWindow {
+	length := int64(interval / time.Second)
+	var windows []timeWindow
+	for end := newest; end > oldest; end -= length {
+		start := end - length
+		if start < oldest {
+			start = oldest
+		}
+		windows = append(windows, timeWindow{start: start, end: end})
+	}
+	return windows
+}
+
+// seedState is how far an import or a backfill got
+// windows without transactions log no summary so without it they would be fetched again every run
+type seedState struct {
+	From     int64 `json:"from,omitempty"` //range of a backfill
+	To       int64 `json:"to,omitempty"`
+	Interval int64 `json:"interval"` //minutes of the windows
+	Reached  int64 `json:"reached"`  //start of the oldest window an import logged or end of the latest a backfill logged
+}
+
+// readSeedState is empty if nothing was seeded yet
+func readSeedState(path string) (seedState, error) {
+	var state seedState
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(body, &state)
+	return state, err
+}
+
+// writeSeedState replaces the file atomically so a crash never leaves it half written
+func writeSeedState(path string, state seedState) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, body, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runImport is the import subcommand
+// it walks back from the oldest logged window and logs the transactions and summary of every window
+// so trends and averages have history on a fresh install. nothing is sent but the result to the log chat
+// running it again continues where a budget or the plan stopped it
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := flags.String("c", "config.json", "config file")
+	days := flags.Int("days", 7, "days back from now to import")
+	interval := flags.Int64("interval", 48, "minutes of each imported window. match the interval of the reports")
+	budget := flags.Int("budget", -1, "max whale alert calls of the whole import including retries. defaults to whale_alert.budget")
+	statePath := flags.String("state", "import_state.json", "file to remember how far the import got in")
+	flags.Parse(args)
+
+	config := parseConfig(*configPath, os.Environ())
+	if !config.db.Enabled() {
+		log.Fatal("import needs log_db_url")
+	}
+	if *days < 1 || *interval < 1 || time.Duration(*interval)*time.Minute > maxChunk {
+		log.Fatal("Invalid import: days must be positive and interval between 1 and 60 minutes")
+	}
+	history, err := historyLimit(config.WhaleAlert)
+	if err != nil {
+		log.Fatal("Invalid whale_alert history: ", err)
+	}
+	if *budget >= 0 {
+		config.WhaleAlert.Budget = *budget
+	}
+	// whole minutes like the windows of reports
+	now := time.Now().Truncate(time.Minute)
+	back := time.Duration(*days) * 24 * time.Hour
+	if back > history {
+		fmt.Printf("whale alert only fetches %s back. importing from there\n", history)
+		back = history
+	}
+	oldest := now.Add(-back).Unix()
+	ctx := context.Background()
+	newest := now.Unix()
+	periods, err := store.FetchPeriods(ctx, config.db, oldest, newest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(periods) > 0 {
+		// windows after it were reported or imported already
+		newest = periods[0].Start
+	}
+	state, err := readSeedState(*statePath)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if state.Interval == *interval && state.Reached > oldest && state.Reached < newest {
+		// empty windows before the oldest logged one were fetched already
+		newest = state.Reached
+	}
+	windows := importWindows(oldest, newest, time.Duration(*interval)*time.Minute)
+	if len(windows) < 1 {
+		fmt.Println("nothing to import. the log already goes back", *days, "days")
+		return
+	}
+	entities, err := loadEntities(ctx, config.db, config.Entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	session := whalealert.NewSession(config.WhaleAlert)
+	imported, logged := 0, 0
+	var stopped error
+	for _, window := range windows {
+		label := time.Unix(window.start, 0).Format("Jan 2 3:04PM") + " - " + time.Unix(window.end, 0).Format("3:04PM")
+		n, err := seedWindow(ctx, config, session, window, entities)
+		if err != nil {
+			stopped = fmt.Errorf("%s: %w", label, err)
+			break
+		}
+		imported++
+		logged += n
+		fmt.Printf("imported %s: %d transactions\n", label, n)
+		err = writeSeedState(*statePath, seedState{Interval: *interval, Reached: window.start})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	msg := fmt.Sprintf("imported %d of %d windows with %d transactions in %d whale alert calls", imported, len(windows), logged, session.Calls())
+	if imported > 0 {
+		msg += ". the log now goes back to " + time.Unix(windows[imported-1].start, 0).Format("Jan 2 3:04PM")
+	}
+	if stopped != nil {
+		var truncated *whalealert.BudgetError
+		var status *whalealert.StatusError
+		switch {
+		case errors.As(stopped, &truncated):
+			msg += ". the budget ran out. run import again to continue"
+		case errors.As(stopped, &status) && imported > 0:
+			msg += ". whale alert history may end there. set whale_alert.history to the plan's"
+		}
+		msg += "\nstopped at " + stopped.Error()
+	}
+	fmt.Println(msg)
+	config.Telegram.SendMessage(config.Telegram.LogID, msg)
+}
+
+// seedWindow fetches a window and logs its transactions and summary without reporting them
+// returns how many transactions were logged
+func seedWindow(ctx context.Context, config Config, session *whalealert.Session, window timeWindow, entities map[string]string) (int, error) {
+	fetched, err := session.Fetch(window.start, window.end)
+	if err != nil {
+		// a partial window would look complete to the next run
+		return 0, err
+	}
+	fetched = prepareFetched(config, fetched)
+	err = store.LogWhales(ctx, config.db, fetched)
+	if err != nil {
+		return 0, err
+	}
+	err = store.LogTransactions(ctx, config.db, fetched)
+	if err != nil {
+		return 0, err
+	}
+	transactions, _ := classifyOwners(config, normalizeOwners(fetched, entities), entities)
+	windowSummary, _ := summarize(transactions, config)
+	return len(fetched), store.LogSummary(ctx, config.db, window.start, window.end, windowSummary.sections())
+}
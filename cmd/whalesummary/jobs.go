@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// Job is an independent report of the fetched window with its own filters and recipients
+type Job struct {
+	Name           string   `json:"name"`
+	Blockchains    []string `json:"blockchains"`     //only include these blockchains. defaults to all
+	Symbols        []string `json:"symbols"`         //only include these symbols. defaults to all
+	MinUSD         float64  `json:"min_usd"`         //ignore transactions below this. can't be lower than whale_alert.min
+	Threshold      float64  `json:"threshold"`       //minimum net flow of a symbol to report. defaults to 1,000,000
+	ThresholdShare float64  `json:"threshold_share"` //share of the window's usd volume a net flow needs like 0.02 instead. threshold becomes the floor
+	Analyzers      []string `json:"analyzers"`       //supply, transfers, chains, derivatives, staking, custody, locks, bridges, rotations, exchanges, reserves, concentration, flagged, and/or watched. defaults to all
+	Top            int      `json:"top"`             //symbols listed per section. the rest are rolled up into one line. defaults to all
+	Mode           string   `json:"mode"`            //supply for only mints and burns or transfers for only exchange flows. defaults to the config mode
+	Recipients     []string `json:"recipients"`      //chat ids
+	Format         Format   `json:"format"`
+	SlackChannel   string   `json:"slack_channel"` //channel for slack.bot_token. defaults to slack.channel
+	EmailTo        []string `json:"email_to"`      //addresses for email. defaults to email.to
+}
+
+const (
+	bridgeAnalyzer   = "bridges"
+	rotationAnalyzer = "rotations"
+)
+
+const (
+	// supplyMode skips everything but mints and burns for issuance focused channels
+	supplyMode = "supply"
+	// transferMode skips everything but exchange inflow and outflow
+	transferMode = "transfers"
+)
+
+// jobs are the configured jobs or the main recipient if there are none
+// categories with their own recipient are jobs limited to their symbols
+func (config Config) jobs() []Job {
+	jobs := config.Jobs
+	if len(jobs) < 1 {
+		jobs = []Job{{
+			Name:       "default",
+			Recipients: []string{config.Telegram.RecipientID},
+			Format:     config.Telegram.Format,
+		}}
+	}
+	for i := range jobs {
+		if jobs[i].Mode == "" {
+			jobs[i].Mode = config.Mode
+		}
+	}
+	for _, category := range config.Categories {
+		if category.RecipientID == "" {
+			continue
+		}
+		jobs = append(jobs, Job{
+			Name:       category.Name,
+			Symbols:    category.Symbols,
+			Recipients: []string{category.RecipientID},
+			Format:     category.Format,
+			Mode:       config.Mode,
+		})
+	}
+	return jobs
+}
+
+func validMode(mode string) bool {
+	return mode == "" || mode == supplyMode || mode == transferMode
+}
+
+func (job Job) threshold() float64 {
+	if job.Threshold > 0 {
+		return job.Threshold
+	}
+	return 1000000
+}
+
+// adapt resolves the threshold share into the threshold of the window
+// volume is the usd amount of every transaction the job keeps
+func (job Job) adapt(transactions []whalealert.Transaction, tickermap map[string]string) Job {
+	if job.ThresholdShare <= 0 {
+		return job
+	}
+	if job.filters() {
+		transactions = job.filter(transactions, tickermap)
+	}
+	volume := 0.0
+	for _, transaction := range transactions {
+		volume += transaction.AmountUsd
+	}
+	job.Threshold = math.Max(job.Threshold, volume*job.ThresholdShare)
+	return job
+}
+
+// minThreshold is the lowest threshold of the jobs
+func minThreshold(jobs []Job) float64 {
+	min := 0.0
+	for i, job := range jobs {
+		if i == 0 || job.threshold() < min {
+			min = job.threshold()
+		}
+	}
+	return min
+}
+
+func (job Job) analyzes(analyzer string) bool {
+	// flagged and watched movements matter whatever the mode
+	// pace and sentiment only cover the sections the mode leaves
+	if job.Mode == supplyMode && analyzer != flaggedAnalyzer && analyzer != watchAnalyzer && analyzer != paceAnalyzer && analyzer != sentimentAnalyzer && analyzer != supplySection.name && analyzer != rotationAnalyzer {
+		return false
+	}
+	if job.Mode == transferMode && analyzer != flaggedAnalyzer && analyzer != watchAnalyzer && analyzer != paceAnalyzer && analyzer != sentimentAnalyzer && analyzer != transferSection.name && analyzer != chainAnalyzer && analyzer != symbolChainAnalyzer && analyzer != derivativesSection.name && analyzer != stakingSection.name && analyzer != custodySection.name && analyzer != breakdownAnalyzer && analyzer != reserveAnalyzer {
+		return false
+	}
+	if len(job.Analyzers) < 1 {
+		return true
+	}
+	for _, name := range job.Analyzers {
+		if strings.EqualFold(name, analyzer) {
+			return true
+		}
+	}
+	return false
+}
+
+// filters is whether the job needs its own summary
+func (job Job) filters() bool {
+	return len(job.Blockchains) > 0 || len(job.Symbols) > 0 || job.MinUSD > 0 || job.Mode != ""
+}
+
+// filter keeps the transactions the job is interested in
+func (job Job) filter(transactions []whalealert.Transaction, tickermap map[string]string) []whalealert.Transaction {
+	var filtered []whalealert.Transaction
+	for _, transaction := range transactions {
+		if len(job.Blockchains) > 0 && !summary.ContainsSymbol(transaction.Blockchain, job.Blockchains) {
+			continue
+		}
+		if len(job.Symbols) > 0 && !summary.ContainsSymbol(summary.RemapSymbol(transaction.Symbol, tickermap), job.Symbols) {
+			continue
+		}
+		if transaction.AmountUsd < job.MinUSD {
+			continue
+		}
+		if job.Mode == supplyMode && transaction.TransactionType != whalealert.MINT.String() && transaction.TransactionType != whalealert.BURN.String() {
+			continue
+		}
+		if job.Mode == transferMode && transaction.TransactionType != whalealert.TRANSFER.String() {
+			continue
+		}
+		filtered = append(filtered, transaction)
+	}
+	return filtered
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// LookupsConfig is how the per symbol lookups of prices, order books, and candles are made
+type LookupsConfig struct {
+	Workers int    `json:"workers"` //requests made at the same time. defaults to 4
+	Cache   string `json:"cache"`   //file lookups are kept in between runs. optional
+	TTL     string `json:"ttl"`     //how long a cached lookup is used like 10m. defaults to 10m
+}
+
+func (config LookupsConfig) workers() int {
+	if config.Workers > 0 {
+		return config.Workers
+	}
+	return 4
+}
+
+func (config LookupsConfig) ttl() time.Duration {
+	ttl, err := time.ParseDuration(config.TTL)
+	if err != nil || ttl <= 0 {
+		return 10 * time.Minute
+	}
+	return ttl
+}
+
+// cachedLookup is a lookup result and when it was fetched
+type cachedLookup struct {
+	Fetched int64           `json:"fetched"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// lookups runs requests in a bounded worker pool and remembers their results by key
+// safe to use from several goroutines
+type lookups struct {
+	slots   chan struct{}
+	path    string
+	ttl     time.Duration
+	now     time.Time
+	mu      sync.Mutex
+	cached  map[string]cachedLookup
+	changed bool
+}
+
+// newLookups reads the cache when there is one
+// a missing or unreadable cache starts empty
+func newLookups(config LookupsConfig, now time.Time) (*lookups, error) {
+	l := &lookups{
+		slots:  make(chan struct{}, config.workers()),
+		path:   config.Cache,
+		ttl:    config.ttl(),
+		now:    now,
+		cached: map[string]cachedLookup{},
+	}
+	if l.path == "" {
+		return l, nil
+	}
+	body, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &l.cached)
+	}
+	if err != nil {
+		l.cached = map[string]cachedLookup{}
+		return l, fmt.Errorf("lookups cache %s: %w", l.path, err)
+	}
+	return l, nil
+}
+
+// each calls fn with every index up to n, at most workers at once, and waits for all of them
+func (l *lookups) each(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		l.slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-l.slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// get decodes the cached result of key into v if it is younger than the ttl
+func (l *lookups) get(key string, v interface{}) bool {
+	if l.path == "" {
+		return false
+	}
+	l.mu.Lock()
+	cached, ok := l.cached[key]
+	l.mu.Unlock()
+	if !ok || l.now.Sub(time.Unix(cached.Fetched, 0)) >= l.ttl {
+		return false
+	}
+	return json.Unmarshal(cached.Value, v) == nil
+}
+
+func (l *lookups) put(key string, v interface{}) {
+	if l.path == "" {
+		return
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	l.cached[key] = cachedLookup{Fetched: l.now.Unix(), Value: value}
+	l.changed = true
+	l.mu.Unlock()
+}
+
+// save writes what was fetched to the cache and drops what expired
+func (l *lookups) save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" || !l.changed {
+		return nil
+	}
+	for key, cached := range l.cached {
+		if l.now.Sub(time.Unix(cached.Fetched, 0)) >= l.ttl {
+			delete(l.cached, key)
+		}
+	}
+	body, err := json.Marshal(l.cached)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(l.path, body, 0644)
+	if err != nil {
+		return fmt.Errorf("lookups cache %s: %w", l.path, err)
+	}
+	l.changed = false
+	return nil
+}
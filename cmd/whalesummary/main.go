@@ -0,0 +1,1389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	_ "net/http/pprof"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enzosv/whalesummary/notify"
+	"github.com/enzosv/whalesummary/retry"
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+	"golang.org/x/text/message"
+)
+
+type Config struct {
+	Telegram        TelegramConfig    `json:"telegram"`
+	WhaleAlert      whalealert.Config `json:"whale_alert"`
+	StableCoins     []string          `json:"stable_coins"`
+	Remap           map[string]string `json:"remap"`
+	RemapIgnore     []string          `json:"remap_ignore"`  //symbols that only look like a wrapped or bridged variant of another
+	MissingUSD      string            `json:"missing_usd"`   //keep, exclude, or price transactions without a usd value at their timestamp. defaults to keep
+	LogDBURL        string            `json:"log_db_url"`    //postgres url or path of a sqlite file
+	LogDBDriver     string            `json:"log_db_driver"` //postgres or sqlite. defaults to postgres
+	LogDBRetry      retry.Config      `json:"log_db_retry"`
+	LogDBSchema     string            `json:"log_db_schema"` //postgres schema of the tables. defaults to the search_path of the url
+	db              store.DB
+	Fees            FeeConfig            `json:"fees"`
+	CoinGecko       CoinGeckoConfig      `json:"coingecko"`
+	Categories      []Category           `json:"categories"`
+	Pegs            map[string]string    `json:"pegs"` //fiat currency of stable coins not pegged to usd
+	Digest          DigestConfig         `json:"digest"`
+	Schedules       []Schedule           `json:"schedules"`
+	Jobs            []Job                `json:"jobs"`
+	Mode            string               `json:"mode"`     //default mode of jobs
+	Entities        map[string]string    `json:"entities"` //owner label to the entity it belongs to
+	Reserves        ReservesConfig       `json:"reserves"`
+	Tokens          []Token              `json:"tokens"`
+	Depth           DepthConfig          `json:"depth"`
+	Volatility      VolatilityConfig     `json:"volatility"`
+	Lookups         LookupsConfig        `json:"lookups"`
+	Concentration   ConcentrationConfig  `json:"concentration"`
+	Breakdown       BreakdownConfig      `json:"breakdown"`
+	ChainBreakdown  ChainBreakdownConfig `json:"chain_breakdown"`
+	Flagged         FlaggedConfig        `json:"flagged"`
+	Daemon          DaemonConfig         `json:"daemon"`
+	Watch           WatchConfig          `json:"watch"`
+	Exchanges       map[string]string    `json:"exchanges"`        //exchange entity to spot or derivatives
+	OwnerCategories map[string]string    `json:"owner_categories"` //owner substring to staking or custody
+	Seasonality     SeasonalityConfig    `json:"seasonality"`
+	History         HistoryConfig        `json:"history"`
+	Buckets         BucketsConfig        `json:"buckets"`
+	Sessions        SessionsConfig       `json:"sessions"`
+	OwnerTypes      map[string]OwnerType `json:"owner_types"`     //owner types besides exchange to report flows of like otc or miner
+	OwnerOverrides  map[string]string    `json:"owner_overrides"` //owner to the owner type to treat it as instead of what whale alert says. unknown leaves it out of exchange flows
+	Classify        ClassifyConfig       `json:"classify"`
+	Reconcile       ReconcileConfig      `json:"reconcile"`
+	Health          HealthConfig         `json:"health"`
+	Sentiment       SentimentConfig      `json:"sentiment"`
+	Alerts          AlertsConfig         `json:"alerts"`
+	Rules           []Rule               `json:"rules"`
+	Ignore          []IgnoreRule         `json:"ignore"`          //transactions left out of every summary like known noise
+	Unhandled       map[string]string    `json:"unhandled"`       //transaction type to ignore, log, or a handled type to summarize it as. defaults to log
+	UnhandledLimit  int                  `json:"unhandled_limit"` //most groups of unhandled transactions listed in the log chat. the rest are summed on one line. defaults to 20
+	Tenants         []Tenant             `json:"tenants"`
+	Slack           notify.Slack         `json:"slack"`
+	Email           notify.Email         `json:"email"`
+	Sheets          notify.Sheets        `json:"sheets"`
+	Locales         map[string]string    `json:"locales"` //chat id or slack channel to BCP 47 tag for number formatting. overrides the locale of the job
+	Quotes          map[string]string    `json:"quotes"`  //chat id or slack channel to btc or eth to express flows in. overrides the quote of the job
+	Bot             BotConfig            `json:"bot"`
+	Watchlist       []FlaggedAddress     `json:"watchlist"`  //addresses and labels of whales to alert on when they move
+	Explorers       map[string]string    `json:"explorers"`  //blockchain to transaction url with %s for the hash
+	ReportURL       string               `json:"report_url"` //public address of serve. messages cut short by a job's top link to the full report there
+	WebApp          bool                 `json:"web_app"`    //link every summary to the app of serve at report_url. opens inside telegram in private chats
+	Shadow          string               `json:"shadow"`     //path of a candidate config reported on the same transactions to its own recipients. relative to this config
+	flagged         map[string]FlaggedAddress
+	today           *runningTotals    // totals of the day in stream and daemon mode
+	health          *healthState      // what /healthz reports. nil unless it is served
+	seen            *seenTransactions // transactions reported by earlier windows of this process
+	alerts          *alertState       // tiers reached by the flows of earlier windows
+	shadow          *Config
+	output          string          // telegram, json, or both
+	payloads        *payloadWriter  // prints the summary of each window. nil unless output is json or both
+	export          string          // csv file every transaction is appended to
+	readOnly        bool            // a shadow never writes to the log production keeps
+	preview         bool            // a dry run prints messages and log writes instead
+	rerun           bool            // an explicit window or dry run reports what the log already has again
+	suggested       map[string]bool // remaps already suggested by this process
+}
+
+// Category groups symbols under a custom heading like L1, L2 or memecoins
+type Category struct {
+	Name        string   `json:"name"`
+	Symbols     []string `json:"symbols"`
+	RecipientID string   `json:"recipient_id"` //optional chat that only receives this category
+	Format      Format   `json:"format"`
+}
+
+type FeeConfig struct {
+	EthRPCURL string `json:"eth_rpc_url"` //json-rpc node for base fee
+	BTCFeeURL string `json:"btc_fee_url"` //mempool.space compatible fee estimates
+}
+
+type CoinGeckoConfig struct {
+	APIKey    string `json:"api_key"`    //optional demo api key
+	Global    bool   `json:"global"`     //include total market cap and btc dominance in header
+	URL       string `json:"url"`        //defaults to COINGECKOURL
+	Prices    bool   `json:"prices"`     //show the price and 24h change of reported symbols
+	MarketCap bool   `json:"market_cap"` //show net flows as a share of market cap, most significant first
+	// coingecko id per symbol for symbols shared by several coins like {"ton": "the-open-network"}
+	// defaults to the coin with the largest market cap
+	IDs map[string]string `json:"ids"`
+
+	StableCoins      bool   `json:"stable_coins"`       //add the symbols of the stablecoins category to stable_coins
+	StableCoinsCache string `json:"stable_coins_cache"` //file the category is kept in between fetches. defaults to stablecoins.json
+	StableCoinsTTL   string `json:"stable_coins_ttl"`   //how long the cached category is used like 24h. defaults to 24h
+}
+
+// TelegramConfig is the bot and the chats it reports to
+type TelegramConfig struct {
+	notify.Telegram
+	RecipientID string `json:"recipient_id"`
+	LogID       string `json:"log_id"`
+	Format      Format `json:"format"`
+	ChatCache   string `json:"chat_cache"` //file the numeric ids chats resolve to are kept in so runs don't ask telegram again. optional
+}
+
+// Summary is the net usd flow per symbol of a window
+type Summary struct {
+	Supply    map[string]float64
+	Transfers map[string]float64
+	Locks     map[string]float64
+	Bridges   map[summary.BridgeFlow]float64
+	Rotations map[summary.Rotation]float64
+	Exchanges map[string]float64 // net inflow per exchange entity
+	// net inflow per exchange entity then symbol
+	ExchangeSymbols map[string]map[string]float64
+	Movers          map[string]float64 // usd volume sent per entity
+	Flagged         []flaggedTransaction
+	Watched         []watchedTransaction
+	// exchange net flow of derivatives venues. kept out of Transfers
+	Derivatives map[string]float64
+	// net flow into staking providers and custodians. kept out of the other sections
+	Staking map[string]float64
+	Custody map[string]float64
+	// net flow per configured owner type then symbol
+	Owners map[string]map[string]float64
+	// exchange net flow per blockchain of its native coin and of its tokens
+	Chains map[string]chainFlow
+	// exchange net flow per symbol then blockchain
+	SymbolChains map[string]map[string]float64
+	// flows of each part of the window. set by the caller since it depends on the window
+	Buckets buckets
+}
+
+// sections are the flows stored per period for averages and the digest
+func (s Summary) sections() map[string]map[string]float64 {
+	sections := map[string]map[string]float64{
+		supplySection.name:      s.Supply,
+		transferSection.name:    s.Transfers,
+		derivativesSection.name: s.Derivatives,
+		stakingSection.name:     s.Staking,
+		custodySection.name:     s.Custody,
+		lockSection.name:        s.Locks,
+	}
+	for ownerType, flows := range s.Owners {
+		sections[ownerType] = flows
+	}
+	return sections
+}
+
+const COINGECKOURL = "https://api.coingecko.com/api/v3"
+
+func (config CoinGeckoConfig) baseURL() string {
+	if config.URL != "" {
+		return strings.TrimSuffix(config.URL, "/")
+	}
+	return COINGECKOURL
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "mockserver" {
+		runMockServer(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		runVerify(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "summarize" {
+		runSummarize(os.Args[2:])
+		return
+	}
+	configPath := flag.String("c", "config.json", "config file")
+	interval := flag.Int64("interval", 48, "minutes between start and end if either is not provided")
+	/*
+		48 so cron is more convenient
+		can't be 60 because whale alert complains about time range
+		0,48 0,4,8,12,16,20 * * *
+		36 1,5,9,13,17,21 * * *
+		24 2,6,10,14,18,22 * * *
+		12 3,7,11,15,19,23 * * *
+	*/
+	startFlag := flag.String("start", "", "inclusive start time for fetching transactions as unix seconds, RFC3339, or relative to now like -6h. defaults to interval before end")
+	endFlag := flag.String("end", "", "exclusive end time for fetching transactions as unix seconds, RFC3339, now, or relative to now like -1h. defaults to interval after start or the current minute")
+
+	digest := flag.Bool("digest", false, "send a heatmap of stored summaries instead of fetching transactions")
+	dryRun := flag.Bool("dry-run", false, "fetch and analyze but print messages and log writes instead of sending and writing them")
+	reconcileFlag := flag.Bool("reconcile", false, "fetch the windows logged in the last reconcile.hours again and correct flows whale alert revised")
+	schedule := flag.Bool("schedule", false, "keep running and execute the schedules in the config")
+	stream := flag.Bool("stream", false, "keep running and report transactions from the whale alert websocket as they arrive")
+	daemon := flag.Bool("daemon", false, "keep running and summarize every interval, resuming after the last processed window")
+	bot := flag.Bool("bot", false, "keep running and answer commands sent to the telegram bot")
+	record := flag.String("record", "", "directory to save every http exchange of this run into")
+	replay := flag.String("replay", "", "directory of recorded http exchanges to run against instead of the network")
+	explain := flag.String("explain", "", "print how the transactions of the window with this hash were classified instead of reporting")
+	mode := flag.String("mode", "", "supply to only report mints and burns or transfers to only report exchange flows. overrides the config mode")
+	export := flag.String("export", "", "csv file to append every transaction of the window to")
+	output := flag.String("output", outputTelegram, "telegram to send reports, json to print the summary of each window to stdout instead, or both")
+	pprofAddr := flag.String("pprof", "", "address like :6060 to serve net/http/pprof on while running")
+	healthAddr := flag.String("health", "", "address like :8081 to serve /healthz on while running")
+
+	flag.Parse()
+	window, err := resolveWindow(*startFlag, *endFlag, time.Duration(*interval)*time.Minute, time.Now())
+	if err != nil {
+		log.Fatal("Invalid window: ", err)
+	}
+	if *pprofAddr != "" {
+		// the api and mock server use their own mux so profiles are only served here
+		go func() {
+			log.Println(http.ListenAndServe(*pprofAddr, nil))
+		}()
+	}
+	if *record != "" && *replay != "" {
+		log.Fatal("Cannot record and replay at the same time")
+	}
+	if !validOutput(*output) {
+		log.Fatal("Invalid output: ", *output)
+	}
+	var payloads *payloadWriter
+	if *output != outputTelegram {
+		payloads = &payloadWriter{w: os.Stdout}
+		// everything else printed goes to stderr so stdout stays json
+		os.Stdout = os.Stderr
+	}
+	if *record != "" {
+		err = useRecorder(*record, false)
+		if err == nil {
+			err = recordWindow(*record, window)
+		}
+		if err != nil {
+			log.Fatal("Cannot record: ", err)
+		}
+	}
+	if *replay != "" {
+		err = useRecorder(*replay, true)
+		if err != nil {
+			log.Fatal("Cannot replay: ", err)
+		}
+		if *startFlag == "" && *endFlag == "" {
+			window, err = replayWindow(*replay)
+			if err != nil {
+				log.Fatal("Cannot replay window: ", err)
+			}
+		}
+	}
+	config := parseConfig(*configPath, os.Environ())
+	var served *healthState
+	if *healthAddr != "" {
+		served = &healthState{started: time.Now()}
+		served.stale = config.Health.stale(time.Duration(*interval)*time.Minute, *daemon || *stream)
+		serveHealth(*healthAddr, config, served)
+	}
+	run := func(config Config) {
+		config.seen = newSeen()
+		config.alerts = newAlertState()
+		if config.shadow != nil {
+			shadow := *config.shadow
+			shadow.alerts = newAlertState()
+			config.shadow = &shadow
+		}
+		if served != nil {
+			config.health = served
+			config.Telegram.Delivered = served.deliveredNow
+		}
+		window := window
+		if config.Sessions.Enabled && *startFlag == "" && *endFlag == "" && *replay == "" {
+			window = config.Sessions.lastSession(time.Now())
+		}
+		if *mode != "" {
+			if !validMode(*mode) {
+				log.Fatal("Invalid mode: ", *mode)
+			}
+			config.Mode = *mode
+			for i := range config.Jobs {
+				config.Jobs[i].Mode = *mode
+			}
+		}
+		if *dryRun {
+			config = config.dryRun()
+		}
+		config.output = *output
+		config.export = *export
+		config.payloads = payloads
+		if *output == outputJSON {
+			// the shadow only sends
+			config.shadow = nil
+		}
+		if *output != outputJSON && *replay == "" && *explain == "" {
+			// recordings don't have the lookups
+			config = resolveChats(config)
+			if config.shadow != nil {
+				shadow := resolveChats(*config.shadow)
+				config.shadow = &shadow
+			}
+		}
+		if *digest {
+			err := sendDigest(context.Background(), config, time.Now())
+			if err != nil {
+				config.Telegram.SendMessage(config.Telegram.LogID, err.Error())
+			}
+			return
+		}
+
+		if *reconcileFlag {
+			err := reconcile(context.Background(), config, time.Now())
+			if err != nil {
+				config.Telegram.SendMessage(config.Telegram.LogID, err.Error())
+			}
+			return
+		}
+
+		if *schedule {
+			runSchedules(config)
+			return
+		}
+		if *stream {
+			runStream(config)
+			return
+		}
+		if *daemon {
+			runDaemon(config, time.Duration(*interval)*time.Minute)
+			return
+		}
+		if *bot {
+			runBot(config)
+			return
+		}
+		if *explain != "" {
+			err := runExplain(config, window, *explain)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		// a single window asked for again is reported even if the log has it
+		config.rerun = *dryRun || *startFlag != "" || *endFlag != ""
+		runSummary(config, window)
+	}
+	if len(config.Tenants) > 0 {
+		runTenants(*configPath, config.Tenants, run)
+		return
+	}
+	run(config)
+}
+
+// runSummary fetches, summarizes, and reports the transactions of a window
+// the fetch error is returned so callers know whether the window is complete
+func runSummary(config Config, window timeWindow) error {
+	transactions, fetchErr := fetchWindow(config, window)
+	if len(transactions) < 1 {
+		return fetchErr
+	}
+	reportTransactions(config, window, transactions, fetchErr)
+	return fetchErr
+}
+
+// fetchWindow fetches the transactions of the window and reports a failed fetch to the log chat
+// whatever was fetched before the failure is still returned
+func fetchWindow(config Config, window timeWindow) ([]whalealert.Transaction, error) {
+	transactions, fetchErr := whalealert.FetchTransactions(config.WhaleAlert, window.start, window.end)
+	var truncated *whalealert.BudgetError
+	if fetchErr == nil || errors.As(fetchErr, &truncated) {
+		// whale alert answered even if the budget cut the window short
+		config.health.fetchedNow()
+	}
+	if fetchErr != nil {
+		config.Telegram.SendMessage(config.Telegram.LogID, fetchErr.Error())
+		// not returning to continue with successful requests if any
+	}
+	// config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("[%d whale transactions](%s) from %s to %s",
+	// 	len(transactions),
+	// 	url,
+	// 	time.Unix(window.start, 0).Format("Jan 2 3:04:05PM"),
+	// 	time.Unix(window.end, 0).Format("3:04:05PM"),
+	// ))
+	return transactions, fetchErr
+}
+
+// reportTransactions summarizes transactions of a window and sends the analysis of every job
+// fetchErr is whatever stopped the transactions from being complete
+func reportTransactions(config Config, window timeWindow, transactions []whalealert.Transaction, fetchErr error) {
+	transactions, seen := config.skipSeen(transactions)
+	if seen > 0 {
+		config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("skipped %d transactions already reported by an earlier window", seen))
+	}
+	if len(transactions) < 1 {
+		return
+	}
+	if config.shadow != nil {
+		// after production and with its own copy since normalizing rewrites the transactions
+		shadowed := append([]whalealert.Transaction(nil), transactions...)
+		defer reportTransactions(*config.shadow, window, shadowed, fetchErr)
+	}
+	start, end := window.start, window.end
+	var err error
+	config.StableCoins, err = withCategoryStableCoins(config.CoinGecko, config.StableCoins, time.Now())
+	if err != nil {
+		config.Telegram.SendMessage(config.Telegram.LogID, err.Error())
+	}
+	transactions, unknownTokens := normalizeAmounts(transactions, newTokenRegistry(config.Tokens))
+	if len(unknownTokens) > 0 {
+		config.Telegram.SendMessage(config.Telegram.LogID, "unknown tokens:\n"+strings.Join(unknownTokens, "\n"))
+	}
+	transactions, duplicates := summary.Dedupe(transactions)
+	if duplicates > 0 {
+		fmt.Printf("skipped %d duplicate transactions\n", duplicates)
+	}
+	transactions, excluded, err := handleMissingUSD(config, transactions)
+	if err != nil {
+		config.Telegram.SendMessage(config.Telegram.LogID, err.Error())
+	}
+	if len(excluded) > 0 {
+		config.Telegram.SendMessage(config.Telegram.LogID, "excluded without a usd value:\n"+strings.Join(excluded, "\n"))
+	}
+	if config.export != "" {
+		err = exportTransactions(config.export, transactions)
+		if err != nil {
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("export %s: %s", config.export, err))
+		}
+	}
+	if config.logs(func() []string {
+		return []string{fmt.Sprintf("the owners of %d wallets and %d transactions", len(transactions)*2, len(transactions))}
+	}) {
+		err = store.LogWhales(context.Background(), config.db, transactions)
+		if err != nil {
+			fmt.Println(err)
+		}
+		err = store.LogTransactions(context.Background(), config.db, transactions)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	entities, err := loadEntities(context.Background(), config.db, config.Entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+	transactions = normalizeOwners(transactions, entities)
+	transactions, classifyErrs := classifyOwners(config, transactions, entities)
+	for _, err := range classifyErrs {
+		config.Telegram.SendMessage(config.Telegram.LogID, err.Error())
+	}
+	var flagErrs []error
+	config.flagged, flagErrs = loadFlagged(config.Flagged, config.Watchlist)
+	for _, err := range flagErrs {
+		config.Telegram.SendMessage(config.Telegram.LogID, err.Error())
+	}
+	windowSummary, unhandled := summarize(transactions, config)
+	if config.payloads != nil {
+		err = config.payloads.write(config.windowPayload(window, windowSummary, unhandled))
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	sends := config.output != outputJSON
+	if sends {
+		alertFlagged(config, windowSummary.Flagged)
+		alertWatched(config, windowSummary.Watched)
+		alertTiers(config, windowSummary.sections(), time.Now())
+		alertRules(config, windowSummary.sections(), transactions)
+		if lines := summary.UnhandledLines(unhandled, config.Unhandled, config.UnhandledLimit); len(lines) > 0 {
+			config.Telegram.SendMessage(config.Telegram.LogID, "unhandled:\n"+strings.Join(lines, "\n"))
+		}
+		suggestRemaps(config, window, transactions)
+	}
+
+	ctx := context.Background()
+	stored := storedEnrichment(ctx, config, window, entities)
+	if config.db.Enabled() {
+		if config.logs(func() []string { return summaryWrites(window, windowSummary.sections()) }) {
+			err = store.LogSummary(ctx, config.db, start, end, windowSummary.sections())
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+	if config.Sheets.Enabled() && !config.readOnly {
+		err = config.Sheets.Append(sheetHeader, sheetRows(window, windowSummary.sections()))
+		if err != nil {
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("sheets %s: %s", config.Sheets.SpreadsheetID, err))
+		}
+	}
+
+	if !sends {
+		return
+	}
+	jobs := config.jobs()
+	for i := range jobs {
+		jobs[i] = jobs[i].adapt(transactions, config.Remap)
+	}
+	enrichment := stored
+	pool, err := newLookups(config.Lookups, time.Now())
+	if err != nil {
+		fmt.Println(err)
+	}
+	// each lookup fills its own field of the enrichment so they run at the same time
+	var wg sync.WaitGroup
+	enrich := func(fetch func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := fetch()
+			if err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+	if config.Reserves.URL != "" || len(config.Reserves.Static) > 0 {
+		enrich(func() (err error) {
+			enrichment.Reserves, err = fetchReserves(config.Reserves, entities)
+			return err
+		})
+	}
+	if config.Depth.URL != "" {
+		enrich(func() error {
+			enrichment.Liquidity = fetchLiquidity(config.Depth, inflowSymbols(windowSummary, minThreshold(jobs), config), pool)
+			return nil
+		})
+	}
+	if config.Volatility.URL != "" {
+		enrich(func() error {
+			enrichment.Volatility = fetchVolatility(config.Volatility, reportedSymbols(windowSummary, minThreshold(jobs), config), pool)
+			return nil
+		})
+	}
+	if config.CoinGecko.Prices || config.CoinGecko.MarketCap {
+		enrich(func() (err error) {
+			enrichment.Prices, err = fetchPrices(config.CoinGecko, reportedSymbols(windowSummary, minThreshold(jobs), config), pool)
+			return err
+		})
+	}
+	for _, job := range jobs {
+		if job.Format.currency() != "usd" {
+			enrich(func() (err error) {
+				enrichment.Rates, err = fetchExchangeRates(config.CoinGecko)
+				return err
+			})
+			break
+		}
+	}
+	if config.quoted(jobs) {
+		enrich(func() (err error) {
+			enrichment.QuotePrices, err = fetchQuotePrices(config.CoinGecko, window)
+			return err
+		})
+	}
+	wg.Wait()
+	err = pool.save()
+	if err != nil {
+		fmt.Println(err)
+	}
+	var header headerContext
+	headerFetched := false
+	for _, job := range jobs {
+		jobSummary := windowSummary
+		jobTransactions := transactions
+		if job.filters() {
+			jobTransactions = job.filter(transactions, config.Remap)
+			jobSummary, _ = summarize(jobTransactions, config)
+		}
+		jobSummary.Buckets = bucketFlows(jobTransactions, window, config)
+		enrichment := enrichment
+		if config.today != nil {
+			config.today.add(job.Name, window, jobSummary)
+			enrichment.Today = config.today.Jobs[job.Name]
+		}
+		if analyzeSummary(jobSummary, enrichment, job, config) == "" {
+			continue
+		}
+		if !headerFetched {
+			// shared by every job and only worth fetching if something is reported
+			header = summaryHeader(config, window, fetchErr)
+			headerFetched = true
+		}
+		details := config.reportButton(job, jobSummary, enrichment, window)
+		// rendered once per locale and quote
+		rendered := map[string]string{}
+		for _, recipient := range job.Recipients {
+			recipientJob := job
+			recipientJob.Format = config.recipientFormat(job.Format, recipient)
+			key := recipientJob.Format.Locale + "|" + recipientJob.Format.quote()
+			msg, ok := rendered[key]
+			if !ok {
+				msg, err = renderJob(config, recipientJob, jobSummary, enrichment, header, window)
+				if err != nil {
+					config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("%s template: %s", job.Name, err))
+					break
+				}
+				rendered[key] = msg
+			}
+			if details != nil {
+				config.Telegram.SendWithButton(recipient, msg, recipientJob.Format.telegramParseMode(), config.recipientButton(*details, recipient))
+				continue
+			}
+			config.Telegram.SendFormatted(recipient, msg, recipientJob.Format.telegramParseMode())
+		}
+		if config.Slack.Enabled() {
+			err = reportSlack(config, slackJob(job), jobSummary, enrichment, header, window)
+			if err != nil {
+				config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("%s slack: %s", job.Name, err))
+			}
+		}
+		if config.Email.Enabled() {
+			err = reportEmail(config, job, jobSummary, enrichment, header, window)
+			if err != nil {
+				config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("%s email: %s", job.Name, err))
+			}
+		}
+	}
+}
+
+// storedEnrichment is the context of the window read from the log
+// averages of the week before it, the usual flow of its weekday and hour, and the previous windows
+func storedEnrichment(ctx context.Context, config Config, window timeWindow, entities map[string]string) Enrichment {
+	enrichment := Enrichment{Season: season(window.start)}
+	if !config.db.Enabled() {
+		return enrichment
+	}
+	var err error
+	enrichment.Averages, err = store.FetchAverages(ctx, config.db, window.start-7*24*60*60, window.start)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if config.Seasonality.Weeks > 0 {
+		enrichment.Seasonal, err = store.FetchSeasonalAverages(ctx, config.db, window.start, config.Seasonality.Weeks)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	enrichment.History, err = fetchHistory(ctx, config, window, entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return enrichment
+}
+
+// renderJob is the whole message of a job
+func renderJob(config Config, job Job, summary Summary, enrichment Enrichment, header headerContext, window timeWindow) (string, error) {
+	analysis := analyzeSummary(summary, enrichment, job, config)
+	return newRenderer(job, enrichment, config).renderMessage(header, analysis, window)
+}
+
+// recipientFormat is the format with the locale and quote configured for the recipient if any
+func (config Config) recipientFormat(format Format, recipient string) Format {
+	if locale, ok := config.Locales[recipient]; ok {
+		format.Locale = locale
+	}
+	if quote, ok := config.Quotes[recipient]; ok {
+		format.Quote = quote
+	}
+	return format
+}
+
+// summarize pairs bridges then nets flows per symbol
+func summarize(transactions []whalealert.Transaction, config Config) (Summary, []whalealert.Transaction) {
+	transactions = config.overrideOwners(config.ignore(transactions))
+	transactions = summary.Reclassify(transactions, config.Unhandled)
+	flagged := matchFlagged(transactions, config.flagged)
+	watched := matchWatched(transactions, config.Watch.watchList())
+	bridges, transactions := summary.MatchBridges(transactions, config.Remap)
+	rotations, transactions := summary.MatchRotations(transactions, config.Remap, config.StableCoins)
+	staking, custody, uncustodied := custodyFlows(transactions, config)
+	owners, untyped := ownerTypeFlows(uncustodied, config)
+	derivativeTransactions, spotTransactions := splitDerivatives(untyped, config)
+	_, derivatives, _, _ := summary.Flows(derivativeTransactions, config.Remap)
+	supply, transfers, locks, unhandled := summary.Flows(spotTransactions, config.Remap)
+	return Summary{Supply: supply, Transfers: transfers, Derivatives: derivatives, Staking: staking, Custody: custody, Owners: owners, Locks: locks, Bridges: bridges, Rotations: rotations, Chains: chainFlows(spotTransactions, config), SymbolChains: symbolChainFlows(spotTransactions, config.Remap), Exchanges: exchangeFlows(transactions), ExchangeSymbols: exchangeSymbolFlows(transactions, config.Remap), Movers: moverVolumes(transactions), Flagged: flagged, Watched: watched}, unhandled
+}
+
+// headerContext is shown before the analysis of every job
+// fetched once and rendered with the number formatting of each recipient
+type headerContext struct {
+	truncated *whalealert.BudgetError
+	session   string
+	global    *globalContext
+	fees      feeContext
+}
+
+// summaryHeader fetches the context shown before the analysis
+func summaryHeader(config Config, window timeWindow, fetchErr error) headerContext {
+	var header headerContext
+	errors.As(fetchErr, &header.truncated)
+	if config.Sessions.Enabled {
+		// the same flow reads differently in each session
+		header.session = config.Sessions.sessionLabel(window)
+	}
+	if config.CoinGecko.Global {
+		global, err := fetchGlobalContext(config.CoinGecko)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			header.global = &global
+		}
+	}
+	header.fees = fetchFeeContext(config.Fees)
+	return header
+}
+
+// headerLines renders the header context in the recipient's locale
+func (r renderer) headerLines(header headerContext) []string {
+	var lines []string
+	if header.truncated != nil {
+		lines = append(lines, fmt.Sprintf("Truncated: only includes transactions until %s",
+			time.Unix(header.truncated.Cutoff, 0).Format("Jan 2 3:04PM")))
+	}
+	if header.session != "" {
+		lines = append(lines, header.session)
+	}
+	if header.global != nil {
+		lines = append(lines, r.p.Sprintf("Market cap: $%.2fT (%+.1f%% 24h) | BTC dominance: %.1f%%",
+			header.global.MarketCap/1000000000000, header.global.Change, header.global.BTCDominance))
+	}
+	var fees []string
+	if header.fees.eth {
+		fees = append(fees, r.p.Sprintf("ETH base %.1f gwei", header.fees.ethGwei))
+	}
+	if header.fees.btc {
+		fees = append(fees, r.p.Sprintf("BTC %d sat/vB (1h %d)", header.fees.btcFastest, header.fees.btcHour))
+	}
+	if len(fees) > 0 {
+		lines = append(lines, "Fees: "+strings.Join(fees, " | "))
+	}
+	return lines
+}
+
+// parseConfig reads and validates the config file with the settings of environ applied over it
+// without a file the environment is the whole config if it has any settings
+// tenants and a shadow are parsed with a nil environ so overrides meant for the main config never reach them
+func parseConfig(path string, environ []string) Config {
+	var config Config
+	configFile, err := os.Open(path)
+	if err != nil && !(os.IsNotExist(err) && len(configEnv(environ)) > 0) {
+		log.Fatal("Cannot open server configuration file: ", err)
+	}
+	if err == nil {
+		defer configFile.Close()
+		dec := json.NewDecoder(configFile)
+		if err = dec.Decode(&config); errors.Is(err, io.EOF) {
+			//do nothing
+		} else if err != nil {
+			log.Fatal("Cannot load server configuration file: ", err)
+		}
+	}
+	err = overrideConfig(&config, environ)
+	if err != nil {
+		log.Fatal("Invalid environment variable ", err)
+	}
+	config.WhaleAlert.Policy, err = config.WhaleAlert.Retry.Policy(retry.Policy{Attempts: 3, Backoff: time.Second, Timeout: 30 * time.Second, Jitter: 0.2})
+	if err != nil {
+		log.Fatal("Invalid whale_alert retry: ", err)
+	}
+	config.Telegram.Policy, err = config.Telegram.Retry.Policy(retry.Policy{Attempts: 2, Backoff: time.Second, Timeout: 10 * time.Second})
+	if err != nil {
+		log.Fatal("Invalid telegram retry: ", err)
+	}
+	for _, job := range config.jobs() {
+		_, err = job.Format.template()
+		if err != nil {
+			log.Fatalf("Invalid template of %s: %s", job.Name, err)
+		}
+		if !validMode(job.Mode) {
+			log.Fatalf("Invalid mode of %s: %s", job.Name, job.Mode)
+		}
+		if job.ThresholdShare < 0 || job.ThresholdShare >= 1 {
+			log.Fatalf("Invalid threshold_share of %s: %g is not between 0 and 1", job.Name, job.ThresholdShare)
+		}
+		if !validQuote(job.Format.Quote) {
+			log.Fatalf("Invalid quote of %s: %s. Use btc or eth", job.Name, job.Format.Quote)
+		}
+	}
+	for recipient, quote := range config.Quotes {
+		if !validQuote(quote) {
+			log.Fatalf("Invalid quote of %s: %s. Use btc or eth", recipient, quote)
+		}
+	}
+	for transactionType, policy := range config.Unhandled {
+		err = summary.ValidUnhandled(policy)
+		if err != nil {
+			log.Fatalf("Invalid unhandled policy of %s: %s", transactionType, err)
+		}
+	}
+	if config.Sessions.Enabled {
+		_, err = config.Sessions.boundaries()
+		if err != nil {
+			log.Fatal("Invalid sessions: ", err)
+		}
+	}
+	for i, rule := range config.Ignore {
+		if rule.empty() {
+			log.Fatalf("Invalid ignore rule %d: set an owner, address, symbol, type, blockchain, or hash", i+1)
+		}
+	}
+	if err := config.Alerts.validate(); err != nil {
+		log.Fatal("Invalid alerts: ", err)
+	}
+	if config.Lookups.TTL != "" {
+		ttl, err := time.ParseDuration(config.Lookups.TTL)
+		if err != nil || ttl <= 0 {
+			log.Fatal("Invalid lookups ttl: ", config.Lookups.TTL)
+		}
+	}
+	if config.Health.Stale != "" {
+		stale, err := time.ParseDuration(config.Health.Stale)
+		if err != nil || stale <= 0 {
+			log.Fatal("Invalid health stale: ", config.Health.Stale)
+		}
+	}
+	if config.CoinGecko.StableCoinsTTL != "" {
+		ttl, err := time.ParseDuration(config.CoinGecko.StableCoinsTTL)
+		if err != nil || ttl <= 0 {
+			log.Fatal("Invalid coingecko stable_coins_ttl: ", config.CoinGecko.StableCoinsTTL)
+		}
+	}
+	if !validMissingUSD(config.MissingUSD) {
+		log.Fatalf("Invalid missing_usd %s. Use keep, exclude, or price", config.MissingUSD)
+	}
+	if config.Classify.Enabled {
+		if config.OwnerTypes == nil {
+			config.OwnerTypes = map[string]OwnerType{}
+		}
+		for name, ownerType := range classifiedOwnerTypes {
+			if _, ok := config.ownerType(name); !ok {
+				config.OwnerTypes[name] = ownerType
+			}
+		}
+	}
+	if config.WebApp && !strings.HasPrefix(config.ReportURL, "https://") {
+		log.Fatal("Invalid web_app: telegram only opens https report_url")
+	}
+	for i, rule := range config.Rules {
+		if err := rule.validate(config); err != nil {
+			log.Fatalf("Invalid rule %d: %s", i+1, err)
+		}
+	}
+	if !store.ValidDriver(config.LogDBDriver) {
+		log.Fatalf("Invalid log_db_driver %s. Use postgres or sqlite", config.LogDBDriver)
+	}
+	config.db = store.DB{URL: config.LogDBURL, Driver: config.LogDBDriver, Schema: config.LogDBSchema}
+	config.db.Policy, err = config.LogDBRetry.Policy(retry.Policy{Attempts: 1, Backoff: time.Second, Timeout: 10 * time.Second})
+	if err != nil {
+		log.Fatal("Invalid log_db_retry: ", err)
+	}
+	if config.Shadow != "" {
+		config.shadow = loadShadow(path, config)
+	}
+	config.suggested = map[string]bool{}
+	return config
+}
+
+// flowSection describes how to label and interpret a map of net flows per symbol
+type flowSection struct {
+	name     string // key of the section when stored
+	analyzer string // analyzer that enables the section. defaults to name
+	increase string // title for symbols with positive net flow
+	decrease string // title for symbols with negative net flow
+	// whether a positive net flow of crypto is bullish
+	// stable coins and negative net flows read the opposite way
+	increaseBullish bool
+	// label for crypto instead of bull or bear when its flows are neutral
+	cryptoLabel string
+	// label for crypto with negative net flow instead of the opposite of increaseBullish
+	decreaseLabel string
+}
+
+var (
+	// minting of new crypto means more supply and lower price. bearish
+	// minting of new stable coin suggests conversion from fiat. bullish
+	// burning of crypto means less supply and higher price. bullish
+	// burning of stable coin suggests conversion into fiat. bearish
+	supplySection = flowSection{name: "supply", increase: "Mints:", decrease: "Burns:"}
+	// inflow of crypto suggests whales are looking to sell. bearish
+	// inflow of stable coin suggests whales are looking to buy. bullish
+	// outflow of crypto suggests whales are going to hodl. bullish
+	// outflow of stable coin suggests whales aren't buying. bearish
+	transferSection = flowSection{name: "transfers", increase: "Exchange Inflow:", decrease: "Exchange Outflow:"}
+	// locking of crypto means less supply and higher price. bullish
+	// locking of stable coin suggests less buying. bearish
+	// unlocking of crypto means sell pressure. bearish
+	// unlocking of stable coin suggests more buying. bullish
+	lockSection = flowSection{name: "locks", increase: "Locked:", decrease: "Unlocked:", increaseBullish: true}
+)
+
+// verdict is how a net flow of the symbol in the section reads like bull, bear, or eur onramp
+func (section flowSection) verdict(symbol string, increase bool, config Config) string {
+	bullish := increase == section.increaseBullish
+	peg := nonUSDPeg(symbol, config)
+	stable := summary.IsStableCoin(symbol, config.StableCoins)
+	if peg != "" || stable {
+		bullish = !bullish
+	}
+	switch {
+	case peg != "" && bullish:
+		// says more about demand for that currency than for crypto
+		return peg + " onramp"
+	case peg != "":
+		return peg + " offramp"
+	case section.cryptoLabel != "" && !stable:
+		return section.cryptoLabel
+	case section.decreaseLabel != "" && !increase && !stable:
+		return section.decreaseLabel
+	case bullish:
+		return "bull"
+	}
+	return "bear"
+}
+
+// majors are reported separately from other crypto
+var majors = []string{"btc", "eth"}
+
+// Enrichment is data from outside the window that gives the summary context
+type Enrichment struct {
+	Averages store.Averages
+	Rates    map[string]float64 // how much of each currency one usd is worth
+	// average usd price of each quote asset over the window
+	QuotePrices map[string]float64
+	Reserves    map[string]float64 // usd reserves per exchange entity
+	Liquidity   map[string]float64 // usd value of order book bids per symbol
+	Seasonal    store.Averages     // usual net flow of the same weekday and hour
+	Season      string             // the weekday and hour of Seasonal like Mon 14:00 UTC
+	// realized volatility per symbol
+	Volatility map[string]volatility
+	Prices     map[string]price // current price per symbol
+	History    history          // summaries of the previous windows
+	// net flow of the job by section then symbol so far today
+	Today map[string]map[string]float64
+}
+
+// renderer turns summaries into message lines for one job
+type renderer struct {
+	p          *message.Printer
+	job        Job
+	format     Format
+	enrichment Enrichment
+	config     Config
+	scores     map[string]float64 // sentiment of each symbol of the summary being analyzed
+}
+
+func newRenderer(job Job, enrichment Enrichment, config Config) renderer {
+	return renderer{p: job.Format.printer(), job: job, format: job.Format, enrichment: enrichment, config: config}
+}
+
+// analyzeSummary is the analysis of the job as the text of a chat message
+func analyzeSummary(summary Summary, enrichment Enrichment, job Job, config Config) string {
+	return analyzeMessage(summary, enrichment, job, config).Text()
+}
+
+// analyzeMessage is every section of the analysis of the job in the order they are sent
+func analyzeMessage(s Summary, enrichment Enrichment, job Job, config Config) summary.Message {
+	r := newRenderer(job, enrichment, config)
+	r.scores = r.sentimentScores(s)
+	averages := enrichment.Averages
+	var sections []summary.Section
+	sections = append(sections, r.flaggedSections(s.Flagged)...)
+	sections = append(sections, r.watchedSections(s.Watched)...)
+	sections = append(sections, r.sentimentSections()...)
+	sections = append(sections, r.analyzeFlows(s.Supply, averages[supplySection.name], supplySection)...)
+	sections = append(sections, r.analyzeFlows(s.Transfers, averages[transferSection.name], transferSection)...)
+	sections = append(sections, r.chainSections(s.Chains)...)
+	sections = append(sections, r.symbolChainSections(s.SymbolChains)...)
+	sections = append(sections, r.analyzeFlows(s.Derivatives, averages[derivativesSection.name], derivativesSection)...)
+	sections = append(sections, r.ownerTypeSections(s.Owners)...)
+	sections = append(sections, r.analyzeFlows(s.Staking, averages[stakingSection.name], stakingSection)...)
+	sections = append(sections, r.analyzeFlows(s.Custody, averages[custodySection.name], custodySection)...)
+	sections = append(sections, r.analyzeFlows(s.Locks, averages[lockSection.name], lockSection)...)
+	sections = append(sections, r.bridgeSections(s.Bridges)...)
+	sections = append(sections, r.rotationSections(s.Rotations)...)
+	sections = append(sections, r.breakdownSections(s.Exchanges, s.ExchangeSymbols)...)
+	sections = append(sections, r.reserveSections(s.Exchanges)...)
+	if len(sections) > 0 {
+		// only context for the flows above
+		sections = append(sections, r.concentrationSections(s.Movers)...)
+		sections = append(sections, r.paceSections(s.sections(), s.Buckets)...)
+		sections = append(sections, r.todaySections()...)
+	}
+	return summary.Message{Sections: sections}
+}
+
+// bridgeSections are the significant flows that moved chains
+func (r renderer) bridgeSections(bridges map[summary.BridgeFlow]float64) []summary.Section {
+	if !r.job.analyzes(bridgeAnalyzer) {
+		return nil
+	}
+	var lines []summary.Line
+	for flow, value := range bridges {
+		if value < r.job.threshold() {
+			continue
+		}
+		// neither bull nor bear. the asset just moved chains
+		lines = append(lines, summary.Line{
+			Text:   r.p.Sprintf("%s: %s→%s %s", r.code(fmt.Sprintf("%-5s", strings.ToUpper(flow.Symbol))), flow.From, flow.To, r.amount(value)),
+			Indent: 2,
+			Symbol: flow.Symbol,
+			Value:  value,
+		})
+	}
+	if len(lines) < 1 {
+		return nil
+	}
+	sortLines(lines)
+	return []summary.Section{{Name: bridgeAnalyzer, Title: "Bridge Flows:", Lines: lines}}
+}
+
+// rotationSections are the significant stablecoin burns that were minted again as another
+func (r renderer) rotationSections(rotations map[summary.Rotation]float64) []summary.Section {
+	if !r.job.analyzes(rotationAnalyzer) {
+		return nil
+	}
+	var lines []summary.Line
+	for rotation, value := range rotations {
+		if value < r.job.threshold() {
+			continue
+		}
+		// the same dollars changed issuer. neither bull nor bear
+		lines = append(lines, summary.Line{
+			Text: r.p.Sprintf("%s→%s: %s (neutral)",
+				r.code(strings.ToUpper(rotation.From)), r.code(strings.ToUpper(rotation.To)), r.amount(value)),
+			Indent:  2,
+			Group:   rotation.From + "→" + rotation.To,
+			Value:   value,
+			Verdict: "neutral",
+		})
+	}
+	if len(lines) < 1 {
+		return nil
+	}
+	sortLines(lines)
+	return []summary.Section{{Name: rotationAnalyzer, Title: "Stablecoin Rotations:", Lines: lines}}
+}
+
+// sortLines orders lines of map entries by their text so messages don't change between runs
+func sortLines(lines []summary.Line) {
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].Text < lines[j].Text
+	})
+}
+
+// analyzeFlows lists significant net flows under the section's titles
+// grouped by asset class with subtotals
+func (r renderer) analyzeFlows(flows, averages map[string]float64, section flowSection) []summary.Section {
+	analyzer := section.analyzer
+	if analyzer == "" {
+		analyzer = section.name
+	}
+	if !r.job.analyzes(analyzer) {
+		return nil
+	}
+	increases := map[string]map[string]float64{}
+	decreases := map[string]map[string]float64{}
+	for key, value := range flows {
+		if math.Abs(value) < r.job.threshold() {
+			// sum of inflow and outflow might be insignificant. ignore
+			continue
+		}
+		class := assetClass(key, r.config)
+		side := increases
+		if value < 0 {
+			side = decreases
+		}
+		if side[class] == nil {
+			side[class] = map[string]float64{}
+		}
+		side[class][key] = value
+	}
+	var sections []summary.Section
+	if len(increases) > 0 {
+		sections = append(sections, summary.Section{Name: section.name, Title: section.increase, Lines: r.topLines(increases, averages, section, true)})
+	}
+	if len(decreases) > 0 {
+		sections = append(sections, summary.Section{Name: section.name, Title: section.decrease, Lines: r.topLines(decreases, averages, section, false)})
+	}
+	return sections
+}
+
+// topLines lists the most significant symbols up to the job's top
+// the rest are rolled up into one line so busy windows stay readable
+func (r renderer) topLines(groups map[string]map[string]float64, averages map[string]float64, section flowSection, increase bool) []summary.Line {
+	type flow struct {
+		class  string
+		symbol string
+		value  float64
+	}
+	var flows []flow
+	for class, group := range groups {
+		for symbol, value := range group {
+			flows = append(flows, flow{class: class, symbol: symbol, value: value})
+		}
+	}
+	top := r.job.Top
+	if top < 1 || len(flows) <= top {
+		return r.groupLines(groups, averages, section, increase)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return r.significant(flows[i].symbol, flows[i].value, flows[j].symbol, flows[j].value)
+	})
+	listed := map[string]map[string]float64{}
+	for _, f := range flows[:top] {
+		if listed[f.class] == nil {
+			listed[f.class] = map[string]float64{}
+		}
+		listed[f.class][f.symbol] = f.value
+	}
+	rest := 0.0
+	for _, f := range flows[top:] {
+		rest += f.value
+	}
+	lines := r.groupLines(listed, averages, section, increase)
+	return append(lines, summary.Line{
+		Text:   r.p.Sprintf("…and %d more totaling %s", len(flows)-top, r.amount(math.Abs(rest))),
+		Indent: 1,
+		Value:  rest,
+	})
+}
+
+// groupLines renders each asset class with its subtotal followed by its symbols, largest first
+func (r renderer) groupLines(groups map[string]map[string]float64, averages map[string]float64, section flowSection, increase bool) []summary.Line {
+	var lines []summary.Line
+	var classes []string
+	for _, category := range r.config.Categories {
+		classes = append(classes, category.Name)
+	}
+	for _, class := range append(classes, assetClasses...) {
+		group, ok := groups[class]
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(group))
+		subtotal := 0.0
+		for key, value := range group {
+			keys = append(keys, key)
+			subtotal += value
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return r.significant(keys[i], group[keys[i]], keys[j], group[keys[j]])
+		})
+		lines = append(lines, summary.Line{Text: r.p.Sprintf("%s: %s", r.italic(class), r.amount(math.Abs(subtotal))), Indent: 1, Group: class, Value: subtotal})
+		for _, key := range keys {
+			verdict := section.verdict(key, increase, r.config)
+			m := r.p.Sprintf("%s: %s", r.code(fmt.Sprintf("%-5s", strings.ToUpper(key))), r.amount(math.Abs(group[key])))
+			score, scored := r.scores[key]
+			if scored && (verdict == "bull" || verdict == "bear") {
+				// how every flow of the symbol reads together instead of this one alone
+				m += r.p.Sprintf(" (%+.0f)", score)
+			} else {
+				m += " (" + verdict + ")"
+				score = 0
+			}
+			if marketCap := r.marketCap(key); marketCap > 0 {
+				m += " = " + r.share(math.Abs(group[key])/marketCap) + " of mcap"
+			}
+			if p, ok := r.enrichment.Prices[key]; ok && r.config.CoinGecko.Prices {
+				// what the flow is supposed to predict
+				m += " @ " + r.price(p)
+			}
+			if average := averages[key]; average > 0 {
+				m += r.p.Sprintf(" %+.0f%% vs 7d avg", (math.Abs(group[key])-average)/average*100)
+			}
+			if past := r.enrichment.History.Averages[section.name][key]; past > 0 {
+				m += r.p.Sprintf(" %+.0f%% vs %s", (math.Abs(group[key])-past)/past*100, r.enrichment.History.Label)
+			}
+			if usual := r.enrichment.Seasonal[section.name][key]; usual > 0 {
+				m += r.p.Sprintf(" %+.0f%% vs usual %s", (math.Abs(group[key])-usual)/usual*100, r.enrichment.Season)
+			}
+			if liquidity := r.enrichment.Liquidity[key]; liquidity > 0 && increase && section.name == transferSection.name {
+				// what selling all of the inflow would eat into
+				m += r.p.Sprintf(" ≈ %.1f%% of bid depth", group[key]/liquidity*100)
+			}
+			if v, ok := r.enrichment.Volatility[key]; ok {
+				m += r.p.Sprintf(" vol %.0f%%", v.Recent*100)
+				if v.quiet(r.config.Volatility) {
+					// large flows into a quiet market
+					m += r.p.Sprintf(" (quiet vs %.0f%% usual)", v.Usual*100)
+				}
+			}
+			lines = append(lines, summary.Line{Text: m, Indent: 2, Group: class, Symbol: key, Value: group[key], Verdict: verdict, Score: score})
+		}
+	}
+	return lines
+}
+
+// assetClasses in the order they are reported
+var assetClasses = []string{"Majors", "Stablecoins", "Non-USD stables", "Altcoins"}
+
+// assetClass is the configured category of the symbol or its default asset class
+func assetClass(symbol string, config Config) string {
+	for _, category := range config.Categories {
+		if summary.ContainsSymbol(symbol, category.Symbols) {
+			return category.Name
+		}
+	}
+	if nonUSDPeg(symbol, config) != "" {
+		return "Non-USD stables"
+	}
+	if summary.IsStableCoin(symbol, config.StableCoins) {
+		return "Stablecoins"
+	}
+	if summary.ContainsSymbol(symbol, majors) {
+		return "Majors"
+	}
+	return "Altcoins"
+}
+
+// nonUSDPeg is the uppercase fiat currency the symbol is pegged to if it isn't usd
+func nonUSDPeg(symbol string, config Config) string {
+	peg := strings.ToUpper(config.Pegs[strings.ToLower(symbol)])
+	if peg == "USD" {
+		return ""
+	}
+	return peg
+}
+
+// feeContext is the current ethereum base fee and bitcoin mempool fees
+// fee spikes alongside whale movement suggest urgency
+type feeContext struct {
+	eth        bool
+	ethGwei    float64
+	btc        bool
+	btcFastest int
+	btcHour    int
+}
+
+func fetchFeeContext(config FeeConfig) feeContext {
+	var fees feeContext
+	if config.EthRPCURL != "" {
+		gwei, err := fetchEthBaseFee(config.EthRPCURL)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			fees.eth, fees.ethGwei = true, gwei
+		}
+	}
+	if config.BTCFeeURL != "" {
+		var estimate struct {
+			FastestFee int `json:"fastestFee"`
+			HourFee    int `json:"hourFee"`
+		}
+		err := getJSON(config.BTCFeeURL, &estimate)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			fees.btc, fees.btcFastest, fees.btcHour = true, estimate.FastestFee, estimate.HourFee
+		}
+	}
+	return fees
+}
+
+func fetchEthBaseFee(rpcURL string) (float64, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{"latest", false},
+	})
+	if err != nil {
+		return 0, err
+	}
+	res, err := http.Post(rpcURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var response struct {
+		Result struct {
+			BaseFeePerGas string `json:"baseFeePerGas"`
+		} `json:"result"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return 0, err
+	}
+	wei, err := strconv.ParseUint(strings.TrimPrefix(response.Result.BaseFeePerGas, "0x"), 16, 64)
+	if err != nil {
+		return 0, err
+	}
+	return float64(wei) / 1000000000, nil
+}
+
+// globalContext is total crypto market cap and btc dominance to frame the flows
+type globalContext struct {
+	MarketCap    float64
+	Change       float64 // percent change of market cap in 24h
+	BTCDominance float64
+}
+
+func fetchGlobalContext(config CoinGeckoConfig) (globalContext, error) {
+	var response struct {
+		Data struct {
+			TotalMarketCap      map[string]float64 `json:"total_market_cap"`
+			MarketCapPercentage map[string]float64 `json:"market_cap_percentage"`
+			MarketCapChange     float64            `json:"market_cap_change_percentage_24h_usd"`
+		} `json:"data"`
+	}
+	err := getCoinGecko(config, "/global", nil, &response)
+	if err != nil {
+		return globalContext{}, err
+	}
+	return globalContext{
+		MarketCap:    response.Data.TotalMarketCap["usd"],
+		Change:       response.Data.MarketCapChange,
+		BTCDominance: response.Data.MarketCapPercentage["btc"],
+	}, nil
+}
+
+func getCoinGecko(config CoinGeckoConfig, path string, params url.Values, v interface{}) error {
+	base, err := url.Parse(config.baseURL() + path)
+	if err != nil {
+		return err
+	}
+	base.RawQuery = params.Encode()
+	req, err := http.NewRequest("GET", base.String(), nil)
+	if err != nil {
+		return err
+	}
+	if config.APIKey != "" {
+		req.Header.Set("x-cg-demo-api-key", config.APIKey)
+	}
+	return doJSON(req, v)
+}
+
+func getJSON(request_url string, v interface{}) error {
+	req, err := http.NewRequest("GET", request_url, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, v)
+}
+
+func doJSON(req *http.Request, v interface{}) error {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Path, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// what to do with transactions whale alert has no usd value for
+const (
+	missingUSDKeep    = "keep"    // aggregate them as zero
+	missingUSDExclude = "exclude" // leave them out and count them
+	missingUSDPrice   = "price"   // price them at their timestamp with coingecko and exclude the rest
+)
+
+func validMissingUSD(policy string) bool {
+	return policy == "" || policy == missingUSDKeep || policy == missingUSDExclude || policy == missingUSDPrice
+}
+
+// handleMissingUSD applies the missing_usd policy to transactions without a usd value
+// and returns what remains with a line per symbol of what was left out
+func handleMissingUSD(config Config, transactions []whalealert.Transaction) ([]whalealert.Transaction, []string, error) {
+	if config.MissingUSD == "" || config.MissingUSD == missingUSDKeep {
+		return transactions, nil, nil
+	}
+	var missing []int
+	for i, transaction := range transactions {
+		if transaction.AmountUsd <= 0 {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) < 1 {
+		return transactions, nil, nil
+	}
+	var err error
+	priced := map[int]float64{}
+	if config.MissingUSD == missingUSDPrice {
+		priced, err = historicalPrices(config.CoinGecko, transactions, missing)
+	}
+	type excluded struct {
+		count  int
+		amount float64
+	}
+	left := map[string]*excluded{}
+	kept := make([]whalealert.Transaction, 0, len(transactions))
+	next := 0
+	for i, transaction := range transactions {
+		if next >= len(missing) || missing[next] != i {
+			kept = append(kept, transaction)
+			continue
+		}
+		next++
+		if price, ok := priced[i]; ok {
+			transaction.AmountUsd = transaction.Amount * price
+			kept = append(kept, transaction)
+			continue
+		}
+		symbol := strings.ToUpper(transaction.Symbol)
+		if left[symbol] == nil {
+			left[symbol] = &excluded{}
+		}
+		left[symbol].count++
+		left[symbol].amount += transaction.Amount
+	}
+	symbols := make([]string, 0, len(left))
+	for symbol := range left {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	lines := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		lines[i] = fmt.Sprintf("%s: %d transactions of %s", symbol, left[symbol].count, strconv.FormatFloat(left[symbol].amount, 'f', -1, 64))
+	}
+	return kept, lines, err
+}
+
+// historicalPrices is the usd price nearest the timestamp of each missing transaction by index
+// from the coingecko market chart of its symbol around the transactions
+func historicalPrices(config CoinGeckoConfig, transactions []whalealert.Transaction, missing []int) (map[int]float64, error) {
+	bySymbol := map[string][]int{}
+	for _, i := range missing {
+		symbol := strings.ToLower(transactions[i].Symbol)
+		bySymbol[symbol] = append(bySymbol[symbol], i)
+	}
+	symbols := make([]string, 0, len(bySymbol))
+	for symbol := range bySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	ids, err := coinIDs(config, symbols)
+	if err != nil {
+		return nil, err
+	}
+	prices := map[int]float64{}
+	var firstErr error
+	for _, symbol := range symbols {
+		id, ok := ids[symbol]
+		if !ok {
+			continue
+		}
+		indexes := bySymbol[symbol]
+		from, to := int64(transactions[indexes[0]].Timestamp), int64(transactions[indexes[0]].Timestamp)
+		for _, i := range indexes {
+			timestamp := int64(transactions[i].Timestamp)
+			if timestamp < from {
+				from = timestamp
+			}
+			if timestamp > to {
+				to = timestamp
+			}
+		}
+		params := url.Values{}
+		params.Add("vs_currency", "usd")
+		// an hour either side so there are points around every transaction
+		params.Add("from", strconv.FormatInt(from-60*60, 10))
+		params.Add("to", strconv.FormatInt(to+60*60, 10))
+		var chart struct {
+			Prices [][2]float64 `json:"prices"` // unix milliseconds and price
+		}
+		err := getCoinGecko(config, "/coins/"+url.PathEscape(id)+"/market_chart/range", params, &chart)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("coingecko %s prices: %w", symbol, err)
+			}
+			continue
+		}
+		if len(chart.Prices) < 1 {
+			continue
+		}
+		for _, i := range indexes {
+			timestamp := float64(transactions[i].Timestamp) * 1000
+			nearest := chart.Prices[0]
+			for _, point := range chart.Prices {
+				if math.Abs(point[0]-timestamp) < math.Abs(nearest[0]-timestamp) {
+					nearest = point
+				}
+			}
+			if nearest[1] > 0 {
+				prices[i] = nearest[1]
+			}
+		}
+	}
+	return prices, firstErr
+}
+
+// coinIDs is the coingecko id of each symbol by coingecko.ids or else the coin with the largest market cap
+func coinIDs(config CoinGeckoConfig, symbols []string) (map[string]string, error) {
+	ids := map[string]string{}
+	var unmapped []string
+	for _, symbol := range symbols {
+		found := false
+		for mapped, id := range config.IDs {
+			if strings.EqualFold(mapped, symbol) {
+				ids[symbol] = id
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmapped = append(unmapped, symbol)
+		}
+	}
+	if len(unmapped) < 1 {
+		return ids, nil
+	}
+	params := url.Values{}
+	params.Add("vs_currency", "usd")
+	params.Add("symbols", strings.Join(unmapped, ","))
+	params.Add("order", "market_cap_desc")
+	var markets []struct {
+		ID     string `json:"id"`
+		Symbol string `json:"symbol"`
+	}
+	err := getCoinGecko(config, "/coins/markets", params, &markets)
+	if err != nil {
+		return ids, err
+	}
+	for _, market := range markets {
+		symbol := strings.ToLower(market.Symbol)
+		if _, ok := ids[symbol]; !ok {
+			ids[symbol] = market.ID
+		}
+	}
+	return ids, nil
+}
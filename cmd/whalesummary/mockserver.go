@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enzosv/whalesummary/notify"
+	"github.com/enzosv/whalesummary/whalealert"
+	"github.com/gorilla/websocket"
+)
+
+// mockServer serves canned whale alert, telegram, and other api responses from fixture files
+type mockServer struct {
+	fixtures  string
+	latency   time.Duration
+	errorRate float64
+	limitRate float64 // fraction of whale alert requests answered with 429
+	mu        sync.Mutex
+	messages  int
+}
+
+// runMockServer is the mockserver subcommand
+// point the url of whale_alert, telegram, and coingecko in the config at it to run without live keys
+func runMockServer(args []string) {
+	flags := flag.NewFlagSet("mockserver", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	fixtures := flags.String("fixtures", "fixtures", "directory with transactions.json and other responses")
+	latency := flags.Duration("latency", 0, "delay before every response")
+	errorRate := flags.Float64("error-rate", 0, "fraction of requests between 0 and 1 that fail")
+	limitRate := flags.Float64("limit-rate", 0, "fraction of whale alert requests between 0 and 1 that are rate limited with a Retry-After of a second")
+	flags.Parse(args)
+
+	server := &mockServer{fixtures: *fixtures, latency: *latency, errorRate: *errorRate, limitRate: *limitRate}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/transactions", server.transactions)
+	mux.HandleFunc("/ws", server.stream)
+	mux.HandleFunc("/", server.fixture)
+	log.Printf("mock server listening on %s with fixtures from %s", *addr, *fixtures)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// delay waits for the configured latency and reports whether this request should fail
+func (s *mockServer) delay() bool {
+	time.Sleep(s.latency)
+	return rand.Float64() < s.errorRate
+}
+
+// transactions mimics whale alert filtering and cursor pagination over transactions.json
+func (s *mockServer) transactions(w http.ResponseWriter, r *http.Request) {
+	if s.delay() {
+		writeJSON(w, http.StatusInternalServerError, whalealert.Response{Result: "error", Message: "mock error"})
+		return
+	}
+	if rand.Float64() < s.limitRate {
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusTooManyRequests, whalealert.Response{Result: "error", Message: "mock rate limit"})
+		return
+	}
+	var transactions []whalealert.Transaction
+	err := readFixture(filepath.Join(s.fixtures, "transactions.json"), &transactions)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, whalealert.Response{Result: "error", Message: err.Error()})
+		return
+	}
+	query := r.URL.Query()
+	start, _ := strconv.ParseInt(query.Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(query.Get("end"), 10, 64)
+	min, _ := strconv.ParseFloat(query.Get("min_value"), 64)
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(query.Get("cursor"))
+
+	var matching []whalealert.Transaction
+	for _, transaction := range transactions {
+		timestamp := int64(transaction.Timestamp)
+		// whale alert end is inclusive
+		if timestamp < start || (end > 0 && timestamp > end) || transaction.AmountUsd < min {
+			continue
+		}
+		matching = append(matching, transaction)
+	}
+	if offset > len(matching) {
+		offset = len(matching)
+	}
+	page := matching[offset:]
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	writeJSON(w, http.StatusOK, whalealert.Response{
+		Result:       "success",
+		Cursor:       strconv.Itoa(offset + len(page)),
+		Count:        len(page),
+		Transactions: page,
+	})
+}
+
+// stream replays transactions.json as websocket alerts one second apart after a subscription
+func (s *mockServer) stream(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var subscription map[string]interface{}
+	err = conn.ReadJSON(&subscription)
+	if err != nil {
+		return
+	}
+	conn.WriteJSON(map[string]interface{}{"type": "subscribed_alerts", "id": subscription["id"]})
+	var transactions []whalealert.Transaction
+	err = readFixture(filepath.Join(s.fixtures, "transactions.json"), &transactions)
+	if err != nil {
+		return
+	}
+	for _, transaction := range transactions {
+		time.Sleep(time.Second + s.latency)
+		alert := map[string]interface{}{
+			"timestamp":        time.Now().Unix(),
+			"blockchain":       transaction.Blockchain,
+			"transaction_type": transaction.TransactionType,
+			"from":             transaction.From.Owner,
+			"to":               transaction.To.Owner,
+			"amounts": []map[string]interface{}{
+				{"symbol": transaction.Symbol, "amount": transaction.Amount, "value_usd": transaction.AmountUsd},
+			},
+			"transaction": map[string]interface{}{
+				"hash": transaction.Hash,
+				"sub_transactions": []map[string]interface{}{
+					{"symbol": transaction.Symbol, "inputs": []whalealert.Wallet{transaction.From}, "outputs": []whalealert.Wallet{transaction.To}},
+				},
+			},
+		}
+		err = conn.WriteJSON(alert)
+		if err != nil {
+			return
+		}
+	}
+	// keep the connection open like the real feed
+	for {
+		_, _, err = conn.ReadMessage()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// fixture handles telegram bot methods and serves any other path from a json file of the same name
+// like /coingecko/global from coingecko/global.json
+// a category is a file in the directory of the path like coingecko/coins/markets/stablecoins.json
+func (s *mockServer) fixture(w http.ResponseWriter, r *http.Request) {
+	if s.delay() {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "description": "mock error"})
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/bot") {
+		s.telegram(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/slack") {
+		s.slack(w, r)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if category := r.URL.Query().Get("category"); category != "" {
+		name += "/" + category
+	}
+	var response interface{}
+	err := readFixture(filepath.Join(s.fixtures, filepath.FromSlash(name)+".json"), &response)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// updates serves the commands in telegram/updates.json that are at or after the offset
+// with none left it waits a second like a short long poll
+func (s *mockServer) updates(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	var updates []notify.Update
+	err := readFixture(filepath.Join(s.fixtures, "telegram", "updates.json"), &updates)
+	if err != nil && !os.IsNotExist(err) {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "description": err.Error()})
+		return
+	}
+	pending := []notify.Update{}
+	for _, update := range updates {
+		if update.ID >= offset {
+			pending = append(pending, update)
+		}
+	}
+	if len(pending) < 1 {
+		time.Sleep(time.Second)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "result": pending})
+}
+
+// telegram prints what would have been sent and acknowledges it
+func (s *mockServer) telegram(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	s.mu.Lock()
+	s.messages++
+	id := s.messages
+	s.mu.Unlock()
+	switch method {
+	case "sendMessage":
+		var payload map[string]interface{}
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "description": err.Error()})
+			return
+		}
+		fmt.Printf("--- sendMessage to %v\n%v\n", payload["chat_id"], payload["text"])
+		if markup, ok := payload["reply_markup"]; ok {
+			buttons, _ := json.Marshal(markup)
+			fmt.Printf("[buttons] %s\n", buttons)
+		}
+	case "getUpdates":
+		s.updates(w, r)
+		return
+	case "getChat":
+		s.chat(w, r)
+		return
+	case "sendPhoto":
+		err := r.ParseMultipartForm(10 << 20)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "description": err.Error()})
+			return
+		}
+		fmt.Printf("--- sendPhoto to %s\n%s\n", r.FormValue("chat_id"), r.FormValue("caption"))
+	default:
+		fmt.Printf("--- %s\n", method)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "result": map[string]interface{}{"message_id": id}})
+}
+
+// chat resolves usernames from telegram/chats.json and any numeric id to itself
+func (s *mockServer) chat(w http.ResponseWriter, r *http.Request) {
+	chatID := r.URL.Query().Get("chat_id")
+	fmt.Printf("--- getChat %s\n", chatID)
+	var chats map[string]notify.Chat
+	err := readFixture(filepath.Join(s.fixtures, "telegram", "chats.json"), &chats)
+	if err != nil && !os.IsNotExist(err) {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "description": err.Error()})
+		return
+	}
+	chat, ok := chats[chatID]
+	if !ok {
+		id, err := strconv.ParseInt(chatID, 10, 64)
+		// like telegram no user has the id of a supergroup without its minus
+		if err != nil || (strings.HasPrefix(chatID, "100") && len(chatID) >= 13) {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "description": "Bad Request: chat not found"})
+			return
+		}
+		chat = notify.Chat{ID: id, Type: "private"}
+		if strings.HasPrefix(chatID, "-100") {
+			chat.Type = "supergroup"
+		} else if id < 0 {
+			chat.Type = "group"
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "result": chat})
+}
+
+// slack prints the fallback text and number of blocks of webhook and chat.postMessage posts
+func (s *mockServer) slack(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Channel string        `json:"channel"`
+		Text    string        `json:"text"`
+		Blocks  []interface{} `json:"blocks"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": err.Error()})
+		return
+	}
+	fmt.Printf("--- slack %s to %s with %d blocks\n%s\n", r.URL.Path, payload.Channel, len(payload.Blocks), payload.Text)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true})
+}
+
+func readFixture(path string, v interface{}) error {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// what -output does with the summary of each window
+const (
+	outputTelegram = "telegram"
+	outputJSON     = "json" // print instead of sending
+	outputBoth     = "both"
+)
+
+func validOutput(output string) bool {
+	return output == outputTelegram || output == outputJSON || output == outputBoth
+}
+
+// payloadWriter prints one payload per line so runs that keep going can be piped too
+// shared by tenants so lines never interleave
+type payloadWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// write refuses payloads that don't match the schema like serve does
+func (p *payloadWriter) write(payload summary.Payload) error {
+	err := payload.Validate()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(body, '\n'))
+	return err
+}
+
+// windowPayload is the summary of every transaction of the window with how each flow reads
+// empty sections are left out
+func (config Config) windowPayload(window timeWindow, windowSummary Summary, unhandled []whalealert.Transaction) summary.Payload {
+	sections := map[string]map[string]float64{}
+	verdicts := map[string]map[string]string{}
+	for name, flows := range windowSummary.sections() {
+		if len(flows) < 1 {
+			continue
+		}
+		sections[name] = flows
+		section, ok := config.flowSection(name)
+		if !ok {
+			continue
+		}
+		verdicts[name] = map[string]string{}
+		for symbol, value := range flows {
+			verdicts[name][symbol] = section.verdict(symbol, value > 0, config)
+		}
+	}
+	payload := summary.NewPayload(window.start, window.end, sections)
+	payload.Unhandled = summary.GroupUnhandled(unhandled, config.Unhandled)
+	payload.Verdicts = verdicts
+	return payload
+}
+
+// flowSection is the section stored under the name including configured owner types
+func (config Config) flowSection(name string) (flowSection, bool) {
+	for _, section := range []flowSection{supplySection, transferSection, derivativesSection, stakingSection, custodySection, lockSection} {
+		if section.name == name {
+			return section, true
+		}
+	}
+	if ownerType, ok := config.ownerType(name); ok {
+		return ownerType.section(name), true
+	}
+	return flowSection{}, false
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// OwnerType is how transfers into and out of an owner type other than exchange are read
+type OwnerType struct {
+	Title string `json:"title"` //heading like OTC Desk. defaults to the owner type
+	// whether crypto moving into this owner type is bullish
+	// false reads like exchanges where inflow suggests selling
+	InflowBullish bool `json:"inflow_bullish"`
+	Neutral       bool `json:"neutral"` //label crypto flows neutral instead of bull or bear
+}
+
+// section is how the flows of the owner type are rendered and stored
+// enabled by the transfers analyzer since these are transfers too
+func (t OwnerType) section(name string) flowSection {
+	title := t.Title
+	if title == "" {
+		title = name
+	}
+	section := flowSection{name: name, analyzer: transferSection.name, increase: title + " Inflow:", decrease: title + " Outflow:", increaseBullish: t.InflowBullish}
+	if t.Neutral {
+		section.cryptoLabel = "neutral"
+	}
+	return section
+}
+
+// ownerTypeFlows nets transfers into and out of each configured owner type per symbol
+// transfers touching an exchange are returned with the rest for the exchange sections
+func ownerTypeFlows(transactions []whalealert.Transaction, config Config) (map[string]map[string]float64, []whalealert.Transaction) {
+	if len(config.OwnerTypes) < 1 {
+		return nil, transactions
+	}
+	flows := map[string]map[string]float64{}
+	var others []whalealert.Transaction
+	for _, transaction := range transactions {
+		from := strings.ToLower(transaction.From.OwnerType)
+		to := strings.ToLower(transaction.To.OwnerType)
+		_, fromTyped := config.ownerType(from)
+		_, toTyped := config.ownerType(to)
+		if transaction.TransactionType != whalealert.TRANSFER.String() || from == "exchange" || to == "exchange" || (!fromTyped && !toTyped) {
+			others = append(others, transaction)
+			continue
+		}
+		if from == to {
+			// internal
+			continue
+		}
+		symbol := summary.RemapSymbol(transaction.Symbol, config.Remap)
+		if toTyped {
+			if flows[to] == nil {
+				flows[to] = map[string]float64{}
+			}
+			flows[to][symbol] += transaction.AmountUsd
+			continue
+		}
+		if flows[from] == nil {
+			flows[from] = map[string]float64{}
+		}
+		flows[from][symbol] -= transaction.AmountUsd
+	}
+	return flows, others
+}
+
+// overrideOwners gives every owner in owner_overrides the owner type it is treated as
+// for labels whale alert calls an exchange that are really custodians or payment processors
+func (config Config) overrideOwners(transactions []whalealert.Transaction) []whalealert.Transaction {
+	if len(config.OwnerOverrides) < 1 {
+		return transactions
+	}
+	overrides := map[string]string{}
+	for owner, ownerType := range config.OwnerOverrides {
+		overrides[strings.ToLower(strings.TrimSpace(owner))] = strings.ToLower(strings.TrimSpace(ownerType))
+	}
+	overridden := make([]whalealert.Transaction, len(transactions))
+	for i, transaction := range transactions {
+		if ownerType, ok := overrides[strings.ToLower(transaction.From.Owner)]; ok && transaction.From.Owner != "" {
+			transaction.From.OwnerType = ownerType
+		}
+		if ownerType, ok := overrides[strings.ToLower(transaction.To.Owner)]; ok && transaction.To.Owner != "" {
+			transaction.To.OwnerType = ownerType
+		}
+		overridden[i] = transaction
+	}
+	return overridden
+}
+
+func (config Config) ownerType(name string) (OwnerType, bool) {
+	for key, ownerType := range config.OwnerTypes {
+		if strings.EqualFold(key, name) {
+			return ownerType, true
+		}
+	}
+	return OwnerType{}, false
+}
+
+// ownerTypeSections renders each configured owner type in alphabetical order
+func (r renderer) ownerTypeSections(flows map[string]map[string]float64) []summary.Section {
+	names := make([]string, 0, len(flows))
+	for name := range flows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sections []summary.Section
+	for _, name := range names {
+		ownerType, _ := r.config.ownerType(name)
+		section := ownerType.section(name)
+		sections = append(sections, r.analyzeFlows(flows[name], r.enrichment.Averages[name], section)...)
+	}
+	return sections
+}
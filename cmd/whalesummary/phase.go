@@ -0,0 +1,178 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/summary"
+)
+
+// PhaseConfig is how the digest reads accumulation and distribution from the timing of exchange flows
+type PhaseConfig struct {
+	Symbols int     `json:"symbols"` //coins labeled with their phase. 0 disables
+	Lag     int     `json:"lag"`     //most hours a coin flow can follow the stablecoin flow it answers. defaults to 6
+	Min     float64 `json:"min"`     //usd of matched flows a coin needs for a label. defaults to 1,000,000
+}
+
+func (config PhaseConfig) lag() int64 {
+	if config.Lag > 0 {
+		return int64(config.Lag) * 60 * 60
+	}
+	return 6 * 60 * 60
+}
+
+func (config PhaseConfig) min() float64 {
+	if config.Min > 0 {
+		return config.Min
+	}
+	return 1000000
+}
+
+// phase is how the exchange flows of a coin lined up with the stablecoin flows before them
+type phase struct {
+	symbol string
+	// stablecoin inflow followed by outflow of the coin. bought and withdrawn
+	accumulated    float64
+	accumulatedLag float64
+	// inflow of the coin followed by stablecoin outflow. sold and cashed out
+	distributed    float64
+	distributedLag float64
+}
+
+func (p phase) label() string {
+	switch {
+	case p.accumulated >= 2*p.distributed:
+		return "accumulation"
+	case p.distributed >= 2*p.accumulated:
+		return "distribution"
+	}
+	return "mixed"
+}
+
+// flowMatch pairs each later flow with the earlier flows before it up to lag, oldest first
+// a flow in the same period counts as following since periods don't say which came first
+// returns the matched usd and its average lag in seconds
+func flowMatch(periods []int64, earlier, later map[int64]float64, lag int64) (float64, float64) {
+	remaining := make([]float64, len(periods))
+	for i, period := range periods {
+		remaining[i] = earlier[period]
+	}
+	matched, lagged := 0.0, 0.0
+	oldest := 0
+	for j, period := range periods {
+		want := later[period]
+		for oldest < j && periods[oldest] < period-lag {
+			oldest++
+		}
+		for i := oldest; i <= j && want > 0; i++ {
+			if remaining[i] <= 0 {
+				continue
+			}
+			take := remaining[i]
+			if take > want {
+				take = want
+			}
+			remaining[i] -= take
+			want -= take
+			matched += take
+			lagged += take * float64(period-periods[i])
+		}
+	}
+	if matched == 0 {
+		return 0, 0
+	}
+	return matched, lagged / matched
+}
+
+// phases match the exchange flows of every coin against the stablecoin flows of the same periods
+// stablecoin inflow followed by coin outflow reads as accumulation and coin inflow followed by stablecoin outflow as distribution
+// every coin is matched against all the stablecoin flow so coins bought together share it
+// flows are exchange net flow per symbol and period like FetchPeriodFlows. positive is inflow
+func phases(periods []int64, flows map[string]map[int64]float64, config Config) []phase {
+	stableIn, stableOut := map[int64]float64{}, map[int64]float64{}
+	for symbol, values := range flows {
+		if !summary.IsStableCoin(symbol, config.StableCoins) {
+			continue
+		}
+		for period, value := range values {
+			stableIn[period] += value
+		}
+	}
+	for period, value := range stableIn {
+		if value < 0 {
+			stableOut[period] = -value
+			delete(stableIn, period)
+		}
+	}
+	lag := config.Digest.Phases.lag()
+	var labeled []phase
+	for symbol, values := range flows {
+		if summary.IsStableCoin(symbol, config.StableCoins) {
+			continue
+		}
+		coinIn, coinOut := map[int64]float64{}, map[int64]float64{}
+		for period, value := range values {
+			if value > 0 {
+				coinIn[period] = value
+			} else {
+				coinOut[period] = -value
+			}
+		}
+		p := phase{symbol: symbol}
+		p.accumulated, p.accumulatedLag = flowMatch(periods, stableIn, coinOut, lag)
+		p.distributed, p.distributedLag = flowMatch(periods, coinIn, stableOut, lag)
+		if p.accumulated+p.distributed < config.Digest.Phases.min() {
+			continue
+		}
+		labeled = append(labeled, p)
+	}
+	sort.Slice(labeled, func(i, j int) bool {
+		a, b := labeled[i].accumulated+labeled[i].distributed, labeled[j].accumulated+labeled[j].distributed
+		if a != b {
+			return a > b
+		}
+		return labeled[i].symbol < labeled[j].symbol
+	})
+	return labeled
+}
+
+// phaseLines are the phase labels of the coins with the most matched flow for the caption of the digest
+func phaseLines(periods []int64, flows map[string]map[int64]float64, config Config) []string {
+	if config.Digest.Phases.Symbols < 1 {
+		return nil
+	}
+	labeled := phases(periods, flows, config)
+	if len(labeled) > config.Digest.Phases.Symbols {
+		labeled = labeled[:config.Digest.Phases.Symbols]
+	}
+	// the caption is plain text
+	r := newRenderer(Job{Format: config.Telegram.Format}, Enrichment{}, config)
+	var lines []string
+	for _, p := range labeled {
+		line := strings.ToUpper(p.symbol) + " " + p.label() + ":"
+		if p.accumulated > 0 {
+			line += r.p.Sprintf(" %s withdrawn after stablecoin inflow (%s)", r.amount(p.accumulated), lagLabel(p.accumulatedLag))
+		}
+		if p.distributed > 0 {
+			if p.accumulated > 0 {
+				line += ","
+			}
+			line += r.p.Sprintf(" %s deposited before stablecoin outflow (%s)", r.amount(p.distributed), lagLabel(p.distributedLag))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// lagLabel is an average lag like lag 2h10m
+func lagLabel(seconds float64) string {
+	if seconds < 60 {
+		return "same period"
+	}
+	lag := strings.TrimSuffix(time.Duration(seconds*float64(time.Second)).Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(lag, "h0m") {
+		lag = strings.TrimSuffix(lag, "0m")
+	}
+	return "lag " + lag
+}
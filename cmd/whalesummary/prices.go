@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// price is the current usd price of a symbol, its percent change in 24h, and its usd market cap
+type price struct {
+	USD       float64
+	Change    float64
+	MarketCap float64
+}
+
+// symbols or ids in one request to coingecko markets
+// small enough that a slow request holds up few symbols
+const pricesPerRequest = 25
+
+// fetchPrices is the price of each symbol from coingecko markets
+// symbols shared by several coins are the one with the largest market cap unless mapped to an id
+// symbols priced within the ttl of the lookups cache are not requested again
+func fetchPrices(config CoinGeckoConfig, symbols []string, l *lookups) (map[string]price, error) {
+	prices := map[string]price{}
+	// coingecko ignores symbols when ids are given so they are separate requests
+	var ids, unmapped []string
+	symbolOf := map[string]string{}
+	mapped := map[string]string{}
+	for symbol, id := range config.IDs {
+		mapped[strings.ToLower(symbol)] = id
+	}
+	for _, symbol := range symbols {
+		var cached price
+		if l.get("price "+symbol, &cached) {
+			if cached.USD > 0 {
+				prices[symbol] = cached
+			}
+			continue
+		}
+		if id := mapped[symbol]; id != "" {
+			ids = append(ids, id)
+			symbolOf[id] = symbol
+			continue
+		}
+		unmapped = append(unmapped, symbol)
+	}
+	type lookup struct {
+		param  string
+		values []string
+	}
+	var requests []lookup
+	for _, all := range []lookup{{"ids", ids}, {"symbols", unmapped}} {
+		for start := 0; start < len(all.values); start += pricesPerRequest {
+			end := start + pricesPerRequest
+			if end > len(all.values) {
+				end = len(all.values)
+			}
+			requests = append(requests, lookup{all.param, all.values[start:end]})
+		}
+	}
+	var mu sync.Mutex
+	var firstErr error
+	l.each(len(requests), func(i int) {
+		params := url.Values{}
+		params.Add("vs_currency", "usd")
+		params.Add(requests[i].param, strings.Join(requests[i].values, ","))
+		params.Add("order", "market_cap_desc")
+		var markets []struct {
+			ID        string  `json:"id"`
+			Symbol    string  `json:"symbol"`
+			Price     float64 `json:"current_price"`
+			Change    float64 `json:"price_change_percentage_24h"`
+			MarketCap float64 `json:"market_cap"`
+		}
+		err := getCoinGecko(config, "/coins/markets", params, &markets)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		found := map[string]price{}
+		for _, market := range markets {
+			symbol := strings.ToLower(market.Symbol)
+			if original, ok := symbolOf[market.ID]; ok {
+				symbol = original
+			}
+			if _, ok := found[symbol]; ok || market.Price <= 0 {
+				// already have the larger coin
+				continue
+			}
+			found[symbol] = price{USD: market.Price, Change: market.Change, MarketCap: market.MarketCap}
+		}
+		for _, value := range requests[i].values {
+			symbol := value
+			if original, ok := symbolOf[value]; ok && requests[i].param == "ids" {
+				symbol = original
+			}
+			// cached without a price too so coins coingecko doesn't list aren't asked for every run
+			l.put("price "+symbol, found[symbol])
+			if p, ok := found[symbol]; ok {
+				prices[symbol] = p
+			}
+		}
+	})
+	return prices, firstErr
+}
+
+// price is a unit price in the recipient's currency like $97,000.00 (+2.1% 24h)
+// prices under a dollar keep four significant digits
+func (r renderer) price(p price) string {
+	value := p.USD
+	symbol := "$"
+	currency := r.format.currency()
+	if rate, ok := r.enrichment.Rates[currency]; ok && currency != "usd" {
+		value *= rate
+		symbol, ok = currencySymbols[currency]
+		if !ok {
+			symbol = strings.ToUpper(currency) + " "
+		}
+	}
+	decimals := 2
+	if value > 0 && value < 1 {
+		decimals = 3 - int(math.Floor(math.Log10(value)))
+	}
+	return symbol + r.p.Sprintf("%.*f", decimals, value) + r.p.Sprintf(" (%+.1f%% 24h)", p.Change)
+}
+
+// marketCap is the usd market cap of the symbol if flows are compared to it
+func (r renderer) marketCap(symbol string) float64 {
+	if !r.config.CoinGecko.MarketCap {
+		return 0
+	}
+	return r.enrichment.Prices[symbol].MarketCap
+}
+
+// significant orders symbols by their net flow as a share of market cap
+// $10M means more for a small cap than for btc
+// symbols without a market cap follow by usd value
+func (r renderer) significant(a string, aValue float64, b string, bValue float64) bool {
+	aCap, bCap := r.marketCap(a), r.marketCap(b)
+	if (aCap > 0) != (bCap > 0) {
+		return aCap > 0
+	}
+	if aCap > 0 {
+		return math.Abs(aValue)/aCap > math.Abs(bValue)/bCap
+	}
+	return math.Abs(aValue) > math.Abs(bValue)
+}
+
+// share is a ratio as a percent with two significant digits like 0.0037%
+func (r renderer) share(ratio float64) string {
+	percent := ratio * 100
+	decimals := 0
+	if percent > 0 {
+		decimals = 1 - int(math.Floor(math.Log10(percent)))
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+	return r.p.Sprintf("%.*f%%", decimals, percent)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// quoteAsset is a crypto flows can be expressed in instead of fiat
+type quoteAsset struct {
+	id     string // coingecko id
+	symbol string
+}
+
+var quoteAssets = map[string]quoteAsset{
+	"btc": {id: "bitcoin", symbol: "₿"},
+	"eth": {id: "ethereum", symbol: "Ξ"},
+}
+
+func validQuote(quote string) bool {
+	_, ok := quoteAssets[strings.ToLower(quote)]
+	return quote == "" || ok
+}
+
+func (f Format) quote() string {
+	return strings.ToLower(f.Quote)
+}
+
+// quoted is whether any job or recipient expresses flows in a quote asset
+func (config Config) quoted(jobs []Job) bool {
+	if len(config.Quotes) > 0 {
+		return true
+	}
+	for _, job := range jobs {
+		if job.Format.quote() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchQuotePrices is the average usd price of every quote asset over the window
+// a window too short to have a price point in it uses the point nearest its middle
+func fetchQuotePrices(config CoinGeckoConfig, window timeWindow) (map[string]float64, error) {
+	prices := map[string]float64{}
+	var firstErr error
+	for quote, asset := range quoteAssets {
+		params := url.Values{}
+		params.Add("vs_currency", "usd")
+		params.Add("from", strconv.FormatInt(window.start-60*60, 10))
+		params.Add("to", strconv.FormatInt(window.end+60*60, 10))
+		var chart struct {
+			Prices [][2]float64 `json:"prices"` // unix milliseconds and price
+		}
+		err := getCoinGecko(config, "/coins/"+asset.id+"/market_chart/range", params, &chart)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("coingecko %s prices: %w", quote, err)
+			}
+			continue
+		}
+		var sum float64
+		count := 0
+		middle := float64(window.start+window.end) / 2 * 1000
+		var nearest [2]float64
+		for _, point := range chart.Prices {
+			if point[0] >= float64(window.start)*1000 && point[0] <= float64(window.end)*1000 {
+				sum += point[1]
+				count++
+			}
+			if nearest[1] == 0 || math.Abs(point[0]-middle) < math.Abs(nearest[0]-middle) {
+				nearest = point
+			}
+		}
+		if count > 0 {
+			prices[quote] = sum / float64(count)
+		} else if nearest[1] > 0 {
+			prices[quote] = nearest[1]
+		}
+	}
+	return prices, firstErr
+}
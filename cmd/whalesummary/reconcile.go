@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// ReconcileConfig is how -reconcile fetches reported windows again to catch transactions whale alert revised
+// like ones removed by a reorg
+type ReconcileConfig struct {
+	Hours       int     `json:"hours"`        //how far back windows are fetched again. defaults to 6
+	Change      float64 `json:"change"`       //share a reported flow must change by to send a correction. defaults to 0.2
+	RecipientID string  `json:"recipient_id"` //chat corrections are sent to. defaults to telegram.recipient_id
+}
+
+func (config ReconcileConfig) hours() int {
+	if config.Hours > 0 {
+		return config.Hours
+	}
+	return 6
+}
+
+func (config ReconcileConfig) change() float64 {
+	if config.Change > 0 {
+		return config.Change
+	}
+	return 0.2
+}
+
+// transactionKey is the primary key of a logged transaction
+func transactionKey(transaction whalealert.Transaction) string {
+	return strings.Join([]string{transaction.Blockchain, transaction.Hash, transaction.Symbol, transaction.From.Address, transaction.To.Address}, "|")
+}
+
+// revised is whether whale alert changed what it says about a transaction since it was logged
+func revised(logged, fetched whalealert.Transaction) bool {
+	changed := func(a, b float64) bool {
+		return math.Abs(a-b) > 1e-9*math.Max(math.Abs(a), math.Abs(b))
+	}
+	return changed(logged.Amount, fetched.Amount) || changed(logged.AmountUsd, fetched.AmountUsd) ||
+		logged.Timestamp != fetched.Timestamp || logged.TransactionType != fetched.TransactionType ||
+		logged.From.Owner != fetched.From.Owner || logged.From.OwnerType != fetched.From.OwnerType ||
+		logged.To.Owner != fetched.To.Owner || logged.To.OwnerType != fetched.To.OwnerType
+}
+
+// transactionChanges counts the logged transactions whale alert no longer has, the ones it revised, and the new ones
+func transactionChanges(logged, fetched []whalealert.Transaction) (removed, changed, added int) {
+	before := map[string]whalealert.Transaction{}
+	for _, transaction := range logged {
+		before[transactionKey(transaction)] = transaction
+	}
+	seen := map[string]bool{}
+	for _, transaction := range fetched {
+		key := transactionKey(transaction)
+		seen[key] = true
+		previous, ok := before[key]
+		if !ok {
+			added++
+		} else if revised(previous, transaction) {
+			changed++
+		}
+	}
+	for key := range before {
+		if !seen[key] {
+			removed++
+		}
+	}
+	return removed, changed, added
+}
+
+// flowCorrection is a reported net flow that changed after whale alert revised its transactions
+type flowCorrection struct {
+	section, symbol string
+	before, after   float64
+}
+
+// flowCorrections are the flows that were or would now be reported and moved by at least the change share
+// largest change first
+func flowCorrections(before, after map[string]map[string]float64, threshold, change float64) []flowCorrection {
+	var corrections []flowCorrection
+	add := func(section, symbol string) {
+		old, now := before[section][symbol], after[section][symbol]
+		largest := math.Max(math.Abs(old), math.Abs(now))
+		if largest < threshold || math.Abs(now-old) < largest*change {
+			return
+		}
+		corrections = append(corrections, flowCorrection{section: section, symbol: symbol, before: old, after: now})
+	}
+	for section, flows := range before {
+		for symbol := range flows {
+			add(section, symbol)
+		}
+	}
+	for section, flows := range after {
+		for symbol := range flows {
+			if _, ok := before[section][symbol]; !ok {
+				add(section, symbol)
+			}
+		}
+	}
+	sort.Slice(corrections, func(i, j int) bool {
+		return math.Abs(corrections[i].after-corrections[i].before) > math.Abs(corrections[j].after-corrections[j].before)
+	})
+	return corrections
+}
+
+// prepareFetched takes the same steps as a report before it logs transactions
+// for commands that log windows they don't report
+func prepareFetched(config Config, transactions []whalealert.Transaction) []whalealert.Transaction {
+	transactions, _ = normalizeAmounts(transactions, newTokenRegistry(config.Tokens))
+	transactions, _ = summary.Dedupe(transactions)
+	transactions, _, err := handleMissingUSD(config, transactions)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return transactions
+}
+
+// reconcile fetches every window summarized in the last reconcile.hours again
+// the log is updated to what whale alert says now and flows that materially changed are corrected
+func reconcile(ctx context.Context, config Config, now time.Time) error {
+	if !config.db.Enabled() {
+		return fmt.Errorf("reconcile needs log_db_url")
+	}
+	periods, err := store.FetchPeriods(ctx, config.db, now.Add(-time.Duration(config.Reconcile.hours())*time.Hour).Unix(), now.Unix())
+	if err != nil {
+		return err
+	}
+	recipient := config.Reconcile.RecipientID
+	if recipient == "" {
+		recipient = config.Telegram.RecipientID
+	}
+	r := newRenderer(Job{Name: "reconcile", Format: config.recipientFormat(config.Telegram.Format, recipient)}, Enrichment{}, config)
+	threshold := minThreshold(config.jobs())
+	entities, err := loadEntities(ctx, config.db, config.Entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+	// a report left out what an earlier overlapping window reported so the recomputed flows do too
+	// every period is in the log already so only the windows reconciled before it count
+	config.rerun = true
+	config.seen = newSeen()
+	for _, period := range periods {
+		fetched, err := whalealert.FetchTransactions(config.WhaleAlert, period.Start, period.End)
+		if err != nil {
+			// a partial fetch would look like removed transactions
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("reconcile %s: %s", time.Unix(period.Start, 0).Format("Jan 2 3:04PM"), err))
+			continue
+		}
+		fetched = prepareFetched(config, fetched)
+		reported, _ := config.skipSeen(fetched)
+		logged, err := store.FetchTransactions(ctx, config.db, period.Start, period.End, "", reportLimit)
+		if err != nil {
+			return err
+		}
+		if len(logged) >= reportLimit {
+			// the rest of the log would look like new transactions and be added again
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("reconcile %s: %d or more logged transactions can't be compared", time.Unix(period.Start, 0).Format("Jan 2 3:04PM"), reportLimit))
+			continue
+		}
+		if len(fetched) < 1 && len(logged) > 0 {
+			// a reorg doesn't empty a window. more likely the plan can't fetch that far back
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("reconcile %s: whale alert returned none of %d logged transactions", time.Unix(period.Start, 0).Format("Jan 2 3:04PM"), len(logged)))
+			continue
+		}
+		removed, changed, added := transactionChanges(logged, fetched)
+		if removed+changed+added < 1 {
+			continue
+		}
+		before, err := store.FetchSummary(ctx, config.db, period.Start, period.Start+1)
+		if err != nil {
+			return err
+		}
+		transactions, _ := classifyOwners(config, normalizeOwners(reported, entities), entities)
+		windowSummary, _ := summarize(transactions, config)
+		after := windowSummary.sections()
+		window := timeWindow{start: period.Start, end: period.End}
+		if config.logs(func() []string {
+			return append([]string{fmt.Sprintf("%d transactions in place of %d", len(fetched), len(logged))}, summaryWrites(window, after)...)
+		}) {
+			err = store.ReplaceTransactions(ctx, config.db, period.Start, period.End, fetched)
+			if err != nil {
+				return err
+			}
+			err = store.ReplaceSummary(ctx, config.db, period.Start, period.End, after)
+			if err != nil {
+				return err
+			}
+		}
+		label := time.Unix(period.Start, 0).Format("Jan 2 3:04PM") + " - " + time.Unix(period.End, 0).Format("3:04PM")
+		config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("reconciled %s: %d removed, %d revised, %d new transactions", label, removed, changed, added))
+		corrections := flowCorrections(before, after, threshold, config.Reconcile.change())
+		if len(corrections) < 1 {
+			continue
+		}
+		flow := func(value float64) string {
+			if value == 0 {
+				return "none"
+			}
+			return r.signed(value)
+		}
+		msg := []string{"✏️ " + r.bold("Correction") + " for " + r.escape(label)}
+		for _, correction := range corrections {
+			msg = append(msg, r.p.Sprintf("  %s %s: %s → %s", r.code(strings.ToUpper(correction.symbol)), r.escape(correction.section), flow(correction.before), flow(correction.after)))
+		}
+		config.Telegram.SendFormatted(recipient, strings.Join(msg, "\n"), r.format.telegramParseMode())
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/enzosv/whalesummary/notify"
+	"github.com/enzosv/whalesummary/retry"
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+func exchangeDeposit(hash string, timestamp int) whalealert.Transaction {
+	transaction := seenTransfer(hash, "b", timestamp)
+	transaction.Symbol = "btc"
+	transaction.To.Owner, transaction.To.OwnerType = "binance", "exchange"
+	transaction.Amount, transaction.AmountUsd = 10, 1000000
+	return transaction
+}
+
+// a window overlapping an earlier one is recomputed without what the earlier one reported
+func TestReconcileSkipsOverlappingWindows(t *testing.T) {
+	ctx := context.Background()
+	start := 1672531200
+	logged := []whalealert.Transaction{exchangeDeposit("0x1", start+50), exchangeDeposit("0x2", start+150), exchangeDeposit("0x3", start+250)}
+	fetched := append(logged, exchangeDeposit("0x4", start+260))
+	whales := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		until, _ := strconv.Atoi(r.URL.Query().Get("end"))
+		response := whalealert.Response{Result: "success"}
+		for _, transaction := range fetched {
+			if transaction.Timestamp >= since && transaction.Timestamp <= until {
+				response.Transactions = append(response.Transactions, transaction)
+			}
+		}
+		response.Count = len(response.Transactions)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer whales.Close()
+	telegram := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer telegram.Close()
+
+	policy := retry.Policy{Attempts: 1, Timeout: 10 * time.Second}
+	config := Config{
+		db:         store.DB{URL: filepath.Join(t.TempDir(), "log.db"), Driver: store.SQLite, Policy: policy},
+		WhaleAlert: whalealert.Config{URL: whales.URL, Limit: 100, Policy: policy},
+		Telegram:   TelegramConfig{Telegram: notify.Telegram{URL: telegram.URL, Policy: policy}, LogID: "1"},
+		seen:       newSeen(),
+	}
+	sections := func(transactions ...whalealert.Transaction) map[string]map[string]float64 {
+		windowSummary, _ := summarize(transactions, config)
+		return windowSummary.sections()
+	}
+	err := store.LogTransactions(ctx, config.db, logged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// what the reports logged. the second already left out 0x2
+	periods := []struct {
+		start, end int64
+		sections   map[string]map[string]float64
+	}{
+		{int64(start), int64(start + 200), sections(logged[0], logged[1])},
+		{int64(start + 100), int64(start + 300), sections(logged[2])},
+	}
+	for _, period := range periods {
+		err = store.LogSummary(ctx, config.db, period.start, period.end, period.sections)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = reconcile(ctx, config, time.Unix(int64(start+3600), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.FetchSummary(ctx, config.db, periods[1].start, periods[1].start+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0x3 and 0x4 without the 0x2 the first window reported
+	want := sections(fetched[2], fetched[3])[transferSection.name]
+	if len(want) < 1 || !reflect.DeepEqual(got[transferSection.name], want) {
+		t.Errorf("reconciled transfers of %v, want %v", got[transferSection.name], want)
+	}
+}
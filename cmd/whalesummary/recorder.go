@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// exchange is a recorded http request and its response
+type exchange struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body"`
+}
+
+// recorder captures or replays every outbound http exchange in a directory
+// secrets are removed from urls so fixtures can be committed
+type recorder struct {
+	dir    string
+	replay bool
+	next   http.RoundTripper
+	mu     sync.Mutex
+	seen   map[string]int
+}
+
+var (
+	apiKeyPattern   = regexp.MustCompile(`(api_key|x_cg_demo_api_key)=[^&]*`)
+	botTokenPattern = regexp.MustCompile(`/bot[^/]+/`)
+)
+
+// useRecorder routes http.DefaultClient through a recorder
+func useRecorder(dir string, replay bool) error {
+	if !replay {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	http.DefaultClient.Transport = &recorder{dir: dir, replay: replay, next: http.DefaultTransport, seen: map[string]int{}}
+	return nil
+}
+
+func redactURL(raw string) string {
+	redacted := apiKeyPattern.ReplaceAllString(raw, "$1=redacted")
+	return botTokenPattern.ReplaceAllString(redacted, "/botredacted/")
+}
+
+// path is unique per request and per repetition of the same request so replays happen in order
+func (r *recorder) path(method, url string, body []byte) string {
+	sum := sha256.Sum256(append([]byte(method+" "+url+"\n"), body...))
+	key := hex.EncodeToString(sum[:8])
+	r.mu.Lock()
+	r.seen[key]++
+	n := r.seen[key]
+	r.mu.Unlock()
+	return filepath.Join(r.dir, fmt.Sprintf("%s-%d.json", key, n))
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	url := redactURL(req.URL.String())
+	path := r.path(req.Method, url, body)
+	if r.replay {
+		var recorded exchange
+		err := readFixture(path, &recorded)
+		if err != nil {
+			return nil, fmt.Errorf("no recording of %s %s: %w", req.Method, url, err)
+		}
+		return &http.Response{
+			Status:     http.StatusText(recorded.Status),
+			StatusCode: recorded.Status,
+			Header:     recorded.Header,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(recorded.Body)),
+			Request:    req,
+		}, nil
+	}
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	responseBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+	recorded, err := json.MarshalIndent(exchange{
+		Method:      req.Method,
+		URL:         url,
+		RequestBody: string(body),
+		Status:      res.StatusCode,
+		Header:      res.Header,
+		Body:        string(responseBody),
+	}, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return res, ioutil.WriteFile(path, recorded, 0644)
+}
+
+// recordWindow saves the window of a recorded run so a replay without flags uses the same one
+func recordWindow(dir string, window timeWindow) error {
+	body, err := json.Marshal(map[string]int64{"start": window.start, "end": window.end})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "window.json"), body, 0644)
+}
+
+func replayWindow(dir string) (timeWindow, error) {
+	var recorded map[string]int64
+	err := readFixture(filepath.Join(dir, "window.json"), &recorded)
+	return timeWindow{start: recorded["start"], end: recorded["end"]}, err
+}
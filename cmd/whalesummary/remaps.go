@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// suggestRemaps sends the wrapped or bridged variants the remap doesn't cover yet to the log chat
+// so the remap grows with what whale alert reports
+// with a log each is only sent the first time it's seen while the log keeps counting what it moved
+func suggestRemaps(config Config, window timeWindow, transactions []whalealert.Transaction) {
+	if config.readOnly {
+		// production suggests for the same transactions
+		return
+	}
+	known := append(append([]string(nil), majors...), config.StableCoins...)
+	suggestions := summary.SuggestRemaps(transactions, config.Remap, known, config.RemapIgnore)
+	if len(suggestions) > 0 && config.logs(func() []string { return []string{fmt.Sprintf("%d remap suggestions", len(suggestions))} }) {
+		fresh, err := store.LogRemapSuggestions(context.Background(), config.db, window.end, suggestions)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			suggestions = fresh
+		}
+	}
+	r := newRenderer(Job{Name: "remap", Format: config.recipientFormat(config.Telegram.Format, config.Telegram.LogID)}, Enrichment{}, config)
+	var lines []string
+	for _, suggestion := range suggestions {
+		if config.suggested[suggestion.Symbol] {
+			continue
+		}
+		config.suggested[suggestion.Symbol] = true
+		lines = append(lines, fmt.Sprintf("%s → %s %s", r.code(suggestion.Symbol), r.code(suggestion.Remap), r.amount(suggestion.AmountUsd)))
+	}
+	if len(lines) < 1 {
+		return
+	}
+	lines = append(lines, fmt.Sprintf("add them to %s or to %s if they are distinct", r.code("remap"), r.code("remap_ignore")))
+	config.Telegram.SendFormatted(config.Telegram.LogID, "remap suggestions:\n"+strings.Join(lines, "\n"), r.format.telegramParseMode())
+}
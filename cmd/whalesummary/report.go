@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/notify"
+	"github.com/enzosv/whalesummary/store"
+)
+
+// reportLimit is the most stored transactions a full report summarizes
+const reportLimit = 100000
+
+// reportButton links to the full report of the window if the job's top left symbols out
+// or to the app of every window with web_app
+func (config Config) reportButton(job Job, jobSummary Summary, enrichment Enrichment, window timeWindow) *notify.Button {
+	if config.ReportURL == "" {
+		return nil
+	}
+	params := url.Values{}
+	params.Add("job", job.Name)
+	params.Add("start", strconv.FormatInt(window.start, 10))
+	params.Add("end", strconv.FormatInt(window.end, 10))
+	if config.WebApp {
+		return &notify.Button{Text: "Explore", URL: strings.TrimSuffix(config.ReportURL, "/") + "/app?" + params.Encode()}
+	}
+	if job.Top < 1 {
+		return nil
+	}
+	full := job
+	full.Top = 0
+	if analyzeSummary(jobSummary, enrichment, full, config) == analyzeSummary(jobSummary, enrichment, job, config) {
+		// nothing was rolled up
+		return nil
+	}
+	return &notify.Button{Text: "Full report", URL: strings.TrimSuffix(config.ReportURL, "/") + "/report?" + params.Encode()}
+}
+
+// recipientButton opens the app inside telegram for private chats
+// groups and channels reject web app buttons so they open its url instead
+func (config Config) recipientButton(button notify.Button, recipient string) notify.Button {
+	if !config.WebApp || !privateChat(recipient) {
+		return button
+	}
+	return notify.Button{Text: button.Text, WebApp: &notify.WebApp{URL: button.URL}}
+}
+
+// privateChat is whether the chat id is of a user. groups and channels are negative
+func privateChat(chatID string) bool {
+	id, err := strconv.ParseInt(chatID, 10, 64)
+	return err == nil && id > 0
+}
+
+// report is the analysis of a job without its top as a web page or its sections as json with format=json
+// summarized from the stored transactions of the window so it can be opened after the message was sent
+func (s *apiServer) report(w http.ResponseWriter, r *http.Request) {
+	window, err := s.window(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jobs := s.config.jobs()
+	job := jobs[0]
+	if name := r.URL.Query().Get("job"); name != "" {
+		found := false
+		for _, j := range jobs {
+			if j.Name == name {
+				job, found = j, true
+				break
+			}
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	transactions, err := store.FetchTransactions(r.Context(), s.config.db, window.start, window.end, "", reportLimit)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "cannot read transactions", http.StatusInternalServerError)
+		return
+	}
+	entities, err := loadEntities(context.Background(), s.config.db, s.config.Entities)
+	if err != nil {
+		log.Println(err)
+	}
+	transactions = normalizeOwners(transactions, entities)
+	config := s.config
+	config.StableCoins, err = withCategoryStableCoins(config.CoinGecko, config.StableCoins, time.Now())
+	if err != nil {
+		log.Println(err)
+	}
+	job = job.adapt(transactions, config.Remap)
+	if job.filters() {
+		transactions = job.filter(transactions, config.Remap)
+	}
+	jobSummary, _ := summarize(transactions, config)
+	jobSummary.Buckets = bucketFlows(transactions, window, config)
+	job.Top = 0
+	if r.URL.Query().Get("format") == "json" {
+		// the sections for other renderers to lay out as plain text
+		job.Format.ParseMode = "none"
+		writeJSON(w, http.StatusOK, analyzeMessage(jobSummary, Enrichment{}, job, config))
+		return
+	}
+	job.Format.ParseMode = "html"
+	analysis := analyzeSummary(jobSummary, Enrichment{}, job, config)
+	if analysis == "" {
+		analysis = "Nothing to report."
+	}
+	title := fmt.Sprintf("%s %s to %s", job.Name,
+		time.Unix(window.start, 0).UTC().Format("Jan 2 15:04"), time.Unix(window.end, 0).UTC().Format("Jan 2 15:04 UTC"))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!doctype html>\n<title>%s</title>\n<h3>%s</h3>\n<pre>%s</pre>\n", html.EscapeString(title), html.EscapeString(title), analysis)
+}
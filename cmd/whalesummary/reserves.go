@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// ReservesConfig is where known exchange reserves come from
+type ReservesConfig struct {
+	URL    string             `json:"url"`    //json object of exchange to usd reserves like a proof of reserves aggregator
+	Static map[string]float64 `json:"static"` //usd reserves per exchange. overrides the url
+}
+
+const reserveAnalyzer = "reserves"
+
+// exchangeFlows nets inflow per exchange entity across all symbols
+// like the symbol summary, transfers between exchanges are ignored
+func exchangeFlows(transactions []whalealert.Transaction) map[string]float64 {
+	flows := map[string]float64{}
+	for _, transaction := range transactions {
+		if transaction.TransactionType != whalealert.TRANSFER.String() || transaction.From.OwnerType == transaction.To.OwnerType {
+			continue
+		}
+		if transaction.From.OwnerType == "exchange" && transaction.From.Owner != "" {
+			flows[transaction.From.Owner] -= transaction.AmountUsd
+			continue
+		}
+		if transaction.To.OwnerType == "exchange" && transaction.To.Owner != "" {
+			flows[transaction.To.Owner] += transaction.AmountUsd
+		}
+	}
+	return flows
+}
+
+// fetchReserves loads usd reserves per exchange entity
+func fetchReserves(config ReservesConfig, entities map[string]string) (map[string]float64, error) {
+	reserves := map[string]float64{}
+	var err error
+	if config.URL != "" {
+		var fetched map[string]float64
+		err = getJSON(config.URL, &fetched)
+		for exchange, value := range fetched {
+			reserves[ownerEntity(exchange, entities)] += value
+		}
+	}
+	for exchange, value := range config.Static {
+		reserves[ownerEntity(exchange, entities)] = value
+	}
+	return reserves, err
+}
+
+// reserveSections expresses each exchange's net flow relative to its reserves, most significant first
+// $50M into a small exchange matters more than into binance
+func (r renderer) reserveSections(flows map[string]float64) []summary.Section {
+	if !r.job.analyzes(reserveAnalyzer) || len(r.enrichment.Reserves) < 1 {
+		return nil
+	}
+	share := map[string]float64{}
+	var exchanges []string
+	for exchange, value := range flows {
+		reserves := r.enrichment.Reserves[exchange]
+		if reserves <= 0 || math.Abs(value) < r.job.threshold() {
+			continue
+		}
+		share[exchange] = value / reserves * 100
+		exchanges = append(exchanges, exchange)
+	}
+	if len(exchanges) < 1 {
+		return nil
+	}
+	sort.Slice(exchanges, func(i, j int) bool {
+		return math.Abs(share[exchanges[i]]) > math.Abs(share[exchanges[j]])
+	})
+	section := summary.Section{Name: reserveAnalyzer, Title: "Exchange Reserves:"}
+	for _, exchange := range exchanges {
+		section.Lines = append(section.Lines, summary.Line{
+			Text: r.p.Sprintf("%s: %s (%+.2f%% of reserves)",
+				r.escape(strings.Title(exchange)), r.signed(flows[exchange]), share[exchange]),
+			Indent: 2,
+			Group:  exchange,
+			Value:  flows[exchange],
+		})
+	}
+	return []summary.Section{section}
+}
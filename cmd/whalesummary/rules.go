@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// Rule is an alert sent to its own chat when a flow or transaction of the window meets it
+// like btc exchange inflow above 200,000,000 or any transfer above 500,000,000
+type Rule struct {
+	Name         string   `json:"name"`          //shown as the title of the alert
+	Section      string   `json:"section"`       //compares the net flow of each symbol in a section like transfers or supply. empty compares each transaction instead
+	Direction    string   `json:"direction"`     //in for inflows and mints, out for outflows and burns, or either. defaults to either
+	Symbols      []string `json:"symbols"`       //only these symbols. defaults to all
+	Types        []string `json:"types"`         //only transactions of these types like transfer or mint. defaults to all
+	Above        float64  `json:"above"`         //usd the flow or transaction has to exceed
+	Priority     bool     `json:"priority"`      //marks the alert as urgent
+	RecipientID  string   `json:"recipient_id"`  //telegram chat of the alert. defaults to telegram.recipient_id
+	SlackChannel string   `json:"slack_channel"` //also posts the alert to this channel of slack.bot_token
+}
+
+const (
+	ruleIn     = "in"
+	ruleOut    = "out"
+	ruleEither = "either"
+)
+
+// validate explains what is wrong with the rule
+func (rule Rule) validate(config Config) error {
+	if rule.Name == "" || rule.Above <= 0 {
+		return fmt.Errorf("needs a name and a positive above")
+	}
+	if rule.Direction != "" && rule.Direction != ruleIn && rule.Direction != ruleOut && rule.Direction != ruleEither {
+		return fmt.Errorf("direction %s must be in, out, or either", rule.Direction)
+	}
+	if rule.Section == "" {
+		return nil
+	}
+	if _, ok := config.flowSection(rule.Section); !ok {
+		return fmt.Errorf("unknown section %s", rule.Section)
+	}
+	if len(rule.Types) > 0 {
+		return fmt.Errorf("types only apply to rules without a section")
+	}
+	return nil
+}
+
+// matches is whether the usd value in the direction meets the rule
+func (rule Rule) matches(symbol string, value float64) bool {
+	if len(rule.Symbols) > 0 && !summary.ContainsSymbol(symbol, rule.Symbols) {
+		return false
+	}
+	switch rule.Direction {
+	case ruleIn:
+		return value > rule.Above
+	case ruleOut:
+		return -value > rule.Above
+	}
+	return math.Abs(value) > rule.Above
+}
+
+// ruleMessage is the alert of what met the rule in the window, largest first. empty if nothing did
+// sections are the net flows of the window and transactions what they were summarized from
+func (r renderer) ruleMessage(rule Rule, sections map[string]map[string]float64, transactions []whalealert.Transaction) string {
+	type match struct {
+		line  string
+		value float64
+	}
+	var matches []match
+	if rule.Section != "" {
+		section, _ := r.config.flowSection(rule.Section)
+		for symbol, value := range sections[rule.Section] {
+			if !rule.matches(symbol, value) {
+				continue
+			}
+			title := section.decrease
+			if value > 0 {
+				title = section.increase
+			}
+			matches = append(matches, match{r.p.Sprintf("%s %s %s", strings.TrimSuffix(title, ":"),
+				r.code(strings.ToUpper(symbol)), r.amount(math.Abs(value))), math.Abs(value)})
+		}
+	} else {
+		for _, transaction := range transactions {
+			if len(rule.Types) > 0 && !summary.ContainsSymbol(transaction.TransactionType, rule.Types) {
+				continue
+			}
+			// a transaction has no direction of its own
+			if !rule.matches(summary.RemapSymbol(transaction.Symbol, r.config.Remap), math.Abs(transaction.AmountUsd)) {
+				continue
+			}
+			matches = append(matches, match{r.p.Sprintf("%s %s %s from %s to %s", r.code(strings.ToUpper(transaction.Symbol)),
+				r.amount(transaction.AmountUsd), r.link(transaction.TransactionType, r.config.explorerURL(transaction.Blockchain, transaction.Hash)),
+				r.escape(walletName(transaction.From)), r.escape(walletName(transaction.To))), transaction.AmountUsd})
+		}
+	}
+	if len(matches) < 1 {
+		return ""
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].value > matches[j].value
+	})
+	msg := []string{"🔔 " + r.bold(rule.Name)}
+	if rule.Priority {
+		msg[0] = "🚨 " + r.bold("Priority: "+rule.Name)
+	}
+	for _, m := range matches {
+		msg = append(msg, "  "+m.line)
+	}
+	return strings.Join(msg, "\n")
+}
+
+// alertRules sends what met each rule to the rule's chat right after the window is summarized
+func alertRules(config Config, sections map[string]map[string]float64, transactions []whalealert.Transaction) {
+	if len(config.Rules) < 1 {
+		return
+	}
+	transactions = config.overrideOwners(config.ignore(transactions))
+	for _, rule := range config.Rules {
+		recipient := rule.RecipientID
+		if recipient == "" {
+			recipient = config.Telegram.RecipientID
+		}
+		r := newRenderer(Job{Name: rule.Name, Format: config.recipientFormat(config.Telegram.Format, recipient)}, Enrichment{}, config)
+		msg := r.ruleMessage(rule, sections, transactions)
+		if msg == "" {
+			continue
+		}
+		config.Telegram.SendFormatted(recipient, msg, r.format.telegramParseMode())
+		if rule.SlackChannel == "" || !config.Slack.Enabled() {
+			continue
+		}
+		slack := newRenderer(slackJob(Job{Name: rule.Name, Format: config.recipientFormat(Format{}, rule.SlackChannel)}), Enrichment{}, config)
+		err := config.Slack.Send(rule.SlackChannel, slack.ruleMessage(rule, sections, transactions))
+		if err != nil {
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("rule %s slack: %s", rule.Name, err))
+		}
+	}
+}
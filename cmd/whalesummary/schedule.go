@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule runs a job at the times of a cron expression
+type Schedule struct {
+	Cron     string `json:"cron"`     //standard 5 field cron expression in local time
+	Job      string `json:"job"`      //summary or digest
+	Interval int64  `json:"interval"` //minutes summarized up to the scheduled time. defaults to 48
+}
+
+// runSchedules blocks while executing every configured schedule
+func runSchedules(config Config) {
+	if len(config.Schedules) < 1 {
+		log.Fatal("No schedules configured")
+	}
+	c := cron.New()
+	for _, schedule := range config.Schedules {
+		job, err := scheduledJob(config, schedule)
+		if err != nil {
+			log.Fatal("Invalid schedule: ", err)
+		}
+		_, err = c.AddFunc(schedule.Cron, job)
+		if err != nil {
+			log.Fatalf("Invalid schedule %q: %s", schedule.Cron, err)
+		}
+	}
+	c.Run()
+}
+
+func scheduledJob(config Config, schedule Schedule) (func(), error) {
+	switch schedule.Job {
+	case "summary":
+		interval := schedule.Interval
+		if interval < 1 {
+			interval = 48
+		}
+		return func() {
+			// the job may start a little late. summarize up to the minute it was scheduled for
+			end := time.Now().Truncate(time.Minute)
+			runSummary(config, timeWindow{start: end.Add(-time.Duration(interval) * time.Minute).Unix(), end: end.Unix()})
+		}, nil
+	case "digest":
+		return func() {
+			err := sendDigest(context.Background(), config, time.Now())
+			if err != nil {
+				config.Telegram.SendMessage(config.Telegram.LogID, err.Error())
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown job %q. expected summary or digest", schedule.Job)
+}
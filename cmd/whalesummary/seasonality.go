@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type SeasonalityConfig struct {
+	Weeks int `json:"weeks"` //weeks of history for the usual flow of the same weekday and hour. disabled if 0
+}
+
+// season names the weekday and hour seasonal averages are compared against like Mon 14:00 UTC
+func season(start int64) string {
+	t := time.Unix(start, 0).UTC()
+	return fmt.Sprintf("%s %02d:00 UTC", t.Format("Mon"), t.Hour())
+}
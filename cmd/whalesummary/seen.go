@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// seenFor is how long before the newest reported transaction the run remembers the others
+// overlapping and retried windows are recent so older keys only cost memory
+const seenFor = 24 * 60 * 60
+
+// seenTransactions are the transactions every window of a long running process reported
+// by summary.Key to the timestamp of the transaction
+type seenTransactions struct {
+	mu     sync.Mutex
+	keys   map[string]int64
+	newest int64
+}
+
+func newSeen() *seenTransactions {
+	return &seenTransactions{keys: map[string]int64{}}
+}
+
+func (s *seenTransactions) has(key string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok
+}
+
+func (s *seenTransactions) add(key string, timestamp int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = timestamp
+	if timestamp > s.newest {
+		s.newest = timestamp
+	}
+}
+
+// prune forgets transactions more than seenFor before the newest
+func (s *seenTransactions) prune() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, timestamp := range s.keys {
+		if timestamp < s.newest-seenFor {
+			delete(s.keys, key)
+		}
+	}
+}
+
+// skipSeen leaves out transactions an earlier window of this run reported or an earlier run logged
+// so overlapping and retried windows don't count the same flow twice
+// a shadow reports whatever production kept since production logs them before the shadow runs
+// a rerun of a window only skips what its own process reported so the log doesn't empty it
+func (config Config) skipSeen(transactions []whalealert.Transaction) ([]whalealert.Transaction, int) {
+	if config.readOnly || len(transactions) < 1 {
+		return transactions, 0
+	}
+	// keyed like the log stores them. normalizing rewrites the transactions so it works on a copy
+	normalized, _ := normalizeAmounts(append([]whalealert.Transaction(nil), transactions...), newTokenRegistry(config.Tokens))
+	keys := make([]string, len(normalized))
+	since, until := int64(normalized[0].Timestamp), int64(normalized[0].Timestamp)
+	var hashes []string
+	hashed := map[string]bool{}
+	for i, transaction := range normalized {
+		keys[i] = summary.Key(transaction)
+		timestamp := int64(transaction.Timestamp)
+		if timestamp < since {
+			since = timestamp
+		}
+		if timestamp > until {
+			until = timestamp
+		}
+		if !hashed[transaction.Hash] {
+			hashed[transaction.Hash] = true
+			hashes = append(hashes, transaction.Hash)
+		}
+	}
+	logged := map[string]bool{}
+	if config.db.Enabled() && !config.rerun {
+		previous, err := store.FetchLoggedTransactions(context.Background(), config.db, since, until+1, hashes)
+		if err != nil {
+			// better to count twice than to drop a window
+			fmt.Println(err)
+		}
+		for _, transaction := range previous {
+			logged[summary.Key(transaction)] = true
+		}
+	}
+	kept := make([]whalealert.Transaction, 0, len(transactions))
+	// duplicates within the window are left to summary.Dedupe
+	batch := map[string]bool{}
+	for i, transaction := range transactions {
+		if !batch[keys[i]] && (logged[keys[i]] || config.seen.has(keys[i])) {
+			continue
+		}
+		batch[keys[i]] = true
+		config.seen.add(keys[i], int64(transaction.Timestamp))
+		kept = append(kept, transaction)
+	}
+	config.seen.prune()
+	return kept, len(transactions) - len(kept)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/enzosv/whalesummary/retry"
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+func seenTransfer(hash, to string, timestamp int) whalealert.Transaction {
+	return whalealert.Transaction{Blockchain: "ethereum", Symbol: "usdt", TransactionType: "transfer", Hash: hash,
+		From: whalealert.Wallet{Address: "a"}, To: whalealert.Wallet{Address: to}, Amount: 100, AmountUsd: 100, Timestamp: timestamp}
+}
+
+func TestSkipSeenOverlappingWindows(t *testing.T) {
+	config := Config{seen: newSeen()}
+	first := []whalealert.Transaction{seenTransfer("0x1", "b", 100), seenTransfer("0x2", "b", 200)}
+	kept, skipped := config.skipSeen(first)
+	if len(kept) != 2 || skipped != 0 {
+		t.Fatalf("first window kept %d and skipped %d", len(kept), skipped)
+	}
+	// overlaps the first window by one transaction and has another leg of the same hash
+	second := []whalealert.Transaction{seenTransfer("0x2", "b", 200), seenTransfer("0x2", "c", 200), seenTransfer("0x3", "b", 300)}
+	kept, skipped = config.skipSeen(second)
+	if len(kept) != 2 || skipped != 1 || kept[0].To.Address != "c" {
+		t.Errorf("second window kept %+v and skipped %d, want the other leg and 0x3", kept, skipped)
+	}
+	// repeats within a window are left to summary.Dedupe
+	kept, _ = config.skipSeen([]whalealert.Transaction{seenTransfer("0x4", "b", 400), seenTransfer("0x4", "b", 400)})
+	if len(kept) != 2 {
+		t.Errorf("kept %d of a repeat within the window, want both", len(kept))
+	}
+}
+
+// what one run logged is skipped by the next one, which starts with nothing seen
+func TestSkipSeenLogRoundTrip(t *testing.T) {
+	db := store.DB{URL: filepath.Join(t.TempDir(), "log.db"), Driver: store.SQLite, Policy: retry.Policy{Attempts: 1, Timeout: 10 * time.Second}}
+	logged := []whalealert.Transaction{seenTransfer("0x1", "b", 100), seenTransfer("0x2", "b", 200)}
+	err := store.LogTransactions(context.Background(), db, logged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	window := []whalealert.Transaction{seenTransfer("0x1", "b", 100), seenTransfer("0x2", "c", 200), seenTransfer("0x3", "b", 300)}
+	tests := []struct {
+		name   string
+		config Config
+		kept   int
+	}{
+		{"next run skips what was logged", Config{db: db, seen: newSeen()}, 2},
+		{"a rerun of the window keeps it", Config{db: db, seen: newSeen(), rerun: true}, 3},
+		{"a shadow keeps what production kept", Config{db: db, seen: newSeen(), readOnly: true}, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kept, skipped := test.config.skipSeen(append([]whalealert.Transaction(nil), window...))
+			if len(kept) != test.kept || skipped != len(window)-test.kept {
+				t.Errorf("kept %d and skipped %d, want %d kept", len(kept), skipped, test.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+)
+
+const sentimentAnalyzer = "sentiment"
+
+// scoreCap is the most one flow adds to a score however far above its average it is
+const scoreCap = 3.0
+
+// SentimentConfig is how the flows of a symbol are weighed into one score
+type SentimentConfig struct {
+	Weights map[string]float64 `json:"weights"` //section like supply or transfers to how much its flows count. defaults to 1
+	Top     int                `json:"top"`     //symbols listed as most bullish and most bearish. defaults to 3
+}
+
+func (config SentimentConfig) weight(section string) float64 {
+	weight, ok := config.Weights[section]
+	if !ok {
+		return 1
+	}
+	return weight
+}
+
+func (config SentimentConfig) top() int {
+	if config.Top > 0 {
+		return config.Top
+	}
+	return 3
+}
+
+// sentimentScores is how bullish the reported flows of each symbol read from -100 to 100
+// every bull or bear flow counts by its size against its 7 day average, or the threshold without one, up to scoreCap
+// so a mint twice its usual size outweighs a routine inflow of the same symbol
+func (r renderer) sentimentScores(s Summary) map[string]float64 {
+	sums := map[string]float64{}
+	for _, part := range []struct {
+		flows   map[string]float64
+		section flowSection
+	}{
+		{s.Supply, supplySection}, {s.Transfers, transferSection}, {s.Derivatives, derivativesSection},
+		{s.Staking, stakingSection}, {s.Custody, custodySection}, {s.Locks, lockSection},
+	} {
+		analyzer := part.section.analyzer
+		if analyzer == "" {
+			analyzer = part.section.name
+		}
+		weight := r.config.Sentiment.weight(part.section.name)
+		if !r.job.analyzes(analyzer) || weight == 0 {
+			continue
+		}
+		for symbol, value := range part.flows {
+			if math.Abs(value) < r.job.threshold() {
+				continue
+			}
+			direction := 0.0
+			switch part.section.verdict(symbol, value > 0, r.config) {
+			case "bull":
+				direction = 1
+			case "bear":
+				direction = -1
+			default:
+				continue
+			}
+			usual := r.enrichment.Averages[part.section.name][symbol]
+			if usual <= 0 {
+				usual = r.job.threshold()
+			}
+			size := scoreCap
+			if usual > 0 {
+				size = math.Min(math.Abs(value)/usual, scoreCap)
+			}
+			sums[symbol] += direction * weight * size
+		}
+	}
+	scores := make(map[string]float64, len(sums))
+	for symbol, sum := range sums {
+		scores[symbol] = 100 * math.Tanh(sum/scoreCap)
+	}
+	return scores
+}
+
+// sentimentSections rank the symbols with the most bullish and most bearish scores
+func (r renderer) sentimentSections() []summary.Section {
+	if !r.job.analyzes(sentimentAnalyzer) || len(r.scores) < 1 {
+		return nil
+	}
+	symbols := make([]string, 0, len(r.scores))
+	for symbol := range r.scores {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if r.scores[symbols[i]] != r.scores[symbols[j]] {
+			return r.scores[symbols[i]] > r.scores[symbols[j]]
+		}
+		return symbols[i] < symbols[j]
+	})
+	ranked := func(title string, symbols []string, bullish bool) []summary.Line {
+		var listed []string
+		for _, symbol := range symbols {
+			if len(listed) >= r.config.Sentiment.top() {
+				break
+			}
+			if score := r.scores[symbol]; math.Round(score) == 0 || (score > 0) != bullish {
+				break
+			}
+			listed = append(listed, r.p.Sprintf("%s %+.0f", r.code(strings.ToUpper(symbol)), r.scores[symbol]))
+		}
+		if len(listed) < 1 {
+			return nil
+		}
+		return []summary.Line{{Text: title + " " + strings.Join(listed, ", ")}}
+	}
+	reversed := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		reversed[len(symbols)-1-i] = symbol
+	}
+	lines := append(ranked("Most bullish:", symbols, true), ranked("Most bearish:", reversed, false)...)
+	if len(lines) < 1 {
+		return nil
+	}
+	return []summary.Section{{Name: sentimentAnalyzer, Lines: lines}}
+}
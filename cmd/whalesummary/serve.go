@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/summary"
+)
+
+// apiServer answers queries about summaries and transactions stored in the log
+type apiServer struct {
+	config Config
+}
+
+// runServe is the serve subcommand
+// dashboards and other services can query the log instead of parsing telegram messages
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := flags.String("c", "config.json", "config file")
+	addr := flags.String("addr", ":8080", "address to listen on")
+	flags.Parse(args)
+
+	config := parseConfig(*configPath, os.Environ())
+	if !config.db.Enabled() {
+		log.Fatal("serve needs log_db_url")
+	}
+	server := &apiServer{config: config}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/summary", server.summary)
+	mux.HandleFunc("/schema", server.schema)
+	mux.HandleFunc("/transactions", server.transactions)
+	mux.HandleFunc("/flows/", server.flows)
+	mux.HandleFunc("/timeline/", server.timeline)
+	mux.HandleFunc("/report", server.report)
+	mux.HandleFunc("/app", server.app)
+	log.Printf("serving the log on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// window is the start and end query parameters in any format the flags accept
+// defaults to the last day
+func (s *apiServer) window(r *http.Request) (timeWindow, error) {
+	query := r.URL.Query()
+	return resolveWindow(query.Get("start"), query.Get("end"), 24*time.Hour, time.Now())
+}
+
+// summary is the net flow by section then symbol of periods that started in the window
+func (s *apiServer) summary(w http.ResponseWriter, r *http.Request) {
+	window, err := s.window(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	sections, err := store.FetchSummary(r.Context(), s.config.db, window.start, window.end)
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot read summaries"})
+		return
+	}
+	payload := summary.NewPayload(window.start, window.end, sections)
+	err = payload.Validate()
+	if err != nil {
+		// better to fail loudly than break consumers quietly
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "invalid summary"})
+		return
+	}
+	writeJSON(w, http.StatusOK, payload)
+}
+
+// schema is the json schema of the summary payload
+func (s *apiServer) schema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(summary.Schema)
+}
+
+// transactions are the stored transactions of the window, newest first
+// optionally of one symbol and at most limit of them
+func (s *apiServer) transactions(w http.ResponseWriter, r *http.Request) {
+	window, err := s.window(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > 1000 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and 1000"})
+			return
+		}
+	}
+	transactions, err := store.FetchTransactions(r.Context(), s.config.db, window.start, window.end, r.URL.Query().Get("symbol"), limit)
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot read transactions"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"start": window.start, "end": window.end, "transactions": transactions})
+}
+
+// flows is every stored net flow of the symbol in /flows/{symbol} per period of the window
+func (s *apiServer) flows(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimPrefix(r.URL.Path, "/flows/")
+	if symbol == "" || strings.Contains(symbol, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	window, err := s.window(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	flows, err := store.FetchSymbolFlows(r.Context(), s.config.db, symbol, window.start, window.end)
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot read flows"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"start": window.start, "end": window.end, "symbol": strings.ToLower(symbol), "flows": flows})
+}
+
+// timeline is the movements of the address or owner in /timeline/{mover} oldest first
+// with its net position versus exchanges after each. the window defaults to the last 30 days
+func (s *apiServer) timeline(w http.ResponseWriter, r *http.Request) {
+	mover, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/timeline/"))
+	if err != nil || strings.TrimSpace(mover) == "" || strings.Contains(mover, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	window, err := resolveWindow(query.Get("start"), query.Get("end"), 30*24*time.Hour, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	t, err := fetchTimeline(r.Context(), s.config, mover, window)
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot read transactions"})
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Session is a trading session that lasts until the next one starts
+type Session struct {
+	Name  string `json:"name"`
+	Start string `json:"start"` //HH:MM in utc
+}
+
+// SessionsConfig aligns windows to trading sessions since the same flow reads differently in each
+type SessionsConfig struct {
+	Enabled  bool      `json:"enabled"`  //summarize whole sessions in the daemon and when no window is given
+	Sessions []Session `json:"sessions"` //defaults to asia at 00:00, europe at 08:00, and us at 13:30
+}
+
+var defaultSessions = []Session{
+	{Name: "Asia", Start: "00:00"},
+	{Name: "Europe", Start: "08:00"},
+	{Name: "US", Start: "13:30"},
+}
+
+// sessionBoundary is when a session starts after midnight utc
+type sessionBoundary struct {
+	name   string
+	offset time.Duration
+}
+
+// boundaries are the configured sessions in order of their start
+func (config SessionsConfig) boundaries() ([]sessionBoundary, error) {
+	sessions := config.Sessions
+	if len(sessions) < 1 {
+		sessions = defaultSessions
+	}
+	boundaries := make([]sessionBoundary, 0, len(sessions))
+	for _, session := range sessions {
+		start, err := time.Parse("15:04", session.Start)
+		if err != nil {
+			return nil, fmt.Errorf("start of %s: %w", session.Name, err)
+		}
+		boundaries = append(boundaries, sessionBoundary{name: session.Name, offset: time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute})
+	}
+	sort.Slice(boundaries, func(i, j int) bool {
+		return boundaries[i].offset < boundaries[j].offset
+	})
+	for i := 1; i < len(boundaries); i++ {
+		if boundaries[i].offset == boundaries[i-1].offset {
+			return nil, fmt.Errorf("%s and %s start at the same time", boundaries[i-1].name, boundaries[i].name)
+		}
+	}
+	return boundaries, nil
+}
+
+// sessionAt is the name and window of the session t falls in
+// invalid sessions were rejected with the config so they are treated as none
+func (config SessionsConfig) sessionAt(t time.Time) (string, timeWindow) {
+	boundaries, err := config.boundaries()
+	if err != nil || len(boundaries) < 1 {
+		return "", timeWindow{}
+	}
+	day := t.UTC().Truncate(24 * time.Hour)
+	type start struct {
+		name string
+		at   time.Time
+	}
+	// the session at midnight may have started the day before
+	var starts []start
+	for _, d := range []time.Time{day.AddDate(0, 0, -1), day, day.AddDate(0, 0, 1)} {
+		for _, boundary := range boundaries {
+			starts = append(starts, start{name: boundary.name, at: d.Add(boundary.offset)})
+		}
+	}
+	for i := len(starts) - 2; i >= 0; i-- {
+		if !starts[i].at.After(t) {
+			return starts[i].name, timeWindow{start: starts[i].at.Unix(), end: starts[i+1].at.Unix()}
+		}
+	}
+	return "", timeWindow{}
+}
+
+// lastSession is the most recent session that has ended
+func (config SessionsConfig) lastSession(now time.Time) timeWindow {
+	_, current := config.sessionAt(now)
+	_, last := config.sessionAt(time.Unix(current.start-1, 0))
+	return last
+}
+
+// sessionLabel names the session the window starts in like Europe session 08:00-13:30 UTC
+func (config SessionsConfig) sessionLabel(window timeWindow) string {
+	name, session := config.sessionAt(time.Unix(window.start, 0))
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s session %s-%s UTC", name,
+		time.Unix(session.start, 0).UTC().Format("15:04"), time.Unix(session.end, 0).UTC().Format("15:04"))
+}
+
+// nextSession continues the daemon from the last processed end up to the end of its session
+// without one, the first window is the current session
+func (config SessionsConfig) nextSession(last int64, now time.Time) timeWindow {
+	if last == 0 {
+		_, session := config.sessionAt(now)
+		return session
+	}
+	_, session := config.sessionAt(time.Unix(last, 0))
+	session.start = last
+	return session
+}
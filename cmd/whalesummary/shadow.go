@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+)
+
+// loadShadow parses the candidate config that summarizes the same transactions as the config at path
+// the shadow reads the log of its own log_db_url or else the production one but never writes to it
+func loadShadow(path string, config Config) *Config {
+	shadowPath := config.Shadow
+	if !filepath.IsAbs(shadowPath) {
+		shadowPath = filepath.Join(filepath.Dir(path), shadowPath)
+	}
+	// without the environment, which only overrides the main config
+	shadow := parseConfig(shadowPath, nil)
+	if shadow.Shadow != "" || len(shadow.Tenants) > 0 {
+		log.Fatal("A shadow config cannot have a shadow or tenants: ", shadowPath)
+	}
+	if !shadow.db.Enabled() {
+		shadow.db = config.db
+	}
+	shadow.readOnly = true
+	return &shadow
+}
@@ -0,0 +1,24 @@
+package main
+
+// slackParseMode renders markdown that slack mrkdwn mostly shares without escapes it doesn't read
+const slackParseMode = "slack"
+
+// slackJob is the job rendered for slack mrkdwn
+func slackJob(job Job) Job {
+	job.Format.ParseMode = slackParseMode
+	return job
+}
+
+// reportSlack renders the job again for slack and sends it to the job's channel
+func reportSlack(config Config, job Job, summary Summary, enrichment Enrichment, header headerContext, window timeWindow) error {
+	channel := job.SlackChannel
+	if channel == "" {
+		channel = config.Slack.Channel
+	}
+	job.Format = config.recipientFormat(job.Format, channel)
+	msg, err := renderJob(config, job, summary, enrichment, header, window)
+	if err != nil {
+		return err
+	}
+	return config.Slack.Send(channel, msg)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stableCoinCache is the last fetched stablecoins category of coingecko
+type stableCoinCache struct {
+	Fetched int64    `json:"fetched"`
+	Symbols []string `json:"symbols"`
+}
+
+// the most coins a page of coingecko markets has
+const marketsPerPage = 250
+
+func (config CoinGeckoConfig) stableCoinsCache() string {
+	if config.StableCoinsCache == "" {
+		return "stablecoins.json"
+	}
+	return config.StableCoinsCache
+}
+
+func (config CoinGeckoConfig) stableCoinsTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.StableCoinsTTL)
+	if err != nil || ttl <= 0 {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+// withCategoryStableCoins is the configured stable coins and the symbols of coingecko's stablecoins category
+// the category is fetched again once the cache is older than the ttl
+// a stale cache is used when the fetch fails and the configured list alone when there is no cache
+func withCategoryStableCoins(config CoinGeckoConfig, configured []string, now time.Time) ([]string, error) {
+	if !config.StableCoins {
+		return configured, nil
+	}
+	path := config.stableCoinsCache()
+	var cache stableCoinCache
+	var fetchErr error
+	body, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(body, &cache)
+	}
+	if err != nil || now.Sub(time.Unix(cache.Fetched, 0)) >= config.stableCoinsTTL() {
+		symbols, err := fetchStableCoins(config)
+		if err == nil {
+			cache = stableCoinCache{Fetched: now.Unix(), Symbols: symbols}
+			body, _ = json.Marshal(cache)
+			err = ioutil.WriteFile(path, body, 0644)
+			if err != nil {
+				fetchErr = fmt.Errorf("stable coins cache %s: %w", path, err)
+			}
+		} else {
+			fetchErr = fmt.Errorf("coingecko stablecoins: %w", err)
+		}
+	}
+	// configured first so its order wins
+	seen := map[string]bool{}
+	var stableCoins []string
+	for _, symbol := range append(append([]string(nil), configured...), cache.Symbols...) {
+		symbol = strings.ToLower(symbol)
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		stableCoins = append(stableCoins, symbol)
+	}
+	return stableCoins, fetchErr
+}
+
+// fetchStableCoins is the lowercase symbol of every coin in coingecko's stablecoins category
+func fetchStableCoins(config CoinGeckoConfig) ([]string, error) {
+	var symbols []string
+	// a few pages is far more than the category has
+	for page := 1; page <= 4; page++ {
+		params := url.Values{}
+		params.Add("vs_currency", "usd")
+		params.Add("category", "stablecoins")
+		params.Add("order", "market_cap_desc")
+		params.Add("per_page", strconv.Itoa(marketsPerPage))
+		params.Add("page", strconv.Itoa(page))
+		var markets []struct {
+			Symbol string `json:"symbol"`
+		}
+		err := getCoinGecko(config, "/coins/markets", params, &markets)
+		if err != nil {
+			return nil, err
+		}
+		for _, market := range markets {
+			if market.Symbol != "" {
+				symbols = append(symbols, strings.ToLower(market.Symbol))
+			}
+		}
+		if len(markets) < marketsPerPage {
+			break
+		}
+	}
+	if len(symbols) < 1 {
+		// an empty category is more likely a broken response than no stable coins
+		return nil, fmt.Errorf("no coins in the category")
+	}
+	return symbols, nil
+}
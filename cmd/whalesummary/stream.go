@@ -0,0 +1,252 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// what ingest does with a transaction when the buffer is full
+const (
+	overflowDropNewest = "drop_newest"
+	overflowDropOldest = "drop_oldest"
+	overflowBlock      = "block"
+)
+
+// minStreamBackoff is the least wait before reconnecting to a failed stream
+const minStreamBackoff = time.Second
+
+// streamMetrics counts what passed through each stage since the last flush
+type streamMetrics struct {
+	received   int64
+	dropped    int64 // by the overflow policy of a full buffer
+	duplicates int64
+	evicted    int64 // smallest transactions past the max of a window
+}
+
+// take resets the counters and returns what they were
+func (m *streamMetrics) take() streamMetrics {
+	return streamMetrics{
+		received:   atomic.SwapInt64(&m.received, 0),
+		dropped:    atomic.SwapInt64(&m.dropped, 0),
+		duplicates: atomic.SwapInt64(&m.duplicates, 0),
+		evicted:    atomic.SwapInt64(&m.evicted, 0),
+	}
+}
+
+// streamPipeline moves alerts from the websocket to the window being aggregated through a bounded buffer
+// ingest never waits on classifying unless the overflow policy is block
+// and classifying never waits on notifying since flushes only swap the window out
+type streamPipeline struct {
+	queue    chan whalealert.Transaction
+	overflow string
+	registry tokenRegistry
+	metrics  streamMetrics
+
+	mu     sync.Mutex
+	window *streamWindow
+	max    int
+}
+
+func newStreamPipeline(config Config) *streamPipeline {
+	stream := config.WhaleAlert.Stream
+	size := stream.Buffer
+	if size < 1 {
+		size = 10000
+	}
+	max := stream.MaxWindow
+	if max < 1 {
+		max = 50000
+	}
+	overflow := stream.Overflow
+	if overflow == "" {
+		overflow = overflowDropNewest
+	}
+	return &streamPipeline{
+		queue:    make(chan whalealert.Transaction, size),
+		overflow: overflow,
+		registry: newTokenRegistry(config.Tokens),
+		window:   newStreamWindow(),
+		max:      max,
+	}
+}
+
+// ingest queues the transactions of an alert by the overflow policy
+func (p *streamPipeline) ingest(transactions []whalealert.Transaction) {
+	atomic.AddInt64(&p.metrics.received, int64(len(transactions)))
+	for _, transaction := range transactions {
+		switch p.overflow {
+		case overflowBlock:
+			p.queue <- transaction
+		case overflowDropOldest:
+			for queued := false; !queued; {
+				select {
+				case p.queue <- transaction:
+					queued = true
+				default:
+					select {
+					case <-p.queue:
+						atomic.AddInt64(&p.metrics.dropped, 1)
+					default:
+					}
+				}
+			}
+		default:
+			select {
+			case p.queue <- transaction:
+			default:
+				atomic.AddInt64(&p.metrics.dropped, 1)
+			}
+		}
+	}
+}
+
+// classify normalizes queued transactions and aggregates them into the window forever
+func (p *streamPipeline) classify() {
+	for transaction := range p.queue {
+		normalized, _ := normalizeAmounts([]whalealert.Transaction{transaction}, p.registry)
+		p.mu.Lock()
+		switch p.window.add(normalized[0], p.max) {
+		case windowDuplicate:
+			atomic.AddInt64(&p.metrics.duplicates, 1)
+		case windowEvicted:
+			atomic.AddInt64(&p.metrics.evicted, 1)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// take swaps the aggregated window for an empty one
+// in the order the transactions happened since the heap keeps them by value
+func (p *streamPipeline) take() []whalealert.Transaction {
+	p.mu.Lock()
+	transactions := p.window.transactions
+	p.window = newStreamWindow()
+	p.mu.Unlock()
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Timestamp < transactions[j].Timestamp
+	})
+	return transactions
+}
+
+// what adding a transaction to a window did
+const (
+	windowAdded = iota
+	windowDuplicate
+	windowEvicted // the transaction or a smaller one was dropped to stay under the max
+)
+
+// streamWindow is a min heap by usd value so the least significant transaction goes first when full
+type streamWindow struct {
+	transactions []whalealert.Transaction
+	keys         map[string]bool
+}
+
+func newStreamWindow() *streamWindow {
+	return &streamWindow{keys: map[string]bool{}}
+}
+
+func (w *streamWindow) add(transaction whalealert.Transaction, max int) int {
+	key := summary.Key(transaction)
+	if w.keys[key] {
+		return windowDuplicate
+	}
+	if len(w.transactions) < max {
+		w.keys[key] = true
+		heap.Push(w, transaction)
+		return windowAdded
+	}
+	if transaction.AmountUsd <= w.transactions[0].AmountUsd {
+		return windowEvicted
+	}
+	delete(w.keys, summary.Key(w.transactions[0]))
+	w.keys[key] = true
+	w.transactions[0] = transaction
+	heap.Fix(w, 0)
+	return windowEvicted
+}
+
+func (w *streamWindow) Len() int { return len(w.transactions) }
+func (w *streamWindow) Less(i, j int) bool {
+	return w.transactions[i].AmountUsd < w.transactions[j].AmountUsd
+}
+func (w *streamWindow) Swap(i, j int) {
+	w.transactions[i], w.transactions[j] = w.transactions[j], w.transactions[i]
+}
+func (w *streamWindow) Push(x interface{}) {
+	w.transactions = append(w.transactions, x.(whalealert.Transaction))
+}
+func (w *streamWindow) Pop() interface{} {
+	last := w.transactions[len(w.transactions)-1]
+	w.transactions = w.transactions[:len(w.transactions)-1]
+	return last
+}
+
+// runStream subscribes to the whale alert websocket and reports what arrived every flush
+// instead of waiting for the next polled window
+func runStream(config Config) {
+	flush := time.Minute
+	if config.WhaleAlert.Stream.Flush != "" {
+		var err error
+		flush, err = time.ParseDuration(config.WhaleAlert.Stream.Flush)
+		if err != nil || flush <= 0 {
+			log.Fatal("Invalid whale_alert stream flush: ", config.WhaleAlert.Stream.Flush)
+		}
+	}
+	switch config.WhaleAlert.Stream.Overflow {
+	case "", overflowDropNewest, overflowDropOldest, overflowBlock:
+	default:
+		log.Fatal("Invalid whale_alert stream overflow: ", config.WhaleAlert.Stream.Overflow)
+	}
+	config.today = &runningTotals{}
+	if config.shadow != nil {
+		config.shadow.today = &runningTotals{}
+	}
+	pipeline := newStreamPipeline(config)
+	go pipeline.classify()
+	go func() {
+		// a zero backoff would never double and reconnect as fast as the stream fails
+		initial := config.WhaleAlert.Policy.Backoff
+		if initial < minStreamBackoff {
+			initial = minStreamBackoff
+		}
+		backoff := initial
+		for {
+			connected, err := whalealert.Subscribe(context.Background(), config.WhaleAlert, func(transactions []whalealert.Transaction) {
+				config.health.fetchedNow()
+				pipeline.ingest(transactions)
+			})
+			if connected {
+				backoff = initial
+			}
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("whale alert stream: %s", err))
+			time.Sleep(backoff)
+			if backoff < flush {
+				backoff *= 2
+			}
+		}
+	}()
+	start := time.Now()
+	for end := range time.Tick(flush) {
+		transactions := pipeline.take()
+		metrics := pipeline.metrics.take()
+		fmt.Printf("stream: received %d, queued %d, dropped %d, duplicates %d, evicted %d\n",
+			metrics.received, len(pipeline.queue), metrics.dropped, metrics.duplicates, metrics.evicted)
+		if metrics.dropped > 0 || metrics.evicted > 0 {
+			config.Telegram.SendMessage(config.Telegram.LogID, fmt.Sprintf("whale alert stream: dropped %d transactions of a full buffer and %d past the max of a window",
+				metrics.dropped, metrics.evicted))
+		}
+		if len(transactions) > 0 {
+			reportTransactions(config, timeWindow{start: start.Unix(), end: end.Unix()}, transactions, nil)
+		}
+		start = end
+	}
+}
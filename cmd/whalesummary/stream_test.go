@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+func streamTransfers(n int) []whalealert.Transaction {
+	transactions := make([]whalealert.Transaction, n)
+	for i := range transactions {
+		transactions[i] = whalealert.Transaction{Blockchain: "ethereum", Symbol: "usdt", TransactionType: "transfer",
+			Hash: string(rune('a' + i)), Amount: float64(i + 1), AmountUsd: float64(i + 1), Timestamp: 1672531200 + i}
+	}
+	return transactions
+}
+
+// with nothing classifying the buffer fills and the overflow policy decides what is kept
+func TestStreamPipelineOverflow(t *testing.T) {
+	tests := []struct {
+		overflow string
+		kept     []string // hashes left in the buffer
+	}{
+		{"", []string{"a", "b"}},
+		{overflowDropNewest, []string{"a", "b"}},
+		{overflowDropOldest, []string{"d", "e"}},
+	}
+	for _, test := range tests {
+		t.Run(test.overflow, func(t *testing.T) {
+			var config Config
+			config.WhaleAlert.Stream.Buffer = 2
+			config.WhaleAlert.Stream.Overflow = test.overflow
+			p := newStreamPipeline(config)
+			done := make(chan struct{})
+			go func() {
+				p.ingest(streamTransfers(5))
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("ingest blocked on a full buffer")
+			}
+			metrics := p.metrics.take()
+			if metrics.received != 5 || metrics.dropped != 3 {
+				t.Errorf("received %d and dropped %d, want 5 and 3", metrics.received, metrics.dropped)
+			}
+			if again := p.metrics.take(); again.received != 0 || again.dropped != 0 {
+				t.Errorf("metrics not reset after take: %+v", again)
+			}
+			close(p.queue)
+			var kept []string
+			for transaction := range p.queue {
+				kept = append(kept, transaction.Hash)
+			}
+			if len(kept) != len(test.kept) || kept[0] != test.kept[0] || kept[1] != test.kept[1] {
+				t.Errorf("kept %v, want %v", kept, test.kept)
+			}
+		})
+	}
+}
+
+// block waits for a slow classifier instead of dropping
+func TestStreamPipelineBlock(t *testing.T) {
+	var config Config
+	config.WhaleAlert.Stream.Buffer = 2
+	config.WhaleAlert.Stream.Overflow = overflowBlock
+	p := newStreamPipeline(config)
+	done := make(chan struct{})
+	go func() {
+		p.ingest(streamTransfers(3))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("ingest returned with a full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	go p.classify()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ingest still blocked while classifying")
+	}
+	classified := func() int {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.window.transactions)
+	}
+	for deadline := time.Now().Add(time.Second); classified() < 3 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := len(p.take()); got != 3 || p.metrics.take().dropped != 0 {
+		t.Errorf("window has %d transactions, want all 3 and none dropped", got)
+	}
+}
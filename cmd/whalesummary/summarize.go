@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+)
+
+// runSummarize is the summarize subcommand
+// it analyzes a past window again from the transactions in the log without calling whale alert
+// so changes to remaps, thresholds, or stable coins can be seen on windows that were already reported
+// nothing is sent or logged
+func runSummarize(args []string) {
+	flags := flag.NewFlagSet("summarize", flag.ExitOnError)
+	configPath := flags.String("c", "config.json", "config file")
+	startFlag := flags.String("start", "", "inclusive start like -start of a report. defaults to interval before end")
+	endFlag := flags.String("end", "", "exclusive end like -end of a report. defaults to interval after start or the current minute")
+	interval := flags.Int64("interval", 48, "minutes between start and end if either is not provided")
+	jobName := flags.String("job", "", "only analyze the job with this name. defaults to every job")
+	output := flags.String("output", "text", "text for the message of each job or json for the summary of the window")
+	flags.Parse(args)
+
+	if *output != "text" && *output != outputJSON {
+		log.Fatalf("Invalid output %s. Use text or json", *output)
+	}
+	window, err := resolveWindow(*startFlag, *endFlag, time.Duration(*interval)*time.Minute, time.Now())
+	if err != nil {
+		log.Fatal("Invalid window: ", err)
+	}
+	config := parseConfig(*configPath, os.Environ())
+	if !config.db.Enabled() {
+		log.Fatal("summarize needs log_db_url")
+	}
+	// classifying would log the wallet labels it finds
+	config.readOnly = true
+	ctx := context.Background()
+	transactions, err := store.FetchTransactions(ctx, config.db, window.start, window.end, "", reportLimit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(transactions) >= reportLimit {
+		fmt.Fprintf(os.Stderr, "only the newest %d transactions of the window are summarized\n", reportLimit)
+	}
+	config.StableCoins, err = withCategoryStableCoins(config.CoinGecko, config.StableCoins, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	entities, err := loadEntities(ctx, config.db, config.Entities)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	var flagErrs []error
+	config.flagged, flagErrs = loadFlagged(config.Flagged, config.Watchlist)
+	for _, err := range flagErrs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	transactions, classifyErrs := classifyOwners(config, normalizeOwners(transactions, entities), entities)
+	for _, err := range classifyErrs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	windowSummary, unhandled := summarize(transactions, config)
+	if *output == outputJSON {
+		err = (&payloadWriter{w: os.Stdout}).write(config.windowPayload(window, windowSummary, unhandled))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	enrichment := storedEnrichment(ctx, config, window, entities)
+	found := false
+	for _, job := range config.jobs() {
+		if *jobName != "" && job.Name != *jobName {
+			continue
+		}
+		found = true
+		job = job.adapt(transactions, config.Remap)
+		jobSummary := windowSummary
+		jobTransactions := transactions
+		if job.filters() {
+			jobTransactions = job.filter(transactions, config.Remap)
+			jobSummary, _ = summarize(jobTransactions, config)
+		}
+		jobSummary.Buckets = bucketFlows(jobTransactions, window, config)
+		// printed for a terminal instead of a chat
+		job.Format.ParseMode = "none"
+		msg, err := renderJob(config, job, jobSummary, enrichment, headerContext{}, window)
+		if err != nil {
+			log.Fatalf("%s template: %s", job.Name, err)
+		}
+		if analyzeSummary(jobSummary, enrichment, job, config) == "" {
+			msg = "Nothing to report."
+		}
+		fmt.Printf("--- %s %s to %s\n%s\n", job.Name, time.Unix(window.start, 0).UTC().Format("Jan 2 15:04"),
+			time.Unix(window.end, 0).UTC().Format("Jan 2 15:04 UTC"), msg)
+	}
+	if !found {
+		log.Fatalf("no job named %s", *jobName)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// ChainBreakdownConfig is when to split the exchange flow of a symbol into its blockchains
+type ChainBreakdownConfig struct {
+	Enabled bool `json:"enabled"` //also net exchange flows per blockchain then symbol
+	// how large the flow against the net direction must be as a share of the flow with it to be shown. defaults to 0.25
+	Divergence float64 `json:"divergence"`
+}
+
+const symbolChainAnalyzer = "symbol_chains"
+
+func (config ChainBreakdownConfig) divergence() float64 {
+	if config.Divergence > 0 {
+		return config.Divergence
+	}
+	return 0.25
+}
+
+// symbolChainFlows nets transfers into and out of exchanges per symbol then blockchain
+// the same transfers as the exchange flows of Transfers
+func symbolChainFlows(transactions []whalealert.Transaction, tickermap map[string]string) map[string]map[string]float64 {
+	flows := map[string]map[string]float64{}
+	for _, transaction := range transactions {
+		if transaction.TransactionType != whalealert.TRANSFER.String() || transaction.From.OwnerType == transaction.To.OwnerType {
+			continue
+		}
+		value := transaction.AmountUsd
+		if transaction.From.OwnerType == "exchange" {
+			value = -value
+		} else if transaction.To.OwnerType != "exchange" {
+			continue
+		}
+		symbol := summary.RemapSymbol(transaction.Symbol, tickermap)
+		if flows[symbol] == nil {
+			flows[symbol] = map[string]float64{}
+		}
+		flows[symbol][strings.ToLower(transaction.Blockchain)] += value
+	}
+	return flows
+}
+
+// symbolChainSections lists symbols whose blockchains flow in opposite directions
+// like usdt into exchanges on tron while it leaves them on ethereum, which the net flow hides
+// largest volume first
+func (r renderer) symbolChainSections(flows map[string]map[string]float64) []summary.Section {
+	if !r.config.ChainBreakdown.Enabled || !r.job.analyzes(symbolChainAnalyzer) {
+		return nil
+	}
+	type divergent struct {
+		symbol  string
+		in, out float64
+	}
+	var symbols []divergent
+	for symbol, chains := range flows {
+		if len(chains) < 2 {
+			continue
+		}
+		d := divergent{symbol: symbol}
+		for _, value := range chains {
+			if value > 0 {
+				d.in += value
+			} else {
+				d.out -= value
+			}
+		}
+		if math.Max(d.in, d.out) < r.job.threshold() || math.Min(d.in, d.out) < math.Max(d.in, d.out)*r.config.ChainBreakdown.divergence() {
+			continue
+		}
+		symbols = append(symbols, d)
+	}
+	if len(symbols) < 1 {
+		return nil
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].in+symbols[i].out > symbols[j].in+symbols[j].out
+	})
+	section := summary.Section{Name: symbolChainAnalyzer, Title: "Flows by Chain:"}
+	for _, d := range symbols {
+		chains := make([]string, 0, len(flows[d.symbol]))
+		for chain := range flows[d.symbol] {
+			chains = append(chains, chain)
+		}
+		sort.Slice(chains, func(i, j int) bool {
+			return math.Abs(flows[d.symbol][chains[i]]) > math.Abs(flows[d.symbol][chains[j]])
+		})
+		parts := make([]string, len(chains))
+		for i, chain := range chains {
+			parts[i] = chain + " " + r.signed(flows[d.symbol][chain])
+		}
+		section.Lines = append(section.Lines, summary.Line{
+			Text:   r.p.Sprintf("%s: %s (net %s)", r.code(strings.ToUpper(d.symbol)), r.escape(strings.Join(parts, ", ")), r.signed(d.in-d.out)),
+			Indent: 2,
+			Symbol: d.symbol,
+			Value:  d.in - d.out,
+		})
+	}
+	return []summary.Section{section}
+}
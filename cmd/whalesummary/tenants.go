@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+)
+
+// Tenant is an independent configuration served by the same process
+// like a community with its own api key, filters, recipients, and database schema
+type Tenant struct {
+	Name   string `json:"name"`
+	Config string `json:"config"` //path of the tenant's config. relative to the main config
+}
+
+// runTenants runs every tenant's config side by side and waits for all of them
+// relative state files are kept next to the tenant's config and the daemon's defaults to a per tenant name
+// tenants sharing a state file are refused so they never resume from each other's progress
+func runTenants(configPath string, tenants []Tenant, run func(Config)) {
+	var wg sync.WaitGroup
+	owners := map[string]string{}
+	for _, tenant := range tenants {
+		if tenant.Name == "" {
+			log.Fatal("Tenant without a name: ", tenant.Config)
+		}
+		path := tenant.Config
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(configPath), path)
+		}
+		// without the environment, which only overrides the main config
+		config := parseConfig(path, nil)
+		if len(config.Tenants) > 0 {
+			log.Fatalf("Tenant %s cannot have tenants", tenant.Name)
+		}
+		if config.Daemon.State == "" {
+			config.Daemon.State = tenant.Name + "_daemon_state.json"
+		}
+		for _, state := range []*string{&config.Daemon.State, &config.WhaleAlert.State, &config.Alerts.State} {
+			if *state == "" {
+				continue
+			}
+			if !filepath.IsAbs(*state) {
+				*state = filepath.Join(filepath.Dir(path), *state)
+			}
+			if owner, ok := owners[*state]; ok {
+				log.Fatalf("Tenants %s and %s share the state file %s", owner, tenant.Name, *state)
+			}
+			owners[*state] = tenant.Name
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(config)
+		}()
+	}
+	wg.Wait()
+}
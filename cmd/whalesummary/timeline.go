@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/store"
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// timelineLimit is the most stored movements a timeline reads
+const timelineLimit = 1000
+
+// timelineMove is one stored movement of an address or owner
+type timelineMove struct {
+	Timestamp        int64   `json:"timestamp"`
+	Blockchain       string  `json:"blockchain"`
+	Hash             string  `json:"hash"`
+	Symbol           string  `json:"symbol"`
+	Direction        string  `json:"direction"`    //in, out, or internal between wallets of the mover
+	Counterparty     string  `json:"counterparty"` //owner or address of the other side
+	CounterpartyType string  `json:"counterparty_type"`
+	AmountUsd        float64 `json:"amount_usd"`
+	ExchangeFlow     float64 `json:"exchange_flow"` //usd withdrawn from exchanges. negative for deposited
+	NetPosition      float64 `json:"net_position"`  //exchange flow since the first movement of the timeline
+}
+
+// timeline is the movements of an address or owner oldest first
+// with its cumulative net position versus exchanges overall and per symbol
+type timeline struct {
+	Mover       string             `json:"mover"`
+	Start       int64              `json:"start"`
+	End         int64              `json:"end"`
+	Moves       []timelineMove     `json:"moves"`
+	NetPosition float64            `json:"net_position"`
+	Symbols     map[string]float64 `json:"net_position_by_symbol"`
+	Truncated   bool               `json:"truncated"` //more than timelineLimit movements. the position counts from the oldest listed
+}
+
+// moverNames are what a mover can be stored as
+// an address, or an owner with every label mapped to its entity
+func moverNames(mover string, entities map[string]string) []string {
+	mover = strings.ToLower(strings.TrimSpace(mover))
+	names := []string{mover}
+	entity := ownerEntity(mover, entities)
+	if entity != mover {
+		names = append(names, strings.ToLower(entity))
+	}
+	for label, e := range entities {
+		if strings.EqualFold(e, entity) && label != mover {
+			names = append(names, label)
+		}
+	}
+	return names
+}
+
+// isMover is whether the wallet is the address or belongs to the owner
+func isMover(mover string, wallet whalealert.Wallet) bool {
+	return strings.EqualFold(mover, wallet.Address) || sameOwner(mover, wallet.Owner)
+}
+
+// fetchTimeline reads the stored movements of an address or owner in the window
+func fetchTimeline(ctx context.Context, config Config, mover string, window timeWindow) (timeline, error) {
+	entities, err := loadEntities(ctx, config.db, config.Entities)
+	if err != nil {
+		fmt.Println(err)
+	}
+	transactions, err := store.FetchMoverTransactions(ctx, config.db, moverNames(mover, entities), window.start, window.end, timelineLimit+1)
+	if err != nil {
+		return timeline{}, err
+	}
+	t := timeline{Mover: mover, Start: window.start, End: window.end, Symbols: map[string]float64{}, Moves: []timelineMove{}}
+	if len(transactions) > timelineLimit {
+		t.Truncated = true
+		transactions = transactions[:timelineLimit]
+	}
+	transactions = config.overrideOwners(normalizeOwners(transactions, entities))
+	// an owner is compared as its entity like the stored labels now are
+	subject := ownerEntity(mover, entities)
+	// newest first from the log
+	for i := len(transactions) - 1; i >= 0; i-- {
+		transaction := transactions[i]
+		from, to := isMover(subject, transaction.From), isMover(subject, transaction.To)
+		move := timelineMove{Timestamp: int64(transaction.Timestamp), Blockchain: transaction.Blockchain, Hash: transaction.Hash,
+			Symbol: transaction.Symbol, AmountUsd: transaction.AmountUsd}
+		counterparty := transaction.To
+		switch {
+		case from && to:
+			move.Direction = "internal"
+		case from:
+			move.Direction = "out"
+		case to:
+			move.Direction = "in"
+			counterparty = transaction.From
+		default:
+			continue
+		}
+		move.Counterparty, move.CounterpartyType = walletName(counterparty), counterparty.OwnerType
+		if move.Direction != "internal" && counterparty.OwnerType == "exchange" {
+			move.ExchangeFlow = transaction.AmountUsd
+			if move.Direction == "out" {
+				move.ExchangeFlow = -transaction.AmountUsd
+			}
+		}
+		t.NetPosition += move.ExchangeFlow
+		move.NetPosition = t.NetPosition
+		if move.ExchangeFlow != 0 {
+			t.Symbols[summary.RemapSymbol(transaction.Symbol, config.Remap)] += move.ExchangeFlow
+		}
+		t.Moves = append(t.Moves, move)
+	}
+	return t, nil
+}
+
+// timelineCommand lists the latest movements of an address or owner like /timeline binance or /timeline 0xabc 7
+// with the net position versus exchanges after each
+func timelineCommand(ctx context.Context, config Config, r renderer, args []string) (string, error) {
+	days := 30
+	if len(args) > 1 {
+		if value, err := strconv.Atoi(args[len(args)-1]); err == nil {
+			if value < 1 || value > 365 {
+				return "Days must be between 1 and 365.", nil
+			}
+			days = value
+			args = args[:len(args)-1]
+		}
+	}
+	if len(args) < 1 {
+		return "Usage: /timeline <address or owner> [days]", nil
+	}
+	mover := strings.Join(args, " ")
+	now := time.Now()
+	t, err := fetchTimeline(ctx, config, mover, timeWindow{start: now.AddDate(0, 0, -days).Unix(), end: now.Unix()})
+	if err != nil {
+		return "", err
+	}
+	if len(t.Moves) < 1 {
+		return fmt.Sprintf("No stored movements of %s in the last %d days.", r.code(mover), days), nil
+	}
+	msg := []string{r.p.Sprintf("%s in the last %d days: %s versus exchanges", r.code(mover), days, r.signed(t.NetPosition))}
+	limit := config.Bot.Top
+	if limit < 1 {
+		limit = 10
+	}
+	moves := t.Moves
+	if len(moves) > limit {
+		msg = append(msg, r.p.Sprintf("Latest %d of %d movements:", limit, len(moves)))
+		moves = moves[len(moves)-limit:]
+	}
+	arrows := map[string]string{"in": "←", "out": "→", "internal": "↔"}
+	for _, move := range moves {
+		line := r.p.Sprintf("%s %s %s %s %s",
+			r.link(time.Unix(move.Timestamp, 0).UTC().Format("Jan 2 15:04"), config.explorerURL(move.Blockchain, move.Hash)),
+			r.code(strings.ToUpper(move.Symbol)), r.amount(move.AmountUsd), arrows[move.Direction], r.escape(move.Counterparty))
+		if move.ExchangeFlow != 0 {
+			line += " · " + r.signed(move.NetPosition)
+		}
+		msg = append(msg, line)
+	}
+	return strings.Join(msg, "\n"), nil
+}
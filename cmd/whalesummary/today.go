@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/summary"
+)
+
+// runningTotals is the net flow of each job by section then symbol since the start of the day in utc
+// kept by the stream and the daemon so every periodic message can say how the day is going
+type runningTotals struct {
+	Day  int64                                    `json:"day"` //unix start of the day in utc
+	Jobs map[string]map[string]map[string]float64 `json:"jobs"`
+}
+
+// add counts the summary of the job's window toward the day the window starts in
+// a window of a new day starts the totals over
+func (t *runningTotals) add(job string, window timeWindow, jobSummary Summary) {
+	day := time.Unix(window.start, 0).UTC().Truncate(24 * time.Hour).Unix()
+	if day != t.Day || t.Jobs == nil {
+		t.Day = day
+		t.Jobs = map[string]map[string]map[string]float64{}
+	}
+	totals := t.Jobs[job]
+	if totals == nil {
+		totals = map[string]map[string]float64{}
+		t.Jobs[job] = totals
+	}
+	for section, flows := range jobSummary.sections() {
+		for symbol, value := range flows {
+			if totals[section] == nil {
+				totals[section] = map[string]float64{}
+			}
+			totals[section][symbol] += value
+		}
+	}
+}
+
+// todaySections are the day's significant totals with one line per direction of each section
+// like Exchange Inflow: BTC $120.00M, USDT $50.00M
+func (r renderer) todaySections() []summary.Section {
+	totals := r.enrichment.Today
+	if len(totals) < 1 {
+		return nil
+	}
+	sections := []flowSection{supplySection, transferSection, derivativesSection, stakingSection, custodySection, lockSection}
+	var ownerTypes []string
+	for name := range totals {
+		if _, ok := r.config.ownerType(name); ok {
+			ownerTypes = append(ownerTypes, name)
+		}
+	}
+	sort.Strings(ownerTypes)
+	for _, name := range ownerTypes {
+		ownerType, _ := r.config.ownerType(name)
+		sections = append(sections, ownerType.section(name))
+	}
+	today := summary.Section{Name: "today", Title: "So far today:"}
+	for _, section := range sections {
+		analyzer := section.analyzer
+		if analyzer == "" {
+			analyzer = section.name
+		}
+		if !r.job.analyzes(analyzer) {
+			continue
+		}
+		var increases, decreases []string
+		for symbol, value := range totals[section.name] {
+			if math.Abs(value) < r.job.threshold() {
+				continue
+			}
+			if value > 0 {
+				increases = append(increases, symbol)
+			} else {
+				decreases = append(decreases, symbol)
+			}
+		}
+		flows := totals[section.name]
+		for _, side := range []struct {
+			title   string
+			symbols []string
+		}{{section.increase, increases}, {section.decrease, decreases}} {
+			if len(side.symbols) < 1 {
+				continue
+			}
+			sort.Slice(side.symbols, func(i, j int) bool {
+				return math.Abs(flows[side.symbols[i]]) > math.Abs(flows[side.symbols[j]])
+			})
+			parts := make([]string, len(side.symbols))
+			for i, symbol := range side.symbols {
+				parts[i] = strings.ToUpper(symbol) + " " + r.amount(math.Abs(flows[symbol]))
+			}
+			today.Lines = append(today.Lines, summary.Line{Text: side.title + " " + r.escape(strings.Join(parts, ", ")), Indent: 2, Group: section.name})
+		}
+	}
+	if len(today.Lines) < 1 {
+		return nil
+	}
+	return []summary.Section{today}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// Token describes how a chain represents amounts of a symbol
+type Token struct {
+	Symbol   string `json:"symbol"`
+	Chain    string `json:"chain"`    //blockchain as named by whale alert like ethereum or tron
+	Contract string `json:"contract"` //empty for the chain's native coin
+	Decimals int    `json:"decimals"`
+}
+
+// tokenRegistry finds tokens by contract or by symbol on a chain
+type tokenRegistry struct {
+	byContract map[string]Token
+	bySymbol   map[string]Token
+}
+
+func newTokenRegistry(tokens []Token) tokenRegistry {
+	registry := tokenRegistry{byContract: map[string]Token{}, bySymbol: map[string]Token{}}
+	for _, token := range tokens {
+		chain := strings.ToLower(token.Chain)
+		if token.Contract != "" {
+			registry.byContract[chain+"|"+strings.ToLower(token.Contract)] = token
+		}
+		registry.bySymbol[chain+"|"+strings.ToLower(token.Symbol)] = token
+	}
+	return registry
+}
+
+func (registry tokenRegistry) lookup(chain, contract, symbol string) (Token, bool) {
+	chain = strings.ToLower(chain)
+	if contract != "" {
+		token, ok := registry.byContract[chain+"|"+strings.ToLower(contract)]
+		return token, ok
+	}
+	token, ok := registry.bySymbol[chain+"|"+strings.ToLower(symbol)]
+	return token, ok
+}
+
+// normalizeAmounts converts raw on chain amounts into whole units using the token's decimals
+// whale alert already reports whole units so only transactions with a raw amount are touched
+func normalizeAmounts(transactions []whalealert.Transaction, registry tokenRegistry) ([]whalealert.Transaction, []string) {
+	var unknown []string
+	for i, transaction := range transactions {
+		if transaction.RawAmount == "" {
+			continue
+		}
+		token, ok := registry.lookup(transaction.Blockchain, transaction.Contract, transaction.Symbol)
+		if !ok {
+			unknown = append(unknown, fmt.Sprintf("%s %s %s", transaction.Blockchain, transaction.Symbol, transaction.Contract))
+			continue
+		}
+		raw, ok := new(big.Float).SetString(transaction.RawAmount)
+		if !ok {
+			unknown = append(unknown, fmt.Sprintf("%s amount %q", transaction.Hash, transaction.RawAmount))
+			continue
+		}
+		scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(token.Decimals)), nil))
+		amount, _ := new(big.Float).Quo(raw, scale).Float64()
+		if transaction.Amount > 0 && transaction.AmountUsd > 0 {
+			// keep the source's price but apply it to the corrected amount
+			transaction.AmountUsd = transaction.AmountUsd / transaction.Amount * amount
+		}
+		transaction.Amount = amount
+		transaction.Symbol = strings.ToLower(token.Symbol)
+		transactions[i] = transaction
+	}
+	return transactions, unknown
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// runVerify is the verify subcommand
+// every directory of the fixtures with a window.json is a recording of a run with its config.json
+// the run is replayed and what it would have sent is compared to golden.txt
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	fixtures := flags.String("fixtures", "fixtures/golden", "directory of recorded windows")
+	update := flags.Bool("update", false, "rewrite golden.txt with the current output instead of comparing")
+	flags.Parse(args)
+	// what runs print goes to stderr so the results stand out
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	dirs, err := filepath.Glob(filepath.Join(*fixtures, "*", "window.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(dirs) < 1 {
+		log.Fatal("No recorded windows in ", *fixtures)
+	}
+	sort.Strings(dirs)
+	failed := 0
+	for _, path := range dirs {
+		dir := filepath.Dir(path)
+		output, err := replayFixture(dir)
+		if err != nil {
+			log.Fatalf("%s: %s", dir, err)
+		}
+		golden := filepath.Join(dir, "golden.txt")
+		if *update {
+			err = ioutil.WriteFile(golden, []byte(output), 0644)
+			if err != nil {
+				log.Fatalf("%s: %s", dir, err)
+			}
+			fmt.Fprintln(out, "updated", golden)
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			log.Fatalf("%s: %s", dir, err)
+		}
+		if string(expected) == output {
+			fmt.Fprintln(out, "ok", dir)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL %s\n--- %s\n+++ output\n%s", dir, golden, lineDiff(string(expected), output))
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "%d of %d windows differ from their golden output\n", failed, len(dirs))
+		os.Exit(1)
+	}
+}
+
+// replayFixture runs the recorded window with the config next to it and returns every message it sent
+func replayFixture(dir string) (string, error) {
+	window, err := replayWindow(dir)
+	if err != nil {
+		return "", err
+	}
+	config := parseConfig(filepath.Join(dir, "config.json"), nil)
+	capture := &messageCapture{next: &recorder{dir: dir, replay: true, seen: map[string]int{}}}
+	previous := http.DefaultClient.Transport
+	http.DefaultClient.Transport = capture
+	defer func() {
+		http.DefaultClient.Transport = previous
+	}()
+	// the fetch error is in the messages to the log chat
+	runSummary(config, window)
+	return strings.Join(capture.messages, ""), nil
+}
+
+// messageCapture answers telegram messages itself so a changed message fails the comparison instead of the replay
+type messageCapture struct {
+	next     http.RoundTripper
+	mu       sync.Mutex
+	messages []string
+}
+
+func (c *messageCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/sendMessage") {
+		return c.next.RoundTrip(req)
+	}
+	var message struct {
+		ChatID string `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&message)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.messages = append(c.messages, fmt.Sprintf("--- to %s\n%s\n", message.ChatID, message.Text))
+	c.mu.Unlock()
+	return &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString(`{"ok":true}`)),
+		Request:    req,
+	}, nil
+}
+
+// lineDiff marks the lines only in a with - and the lines only in b with +
+// from their longest common subsequence
+func lineDiff(a, b string) string {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+	// common[i][j] is the longest common subsequence of x[i:] and y[j:]
+	common := make([][]int, len(x)+1)
+	for i := range common {
+		common[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			diff.WriteString("  " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || common[i+1][j] >= common[i][j+1]):
+			diff.WriteString("- " + x[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+ " + y[j] + "\n")
+			j++
+		}
+	}
+	return diff.String()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/enzosv/whalesummary/summary"
+)
+
+// VolatilityConfig is a public candle endpoint used to tell whether flows arrive while the market is quiet
+type VolatilityConfig struct {
+	URL    string  `json:"url"`    //binance compatible klines endpoint like https://api.binance.com/api/v3/klines
+	Quote  string  `json:"quote"`  //quote asset of the pairs. defaults to USDT
+	Recent int     `json:"recent"` //days of recent volatility. defaults to 7
+	Days   int     `json:"days"`   //days of usual volatility to compare to. defaults to 30
+	Low    float64 `json:"low"`    //ratio of recent to usual volatility considered unusually low. defaults to 0.6
+}
+
+// volatility is the annualized realized volatility of a symbol
+type volatility struct {
+	Recent float64
+	Usual  float64
+}
+
+func (config VolatilityConfig) days() (int, int) {
+	recent, days := config.Recent, config.Days
+	if recent < 2 {
+		recent = 7
+	}
+	if days < 1 {
+		days = 30
+	}
+	if days <= recent {
+		days = recent * 4
+	}
+	return recent, days
+}
+
+func (config VolatilityConfig) low() float64 {
+	if config.Low > 0 {
+		return config.Low
+	}
+	return 0.6
+}
+
+// quiet is whether recent volatility is unusually low compared to the usual
+// large flows into a quiet market often come before a breakout
+func (v volatility) quiet(config VolatilityConfig) bool {
+	return v.Usual > 0 && v.Recent < v.Usual*config.low()
+}
+
+// fetchVolatility is the realized volatility of daily closes of each symbol
+// symbols without a pair on the exchange are left out
+func fetchVolatility(config VolatilityConfig, symbols []string, l *lookups) map[string]volatility {
+	quote := config.Quote
+	if quote == "" {
+		quote = "USDT"
+	}
+	recent, days := config.days()
+	var mu sync.Mutex
+	volatilities := map[string]volatility{}
+	l.each(len(symbols), func(i int) {
+		symbol := symbols[i]
+		params := url.Values{}
+		params.Add("symbol", strings.ToUpper(symbol+quote))
+		params.Add("interval", "1d")
+		// one more close than returns
+		params.Add("limit", strconv.Itoa(days+1))
+		requestURL := config.URL + "?" + params.Encode()
+		var v volatility
+		if !l.get(requestURL, &v) {
+			var candles [][]interface{}
+			err := getJSON(requestURL, &candles)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			var returns []float64
+			previous := 0.0
+			for _, candle := range candles {
+				if len(candle) < 5 {
+					continue
+				}
+				text, _ := candle[4].(string)
+				close, err := strconv.ParseFloat(text, 64)
+				if err != nil || close <= 0 {
+					continue
+				}
+				if previous > 0 {
+					returns = append(returns, math.Log(close/previous))
+				}
+				previous = close
+			}
+			if len(returns) > recent {
+				v = volatility{
+					Recent: realizedVolatility(returns[len(returns)-recent:]),
+					Usual:  realizedVolatility(returns),
+				}
+			}
+			// cached even when too short so new listings aren't asked for every run
+			l.put(requestURL, v)
+		}
+		if v.Usual > 0 {
+			mu.Lock()
+			volatilities[symbol] = v
+			mu.Unlock()
+		}
+	})
+	return volatilities
+}
+
+// realizedVolatility is the annualized standard deviation of daily log returns
+func realizedVolatility(returns []float64) float64 {
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+	// crypto trades every day
+	return math.Sqrt(variance) * math.Sqrt(365)
+}
+
+// reportedSymbols are the crypto symbols with a net flow large enough to report in any section
+func reportedSymbols(windowSummary Summary, threshold float64, config Config) []string {
+	seen := map[string]bool{}
+	var symbols []string
+	for _, flows := range windowSummary.sections() {
+		for symbol, value := range flows {
+			if seen[symbol] || math.Abs(value) < threshold || summary.IsStableCoin(symbol, config.StableCoins) || nonUSDPeg(symbol, config) != "" {
+				continue
+			}
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	// the same every run so requests that list them can be replayed
+	sort.Strings(symbols)
+	return symbols
+}
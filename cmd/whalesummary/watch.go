@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// WatchConfig extends the built in entities whose movements reliably move markets
+type WatchConfig struct {
+	Entities    map[string]string `json:"entities"`     //owner substring to category like government or estate
+	Disabled    bool              `json:"disabled"`     //ignore the built in entities
+	RecipientID string            `json:"recipient_id"` //chat for immediate alerts. defaults to telegram.recipient_id
+}
+
+const (
+	governmentWatch = "government"
+	estateWatch     = "estate"
+	watchAnalyzer   = "watched"
+)
+
+// watchedEntities are matched as substrings of the normalized owner
+var watchedEntities = map[string]string{
+	"government":    governmentWatch,
+	"u.s. marshals": governmentWatch,
+	"us marshals":   governmentWatch,
+	"silk road":     governmentWatch,
+	"mt. gox":       estateWatch,
+	"mt gox":        estateWatch,
+	"mtgox":         estateWatch,
+	"ftx":           estateWatch,
+	"celsius":       estateWatch,
+	"voyager":       estateWatch,
+	"blockfi":       estateWatch,
+}
+
+var watchWording = map[string]string{
+	governmentWatch: "🏛 Government wallet",
+	estateWatch:     "⚖️ Bankruptcy estate",
+}
+
+// watchedTransaction is a transaction sent or received by a watched entity
+type watchedTransaction struct {
+	Transaction whalealert.Transaction
+	Entity      string
+	Category    string
+	Outgoing    bool
+}
+
+// watchList is the built in entities overridden by the configured ones
+func (config WatchConfig) watchList() map[string]string {
+	list := map[string]string{}
+	if !config.Disabled {
+		for entity, category := range watchedEntities {
+			list[entity] = category
+		}
+	}
+	for entity, category := range config.Entities {
+		list[strings.ToLower(strings.TrimSpace(entity))] = strings.ToLower(category)
+	}
+	return list
+}
+
+// watchCategory finds the watched entity an owner belongs to
+// the longest match wins so specific entries beat general ones
+func watchCategory(owner string, list map[string]string) (string, string) {
+	owner = strings.ToLower(owner)
+	var match string
+	for entity := range list {
+		if len(entity) > len(match) && strings.Contains(owner, entity) {
+			match = entity
+		}
+	}
+	return match, list[match]
+}
+
+func matchWatched(transactions []whalealert.Transaction, list map[string]string) []watchedTransaction {
+	var matches []watchedTransaction
+	for _, transaction := range transactions {
+		if entity, category := watchCategory(transaction.From.Owner, list); entity != "" {
+			matches = append(matches, watchedTransaction{Transaction: transaction, Entity: transaction.From.Owner, Category: category, Outgoing: true})
+		} else if entity, category := watchCategory(transaction.To.Owner, list); entity != "" {
+			matches = append(matches, watchedTransaction{Transaction: transaction, Entity: transaction.To.Owner, Category: category})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Transaction.AmountUsd > matches[j].Transaction.AmountUsd
+	})
+	return matches
+}
+
+func (r renderer) watchedSections(matches []watchedTransaction) []summary.Section {
+	if !r.job.analyzes(watchAnalyzer) || len(matches) < 1 {
+		return nil
+	}
+	section := summary.Section{Name: watchAnalyzer, Title: "Watched Entities:"}
+	for _, match := range matches {
+		value := match.Transaction.AmountUsd
+		if match.Outgoing {
+			value = -value
+		}
+		section.Lines = append(section.Lines, summary.Line{
+			Text:   r.watchedLine(match),
+			Indent: 2,
+			Group:  match.Entity,
+			Symbol: strings.ToLower(match.Transaction.Symbol),
+			Value:  value,
+		})
+	}
+	return []summary.Section{section}
+}
+
+// watchedLine calls out deposits to exchanges since those usually precede a sale
+func (r renderer) watchedLine(match watchedTransaction) string {
+	transaction := match.Transaction
+	wording, ok := watchWording[match.Category]
+	if !ok {
+		wording = "👀 " + match.Category
+	}
+	counterparty, verb := transaction.To, "sent %s %s to %s"
+	if !match.Outgoing {
+		counterparty, verb = transaction.From, "received %s %s from %s"
+	}
+	other := counterparty.Owner
+	if other == "" {
+		other = shortMover(counterparty.Address)
+	}
+	if other == "" {
+		other = "unknown"
+	}
+	line := r.p.Sprintf("%s %s "+verb, wording, r.code(match.Entity),
+		r.amount(transaction.AmountUsd), strings.ToUpper(transaction.Symbol), r.escape(other))
+	if match.Outgoing && counterparty.OwnerType == "exchange" {
+		line += " (possible sale)"
+	}
+	return line
+}
+
+// alertWatched sends watched entity movements right away instead of waiting for the summary
+func alertWatched(config Config, matches []watchedTransaction) {
+	if len(matches) < 1 {
+		return
+	}
+	recipient := config.Watch.RecipientID
+	if recipient == "" {
+		recipient = config.Telegram.RecipientID
+	}
+	r := newRenderer(Job{Name: "watched", Format: config.recipientFormat(config.Telegram.Format, recipient)}, Enrichment{}, config)
+	var msg []string
+	for _, match := range matches {
+		msg = append(msg, r.watchedLine(match))
+	}
+	config.Telegram.SendFormatted(recipient, strings.Join(msg, "\n"), r.format.telegramParseMode())
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeWindow is the range of transactions to summarize in unix seconds
+// start is inclusive and end is exclusive so consecutive windows never overlap
+type timeWindow struct {
+	start int64
+	end   int64
+}
+
+// resolveWindow fills in whichever of start and end is missing using interval
+// with neither, the window ends at the current minute
+func resolveWindow(startValue, endValue string, interval time.Duration, now time.Time) (timeWindow, error) {
+	var start, end time.Time
+	var err error
+	if startValue != "" {
+		start, err = parseTime(startValue, now)
+		if err != nil {
+			return timeWindow{}, fmt.Errorf("start: %w", err)
+		}
+	}
+	if endValue != "" {
+		end, err = parseTime(endValue, now)
+		if err != nil {
+			return timeWindow{}, fmt.Errorf("end: %w", err)
+		}
+	}
+	switch {
+	case startValue == "" && endValue == "":
+		// rounded down to nearest minute
+		end = now.Truncate(time.Minute)
+		start = end.Add(-interval)
+	case startValue == "":
+		start = end.Add(-interval)
+	case endValue == "":
+		end = start.Add(interval)
+	}
+	if !end.After(start) {
+		return timeWindow{}, fmt.Errorf("end %s must be after start %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	return timeWindow{start: start.Unix(), end: end.Unix()}, nil
+}
+
+// parseTime reads unix seconds, RFC3339, a utc date like 2023-01-01, "now", or a duration relative to now like -6h
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "now" {
+		return now, nil
+	}
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		offset, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(offset), nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveWindow(t *testing.T) {
+	now := time.Date(2023, 1, 2, 15, 4, 35, 0, time.UTC)
+	minute := now.Truncate(time.Minute)
+	interval := 48 * time.Minute
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		want  timeWindow
+		err   bool
+	}{
+		{"default ends at the current minute", "", "", timeWindow{minute.Add(-interval).Unix(), minute.Unix()}, false},
+		{"start only lasts an interval", "1672671600", "", timeWindow{1672671600, 1672671600 + 48*60}, false},
+		{"end only starts an interval before", "", "1672671600", timeWindow{1672671600 - 48*60, 1672671600}, false},
+		{"relative start and end", "-6h", "now", timeWindow{now.Add(-6 * time.Hour).Unix(), now.Unix()}, false},
+		{"rfc3339", "2023-01-01T00:00:00Z", "2023-01-01T00:48:00+00:00", timeWindow{1672531200, 1672531200 + 48*60}, false},
+		{"date", "2023-01-01", "2023-01-02", timeWindow{1672531200, 1672617600}, false},
+		{"end before start", "2023-01-02", "2023-01-01", timeWindow{}, true},
+		{"end at start", "1672671600", "1672671600", timeWindow{}, true},
+		{"invalid start", "yesterday", "", timeWindow{}, true},
+		{"invalid end", "", "-6 hours", timeWindow{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolveWindow(test.start, test.end, interval, now)
+			if (err != nil) != test.err {
+				t.Fatalf("error %v, want error %v", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2023, 1, 2, 15, 4, 35, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{"now", now, false},
+		{"-6h", now.Add(-6 * time.Hour), false},
+		{"+90m", now.Add(90 * time.Minute), false},
+		{"1672531200", time.Unix(1672531200, 0), false},
+		{"2023-01-01", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2023-01-01T08:00:00+08:00", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"-6 hours", time.Time{}, true},
+		{"01/01/2023", time.Time{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseTime(test.value, now)
+			if (err != nil) != test.err {
+				t.Fatalf("error %v, want error %v", err, test.err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,10 @@ module github.com/enzosv/whalesummary
 go 1.16
 
 require (
+	github.com/gorilla/websocket v1.5.0
 	github.com/jackc/pgx/v4 v4.14.1
+	github.com/robfig/cron/v3 v3.0.1
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
 	golang.org/x/text v0.3.7
+	modernc.org/sqlite v1.14.3
 )
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/enzosv/whalesummary/retry"
+)
+
+// Chat is what getChat says about a user, group, supergroup, or channel
+type Chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+var (
+	numericChatID = regexp.MustCompile(`^-?[0-9]+$`)
+	// telegram usernames are 5 to 32 characters but some old ones are shorter
+	chatUsername = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+)
+
+// ChatID is the configured chat in a form getChat accepts
+// numeric ids of users, groups, and supergroups like -1001234567890 are kept
+// @channelusername, channelusername, and t.me/channelusername links become @channelusername
+func ChatID(configured string) (string, error) {
+	id := strings.TrimSpace(configured)
+	if numericChatID.MatchString(id) {
+		return id, nil
+	}
+	for _, prefix := range []string{"https://t.me/", "http://t.me/", "t.me/", "@"} {
+		if strings.HasPrefix(id, prefix) {
+			id = strings.TrimSuffix(strings.TrimPrefix(id, prefix), "/")
+			break
+		}
+	}
+	if !chatUsername.MatchString(id) {
+		return "", fmt.Errorf("%q is not a numeric chat id, @username, or t.me link", configured)
+	}
+	return "@" + id, nil
+}
+
+// GetChat asks telegram for the chat so a wrong id or a bot that isn't a member is known before sending
+func (config Telegram) GetChat(ctx context.Context, chatID string) (Chat, error) {
+	var chat Chat
+	err := config.Policy.Do(ctx, func(ctx context.Context) error {
+		params := url.Values{}
+		params.Add("chat_id", chatID)
+		req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/bot%s/getChat?%s", config.baseURL(), config.BotID, params.Encode()), nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusTooManyRequests {
+			return retry.After(fmt.Errorf("telegram returned %s", res.Status), retry.RetryAfter(res.Header))
+		}
+		if res.StatusCode >= 500 {
+			return fmt.Errorf("telegram returned %s", res.Status)
+		}
+		var response struct {
+			OK          bool   `json:"ok"`
+			Description string `json:"description"`
+			Result      Chat   `json:"result"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&response)
+		if err != nil {
+			return err
+		}
+		if !response.OK {
+			// like chat not found. asking again won't change it
+			return retry.Permanent(fmt.Errorf("telegram getChat %s: %s", chatID, response.Description))
+		}
+		chat = response.Result
+		return nil
+	})
+	return chat, err
+}
+
+// ResolveChat is the numeric id of the configured chat
+// a supergroup or channel id missing its -100 minus is tried again with it since that is the usual mistake
+func (config Telegram) ResolveChat(ctx context.Context, configured string) (string, error) {
+	id, err := ChatID(configured)
+	if err != nil {
+		return "", err
+	}
+	chat, err := config.GetChat(ctx, id)
+	if err != nil && strings.HasPrefix(id, "100") && len(id) >= 13 {
+		if supergroup, err2 := config.GetChat(ctx, "-"+id); err2 == nil {
+			chat, err = supergroup, nil
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(chat.ID, 10), nil
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email sends through an smtp server
+type Email struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`     //defaults to 587. 465 connects with tls instead of upgrading with starttls
+	Username string   `json:"username"` //optional. plain auth
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`      //addresses when the job has no email_to
+	Subject  string   `json:"subject"` //followed by the job and the end of the window. defaults to Whale Summary
+	// the plain text of emails is written here instead of sent when set like for a dry run
+	Preview io.Writer `json:"-"`
+}
+
+// Enabled is whether a server and a sender are configured
+func (config Email) Enabled() bool {
+	return config.Host != "" && config.From != ""
+}
+
+func (config Email) addr() string {
+	port := config.Port
+	if port < 1 {
+		port = 587
+	}
+	return net.JoinHostPort(config.Host, strconv.Itoa(port))
+}
+
+// EmailHTML is an html document of a message rendered in telegram html
+// the lines and their indents are kept like in a chat message
+func EmailHTML(message string) string {
+	return `<!DOCTYPE html><html><body><div style="font-family: monospace; white-space: pre-wrap;">` + message + `</div></body></html>`
+}
+
+// Send emails the message as html with a plain text alternative
+func (config Email) Send(to []string, subject, text, htmlBody string) error {
+	if config.Preview != nil {
+		_, err := fmt.Fprintf(config.Preview, "--- email %s: %s\n%s\n", strings.Join(to, ", "), subject, text)
+		return err
+	}
+	msg, err := config.message(to, subject, text, htmlBody)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+	if config.Port != 465 {
+		// upgraded with starttls when the server offers it
+		err = smtp.SendMail(config.addr(), auth, config.From, to, msg)
+		if err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+		return nil
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", config.addr(), &tls.Config{ServerName: config.Host})
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("email: %w", err)
+	}
+	defer client.Close()
+	if auth != nil {
+		err = client.Auth(auth)
+		if err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	err = client.Mail(config.From)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	for _, address := range to {
+		err = client.Rcpt(address)
+		if err != nil {
+			return fmt.Errorf("email %s: %w", address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	_, err = w.Write(msg)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return client.Quit()
+}
+
+// message is a multipart/alternative email with the plain text first so clients prefer the html
+func (config Email) message(to []string, subject, text, htmlBody string) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		_, err = qp.Write([]byte(part.content))
+		if err != nil {
+			return nil, err
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := parts.Close()
+	if err != nil {
+		return nil, err
+	}
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", config.From},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	for _, header := range headers {
+		msg.WriteString(header[0] + ": " + header[1] + "\r\n")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// entity is a run of a markdown message with the same formatting
+type entity struct {
+	kind       string // text, code, pre, italic, bold, or link
+	text       string
+	url        string
+	start, end int // runes of the message it was read from
+}
+
+// parseMarkdown reads telegram's legacy markdown
+// delimiters without a closing one and characters after a backslash are text
+func parseMarkdown(message string) []entity {
+	var entities []entity
+	var text strings.Builder
+	start := 0
+	flush := func(end int) {
+		if text.Len() > 0 {
+			entities = append(entities, entity{kind: "text", text: text.String(), start: start, end: end})
+			text.Reset()
+		}
+		start = end
+	}
+	add := func(e entity) {
+		flush(e.start)
+		entities = append(entities, e)
+		start = e.end
+	}
+	runes := []rune(message)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		rest := string(runes[i:])
+		switch {
+		case c == '\\' && i+1 < len(runes):
+			// its own entity so a split never separates the backslash from what it escapes
+			add(entity{kind: "text", text: string(runes[i+1]), start: i, end: i + 2})
+			i++
+			continue
+		case strings.HasPrefix(rest, "```"):
+			if end := strings.Index(rest[3:], "```"); end >= 0 {
+				size := len([]rune(rest[:3+end+3]))
+				add(entity{kind: "pre", text: rest[3 : 3+end], start: i, end: i + size})
+				i += size - 1
+				continue
+			}
+		case c == '`':
+			if end := strings.IndexRune(rest[1:], c); end > 0 {
+				size := len([]rune(rest[:1+end+1]))
+				add(entity{kind: "code", text: rest[1 : 1+end], start: i, end: i + size})
+				i += size - 1
+				continue
+			}
+		case c == '_' || c == '*':
+			if text, size := delimited(runes[i:]); size > 0 {
+				kinds := map[rune]string{'_': "italic", '*': "bold"}
+				add(entity{kind: kinds[c], text: text, start: i, end: i + size})
+				i += size - 1
+				continue
+			}
+		case c == '[':
+			if match := linkPattern.FindStringSubmatch(rest); match != nil {
+				size := len([]rune(match[0]))
+				add(entity{kind: "link", text: match[1], url: match[2], start: i, end: i + size})
+				i += size - 1
+				continue
+			}
+		}
+		text.WriteRune(c)
+	}
+	flush(len(runes))
+	return entities
+}
+
+// delimited is the text between the delimiter the runes start with and the next unescaped one
+// escapes inside like the \_ of an escaped owner name are read as the character. size is 0 without a closing one
+func delimited(runes []rune) (string, int) {
+	var text strings.Builder
+	for i := 1; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes):
+			i++
+		case runes[i] == runes[0]:
+			if i == 1 {
+				return "", 0
+			}
+			return text.String(), i + 1
+		}
+		text.WriteRune(runes[i])
+	}
+	return "", 0
+}
+
+var linkPattern = regexp.MustCompile(`^\[([^\]]*)\]\(([^)]*)\)`)
+
+var (
+	// every character MarkdownV2 reserves outside of entities
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+		">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`)
+	// inside code only backticks and backslashes
+	markdownV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	// inside a link url only closing parentheses and backslashes
+	markdownV2URLEscaper = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+// MarkdownV2 converts a legacy markdown message into MarkdownV2 with every reserved character escaped
+// so owner names and symbols with characters like _ or . can't break the message
+func MarkdownV2(message string) string {
+	var converted strings.Builder
+	for _, e := range parseMarkdown(message) {
+		switch e.kind {
+		case "code":
+			converted.WriteString("`" + markdownV2CodeEscaper.Replace(e.text) + "`")
+		case "pre":
+			converted.WriteString("```" + markdownV2CodeEscaper.Replace(e.text) + "```")
+		case "italic":
+			converted.WriteString("_" + markdownV2Escaper.Replace(e.text) + "_")
+		case "bold":
+			converted.WriteString("*" + markdownV2Escaper.Replace(e.text) + "*")
+		case "link":
+			converted.WriteString("[" + markdownV2Escaper.Replace(e.text) + "](" + markdownV2URLEscaper.Replace(e.url) + ")")
+		default:
+			converted.WriteString(markdownV2Escaper.Replace(e.text))
+		}
+	}
+	return converted.String()
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]+>`)
+
+// PlainText strips the formatting of a message of a telegram parse mode
+// links keep their url after the text
+func PlainText(message, parseMode string) string {
+	switch parseMode {
+	case "HTML":
+		return html.UnescapeString(htmlTag.ReplaceAllString(message, ""))
+	case "":
+		return message
+	}
+	var plain strings.Builder
+	for _, e := range parseMarkdown(message) {
+		plain.WriteString(e.text)
+		if e.kind == "link" && e.url != "" {
+			plain.WriteString(" (" + e.url + ")")
+		}
+	}
+	return plain.String()
+}
@@ -0,0 +1,68 @@
+package notify
+
+import "testing"
+
+func TestMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"reserved characters", "BTC +1.5% (24h)!", `BTC \+1\.5% \(24h\)\!`},
+		{"legacy escapes", `wrapped\_btc 2\*3`, `wrapped\_btc 2\*3`},
+		{"bold", "*Flagged* a.b", `*Flagged* a\.b`},
+		{"italic", "_binance-us_", `_binance\-us_`},
+		{"escape inside italic", `_binance\_us 1\*_`, `_binance\_us 1\*_`},
+		{"code keeps reserved characters", "`a.b-c`", "`a.b-c`"},
+		{"link", "[tx.1](https://x.io/tx_1)", `[tx\.1](https://x.io/tx_1)`},
+		{"unclosed delimiter is text", "a_b", `a\_b`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := MarkdownV2(test.message); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// email and slack get what a reader of the formatted message would see
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		parseMode string
+		want      string
+	}{
+		{"escaped characters", `wrapped\_btc 2\*3 \[x\]`, "markdown", "wrapped_btc 2*3 [x]"},
+		{"escape inside bold", `*wrapped\_btc*`, "markdown", "wrapped_btc"},
+		{"bold italic and code", "*Flagged* _binance us_ `BTC`", "markdown", "Flagged binance us BTC"},
+		{"pre", "```a *b*```", "markdown", "a *b*"},
+		{"link keeps its url", "[transfer](https://x.io/tx)", "markdown", "transfer (https://x.io/tx)"},
+		{"markdownv2 is written as markdown", "*a.b* `c`", "MarkdownV2", "a.b c"},
+		{"html", "<b>a &amp; b</b> <code>c</code>", "HTML", "a & b c"},
+		{"plain", "*a*", "", "*a*"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PlainText(test.message, test.parseMode); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// what MarkdownV2 sends reads the same as the message once stripped
+// the legacy parser reads a backslash before any character as an escape so it strips MarkdownV2 too
+func TestPlainTextRoundTrip(t *testing.T) {
+	for _, message := range []string{
+		"*Flagged address activity*",
+		`wrapped\_eth _moved_ $1.00M from a.b-c (x) to d!`,
+		"`BTC` $72.00M over $50.00M",
+		`2\*3 = 6 \[approx\]`,
+	} {
+		if sent, plain := PlainText(MarkdownV2(message), "markdown"), PlainText(message, "markdown"); sent != plain {
+			t.Errorf("%s: sent reads %q, message reads %q", message, sent, plain)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SHEETSURL = "https://sheets.googleapis.com"
+
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// Sheets appends rows to a google sheet as a service account
+// share the sheet with the account's email so it can edit it
+type Sheets struct {
+	SpreadsheetID string `json:"spreadsheet_id"` //from the url of the sheet
+	Sheet         string `json:"sheet"`          //tab rows are appended to. defaults to the first
+	Credentials   string `json:"credentials"`    //path of the service account's json key
+	URL           string `json:"url"`            //defaults to SHEETSURL
+	// rows are written here instead of appended when set like for a dry run
+	Preview io.Writer `json:"-"`
+}
+
+// Enabled is whether a sheet and a key are configured
+func (config Sheets) Enabled() bool {
+	return config.SpreadsheetID != "" && config.Credentials != ""
+}
+
+func (config Sheets) baseURL() string {
+	if config.URL != "" {
+		return strings.TrimSuffix(config.URL, "/")
+	}
+	return SHEETSURL
+}
+
+// serviceAccount is the part of a google service account key used to sign in
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// token exchanges a jwt signed with the service account's key for an access token
+func (config Sheets) token(ctx context.Context) (string, error) {
+	body, err := ioutil.ReadFile(config.Credentials)
+	if err != nil {
+		return "", err
+	}
+	var account serviceAccount
+	err = json.Unmarshal(body, &account)
+	if err != nil {
+		return "", fmt.Errorf("sheets credentials %s: %w", config.Credentials, err)
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("sheets credentials %s: no private key", config.Credentials)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("sheets credentials %s: %w", config.Credentials, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("sheets credentials %s: not an rsa key", config.Credentials)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	encode := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	now := time.Now().Unix()
+	unsigned := encode(map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + encode(map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   account.TokenURI,
+		"iat":   now,
+		"exp":   now + 60*60,
+	})
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Add("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Add("assertion", unsigned+"."+base64.RawURLEncoding.EncodeToString(signature))
+	req, err := http.NewRequestWithContext(ctx, "POST", account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = sheetsJSON(req, &token)
+	if err != nil {
+		return "", fmt.Errorf("google token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+func sheetsJSON(req *http.Request, v interface{}) error {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", req.URL.Path, res.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// Append adds the rows after the last row of the sheet
+// the header goes first while the sheet is empty
+// values are read like typed in so dates and numbers can be charted
+func (config Sheets) Append(header []string, rows [][]interface{}) error {
+	if len(rows) < 1 {
+		return nil
+	}
+	if config.Preview != nil {
+		fmt.Fprintf(config.Preview, "--- sheets %s %s\n", config.SpreadsheetID, config.Sheet)
+		for _, row := range rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				if f, ok := cell.(float64); ok {
+					cells[i] = strconv.FormatFloat(f, 'f', -1, 64)
+				} else {
+					cells[i] = fmt.Sprint(cell)
+				}
+			}
+			fmt.Fprintln(config.Preview, strings.Join(cells, "\t"))
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	token, err := config.token(ctx)
+	if err != nil {
+		return err
+	}
+	values := config.baseURL() + "/v4/spreadsheets/" + url.PathEscape(config.SpreadsheetID) + "/values/"
+	prefix := ""
+	if config.Sheet != "" {
+		prefix = "'" + strings.ReplaceAll(config.Sheet, "'", "''") + "'!"
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", values+url.PathEscape(prefix+"A1:A1"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var first struct {
+		Values [][]interface{} `json:"values"`
+	}
+	err = sheetsJSON(req, &first)
+	if err != nil {
+		return err
+	}
+	if len(first.Values) < 1 && len(header) > 0 {
+		headerRow := make([]interface{}, len(header))
+		for i, column := range header {
+			headerRow[i] = column
+		}
+		rows = append([][]interface{}{headerRow}, rows...)
+	}
+	body, err := json.Marshal(map[string]interface{}{"values": rows})
+	if err != nil {
+		return err
+	}
+	params := url.Values{}
+	params.Add("valueInputOption", "USER_ENTERED")
+	params.Add("insertDataOption", "INSERT_ROWS")
+	req, err = http.NewRequestWithContext(ctx, "POST", values+url.PathEscape(prefix+"A1")+":append?"+params.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	return sheetsJSON(req, &struct{}{})
+}
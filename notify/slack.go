@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const SLACKURL = "https://slack.com/api"
+
+// Slack posts through either a webhook or a bot token
+type Slack struct {
+	WebhookURL string `json:"webhook_url"`
+	BotToken   string `json:"bot_token"`
+	Channel    string `json:"channel"` //channel for bot_token when the job has no slack_channel
+	URL        string `json:"url"`     //defaults to SLACKURL
+	// messages are written here instead of sent when set like for a dry run
+	Preview io.Writer `json:"-"`
+}
+
+// Enabled is whether either a webhook or a bot token is configured
+func (config Slack) Enabled() bool {
+	return config.WebhookURL != "" || config.BotToken != ""
+}
+
+func (config Slack) baseURL() string {
+	if config.URL != "" {
+		return config.URL
+	}
+	return SLACKURL
+}
+
+// slack allows at most 10 fields per section and 50 blocks per message
+const (
+	slackFields = 10
+	slackBlocks = 50
+)
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+// slackEscape escapes the only characters slack mrkdwn reserves
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// Blocks turns a markdown rendered message into block kit
+// section titles are bold, asset classes are text, and symbol rows become fields that slack aligns in columns
+func Blocks(message string) []slackBlock {
+	var result []slackBlock
+	var fields []slackText
+	flush := func() {
+		if len(fields) > 0 {
+			result = append(result, slackBlock{Type: "section", Fields: fields})
+			fields = nil
+		}
+	}
+	for _, line := range strings.Split(slackEscape(message), "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+			result = append(result, slackBlock{Type: "divider"})
+		case strings.HasPrefix(line, "  "):
+			if len(fields) >= slackFields {
+				flush()
+			}
+			field := strings.TrimSpace(line)
+			if i := strings.Index(field, ": "); i > 0 {
+				// the ticker no longer needs padding to line up
+				field = "*" + strings.Trim(field[:i], "` ") + "*\n" + field[i+2:]
+			}
+			fields = append(fields, slackText{Type: "mrkdwn", Text: field})
+		case strings.HasPrefix(line, " "):
+			flush()
+			result = append(result, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: strings.TrimSpace(line)}})
+		case strings.HasSuffix(line, ":"):
+			flush()
+			result = append(result, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "*" + line + "*"}})
+		default:
+			flush()
+			result = append(result, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: line}})
+		}
+	}
+	flush()
+	if len(result) > slackBlocks {
+		// the text fallback still has everything
+		result = result[:slackBlocks]
+	}
+	return result
+}
+
+// Send posts a markdown rendered message to the webhook or the channel of the bot
+func (config Slack) Send(channel, message string) error {
+	if config.Preview != nil {
+		_, err := fmt.Fprintf(config.Preview, "--- slack %s\n%s\n", channel, message)
+		return err
+	}
+	payload := map[string]interface{}{"text": slackEscape(message), "blocks": Blocks(message)}
+	endpoint := config.WebhookURL
+	if config.BotToken != "" {
+		endpoint = config.baseURL() + "/chat.postMessage"
+		payload["channel"] = channel
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if config.BotToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.BotToken)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	response, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned %s: %s", res.Status, response)
+	}
+	if config.BotToken != "" {
+		// the web api reports failures in the body
+		var result struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		err = json.Unmarshal(response, &result)
+		if err != nil {
+			return err
+		}
+		if !result.OK {
+			return fmt.Errorf("slack: %s", result.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// TGLIMIT is the most characters telegram accepts in one message
+const TGLIMIT = 4096
+
+// Split breaks a message longer than limit into messages at section boundaries
+// a section starts at an unindented line like Mints: and lasts until the next one
+// sections longer than limit are broken between lines so formatting of each line stays intact
+func Split(message string, limit int) []string {
+	return splitMeasured(message, limit, length)
+}
+
+// splitMeasured splits like Split with the length of a part as measure counts it
+// like the length after the part is escaped for a parse mode
+func splitMeasured(message string, limit int, measure func(string) int) []string {
+	if measure(message) <= limit {
+		return []string{message}
+	}
+	var sections [][]string
+	for _, line := range strings.Split(message, "\n") {
+		if len(sections) < 1 || (line != "" && !strings.HasPrefix(line, " ")) {
+			sections = append(sections, nil)
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+	var chunks []string
+	var chunk []string
+	add := func(lines []string) {
+		joined := strings.Join(lines, "\n")
+		if len(chunk) > 0 && measure(strings.Join(append(append([]string(nil), chunk...), joined), "\n")) > limit {
+			chunks = append(chunks, strings.Join(chunk, "\n"))
+			chunk = nil
+		}
+		chunk = append(chunk, lines...)
+	}
+	for _, section := range sections {
+		if measure(strings.Join(section, "\n")) <= limit {
+			add(section)
+			continue
+		}
+		for _, line := range section {
+			for _, part := range splitLine(line, limit, measure) {
+				add([]string{part})
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, strings.Join(chunk, "\n"))
+	}
+	// blank lines between sections shouldn't start or end a message
+	for i := range chunks {
+		chunks[i] = strings.Trim(chunks[i], "\n")
+	}
+	return chunks
+}
+
+// splitLine cuts a line longer than limit. only lines that could never fit are cut
+// cuts fall between entities and escapes when they can so each part keeps its formatting
+func splitLine(line string, limit int, measure func(string) int) []string {
+	var parts []string
+	runes := []rune(line)
+	for measure(string(runes)) > limit {
+		cut := limit
+		if cut > len(runes) {
+			cut = len(runes)
+		}
+		for cut > 1 && measure(string(runes[:cut])) > limit {
+			cut--
+		}
+		safe := breaks(string(runes))
+		for i := cut; i > 0; i-- {
+			if safe[i] {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	return append(parts, string(runes))
+}
+
+// breaks marks the runes of a markdown line a cut can go before
+// anywhere in plain text but only around entities like `code` and escapes like \_
+func breaks(line string) []bool {
+	safe := make([]bool, len([]rune(line))+1)
+	for _, e := range parseMarkdown(line) {
+		safe[e.start] = true
+		safe[e.end] = true
+		if e.kind == "text" && e.end-e.start == len([]rune(e.text)) {
+			for i := e.start; i < e.end; i++ {
+				safe[i] = true
+			}
+		}
+	}
+	return safe
+}
+
+// length is how telegram counts characters
+func length(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+// escaped measures like sendParts does for MarkdownV2
+func escaped(part string) int {
+	return length(MarkdownV2(part))
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		limit   int
+		measure func(string) int
+		want    []string
+	}{
+		{"fits", "Transfers:\n  BTC", 20, length, []string{"Transfers:\n  BTC"}},
+		{"at section boundaries", "Mints:\n  aaaa\nBurns:\n  bbbb", 14, length, []string{"Mints:\n  aaaa", "Burns:\n  bbbb"}},
+		{"long section between lines", "Mints:\n  aaaa\n  bbbb", 8, length, []string{"Mints:", "  aaaa", "  bbbb"}},
+		{"line longer than the limit", "abcdefghij", 4, length, []string{"abcd", "efgh", "ij"}},
+		// a.b escapes to a\.b so both lines with the newline are exactly 9
+		{"exactly the limit after escaping", "a.b\na.b", 9, escaped, []string{"a.b\na.b"}},
+		{"one over the limit after escaping", "a.b\na.b", 8, escaped, []string{"a.b", "a.b"}},
+		// a\.b\. is 6 so the reserved character ends the part escaped instead of being cut from its backslash
+		{"reserved character at the boundary", "a.b.c.d", 6, escaped, []string{"a.b.", "c.d"}},
+		{"escape at the boundary", `abc\_def`, 4, length, []string{"abc", `\_de`, "f"}},
+		{"entity at the boundary", "ab `code` cd", 6, length, []string{"ab ", "`code`", " cd"}},
+		{"entity longer than the limit", "`abcdef`", 4, length, []string{"`abc", "def`"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitMeasured(test.message, test.limit, test.measure)
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+			for _, part := range got {
+				if size := test.measure(part); size > test.limit {
+					t.Errorf("part %q is %d over a limit of %d", part, size, test.limit)
+				}
+			}
+		})
+	}
+}
+
+// splitting a legacy message on its own length would send MarkdownV2 parts over the limit
+func TestSplitMeasuresEscapedParts(t *testing.T) {
+	line := "  `BTC` $1.00M from a.b-c to d.e-f (x)"
+	message := "Transfers:\n" + strings.Repeat(line+"\n", 200)
+	for _, part := range splitMeasured(message, TGLIMIT, escaped) {
+		if size := escaped(part); size > TGLIMIT {
+			t.Errorf("part is %d escaped, over %d", size, TGLIMIT)
+		}
+	}
+	if parts := Split(message, TGLIMIT); escaped(parts[0]) <= TGLIMIT {
+		t.Errorf("expected the first part of an unescaped split to be over the limit once escaped")
+	}
+}
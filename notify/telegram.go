@@ -0,0 +1,225 @@
+// Package notify sends reports to chat apps, email, and spreadsheets
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/enzosv/whalesummary/retry"
+)
+
+// Telegram sends through a telegram bot
+type Telegram struct {
+	BotID  string       `json:"bot_id"`
+	Retry  retry.Config `json:"retry"`
+	URL    string       `json:"url"` //defaults to TGURL
+	Policy retry.Policy `json:"-"`   //resolved from Retry by the caller
+	// messages are written here instead of sent when set like for a dry run
+	Preview io.Writer `json:"-"`
+	// called after telegram accepts a message when set
+	Delivered func() `json:"-"`
+}
+
+const TGURL = "https://api.telegram.org"
+
+func (config Telegram) baseURL() string {
+	if config.URL != "" {
+		return strings.TrimSuffix(config.URL, "/")
+	}
+	return TGURL
+}
+
+// Button opens a url from below a message
+// with WebApp instead of URL it opens inside telegram, which only allows it in private chats
+type Button struct {
+	Text   string  `json:"text"`
+	URL    string  `json:"url,omitempty"`
+	WebApp *WebApp `json:"web_app,omitempty"`
+}
+
+// WebApp is the page a web app button opens
+type WebApp struct {
+	URL string `json:"url"`
+}
+
+func constructPayload(chatID, message, parseMode string, button *Button) (*bytes.Reader, error) {
+	payload := map[string]interface{}{}
+	payload["chat_id"] = chatID
+	payload["text"] = message
+	if parseMode != "" {
+		payload["parse_mode"] = parseMode
+	}
+	if button != nil {
+		payload["reply_markup"] = map[string]interface{}{"inline_keyboard": [][]Button{{*button}}}
+	}
+
+	jsonValue, err := json.Marshal(payload)
+	return bytes.NewReader(jsonValue), err
+}
+
+// SendMessage sends markdown
+func (config Telegram) SendMessage(chatID, message string) error {
+	return config.SendFormatted(chatID, message, "markdown")
+}
+
+// SendFormatted sends with a telegram parse mode. empty for plain text
+// messages for MarkdownV2 are written in legacy markdown and escaped when sent
+// messages over TGLIMIT are sent in parts split at section boundaries
+// a part telegram can't parse is sent again as plain text rather than not at all
+func (config Telegram) SendFormatted(chatID, message, parseMode string) error {
+	return config.sendParts(chatID, message, parseMode, nil)
+}
+
+// SendWithButton sends like SendFormatted with the button below the last part
+func (config Telegram) SendWithButton(chatID, message, parseMode string, button Button) error {
+	return config.sendParts(chatID, message, parseMode, &button)
+}
+
+func (config Telegram) sendParts(chatID, message, parseMode string, button *Button) error {
+	var firstErr error
+	measure := length
+	if parseMode == "MarkdownV2" {
+		// escaping adds characters so parts are measured as sent
+		measure = func(part string) int {
+			return length(MarkdownV2(part))
+		}
+	}
+	parts := splitMeasured(message, TGLIMIT, measure)
+	for i, part := range parts {
+		formatted := part
+		if parseMode == "MarkdownV2" {
+			formatted = MarkdownV2(part)
+		}
+		var markup *Button
+		if i == len(parts)-1 {
+			markup = button
+		}
+		err := config.send(chatID, formatted, parseMode, markup)
+		var unparsed *parseError
+		if errors.As(err, &unparsed) {
+			err = config.send(chatID, PlainText(part, parseMode), "", markup)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// parseError is telegram rejecting the formatting of a message
+type parseError struct {
+	description string
+}
+
+func (e *parseError) Error() string {
+	return "telegram could not parse the message: " + e.description
+}
+
+func (config Telegram) send(chatID, message, parseMode string, button *Button) error {
+	if config.Preview != nil {
+		_, err := fmt.Fprintf(config.Preview, "--- telegram %s %s\n%s\n", chatID, parseMode, message)
+		return err
+	}
+	err := config.Policy.Do(context.Background(), func(ctx context.Context) error {
+		payload, err := constructPayload(chatID, message, parseMode, button)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/bot%s/sendMessage", config.baseURL(), config.BotID), payload)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(body))
+		return responseError(res, body, parseMode)
+	})
+	if err != nil {
+		fmt.Println(err)
+	} else if config.Delivered != nil {
+		config.Delivered()
+	}
+	return err
+}
+
+// responseError is how a telegram response is retried. nil when it was sent
+func responseError(res *http.Response, body []byte, parseMode string) error {
+	if res.StatusCode == http.StatusBadRequest && parseMode != "" && strings.Contains(string(body), "can't parse entities") {
+		return retry.Permanent(&parseError{description: string(body)})
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		return retry.After(fmt.Errorf("telegram returned %s", res.Status), retry.RetryAfter(res.Header))
+	}
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("telegram returned %s", res.Status)
+	}
+	if res.StatusCode >= 400 {
+		// like a chat that doesn't exist or a bot that was removed from it
+		return retry.Permanent(fmt.Errorf("telegram returned %s: %s", res.Status, strings.TrimSpace(string(body))))
+	}
+	return nil
+}
+
+// SendPhoto sends a png with a caption
+func (config Telegram) SendPhoto(chatID, caption string, photo []byte) error {
+	if config.Preview != nil {
+		_, err := fmt.Fprintf(config.Preview, "--- telegram %s photo of %d bytes\n%s\n", chatID, len(photo), caption)
+		return err
+	}
+	err := config.Policy.Do(context.Background(), func(ctx context.Context) error {
+		var payload bytes.Buffer
+		writer := multipart.NewWriter(&payload)
+		writer.WriteField("chat_id", chatID)
+		writer.WriteField("caption", caption)
+		part, err := writer.CreateFormFile("photo", "heatmap.png")
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(photo)
+		if err != nil {
+			return err
+		}
+		err = writer.Close()
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/bot%s/sendPhoto", config.baseURL(), config.BotID), &payload)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(body))
+		return responseError(res, body, "")
+	})
+	if err != nil {
+		fmt.Println(err)
+	} else if config.Delivered != nil {
+		config.Delivered()
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/enzosv/whalesummary/retry"
+)
+
+// photos are retried and counted like messages
+func TestSendPhotoRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		tries     int
+		delivered bool
+	}{
+		{"sent", []int{http.StatusOK}, 1, true},
+		{"server error is retried", []int{http.StatusBadGateway, http.StatusOK}, 2, true},
+		{"rate limit is retried", []int{http.StatusTooManyRequests, http.StatusOK}, 2, true},
+		{"missing chat stops", []int{http.StatusBadRequest, http.StatusOK}, 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tries := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(1 << 20); err != nil || r.FormValue("chat_id") != "1" {
+					t.Errorf("photo form %v without the chat", err)
+				}
+				w.WriteHeader(test.statuses[tries])
+				tries++
+				w.Write([]byte(`{"ok": false}`))
+			}))
+			defer server.Close()
+
+			delivered := false
+			config := Telegram{
+				URL:       server.URL,
+				Policy:    retry.Policy{Attempts: 3, Backoff: time.Millisecond, Timeout: time.Second},
+				Delivered: func() { delivered = true },
+			}
+			err := config.SendPhoto("1", "heatmap", []byte("png"))
+			if tries != test.tries || delivered != test.delivered || (err == nil) != test.delivered {
+				t.Errorf("tried %d times and delivered %v with %v, want %d and %v", tries, delivered, err, test.tries, test.delivered)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Update is a message or channel post sent to the bot
+type Update struct {
+	ID          int64    `json:"update_id"`
+	Message     *Message `json:"message"`
+	ChannelPost *Message `json:"channel_post"`
+}
+
+// Message is the part of a telegram message commands need
+type Message struct {
+	ID   int64  `json:"message_id"`
+	Text string `json:"text"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// Post is the message or channel post of the update
+func (update Update) Post() *Message {
+	if update.Message != nil {
+		return update.Message
+	}
+	return update.ChannelPost
+}
+
+// Updates long polls for updates from offset and waits up to wait for one to arrive
+func (config Telegram) Updates(ctx context.Context, offset int64, wait time.Duration) ([]Update, error) {
+	params := url.Values{}
+	params.Add("offset", strconv.FormatInt(offset, 10))
+	params.Add("timeout", strconv.Itoa(int(wait/time.Second)))
+	params.Add("allowed_updates", `["message","channel_post"]`)
+	// long polling outlasts the timeout of the retry policy
+	ctx, cancel := context.WithTimeout(ctx, wait+10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/bot%s/getUpdates?%s", config.baseURL(), config.BotID, params.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response struct {
+		OK          bool     `json:"ok"`
+		Description string   `json:"description"`
+		Result      []Update `json:"result"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	if !response.OK {
+		return nil, fmt.Errorf("telegram getUpdates: %s", response.Description)
+	}
+	return response.Result, nil
+}
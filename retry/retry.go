@@ -0,0 +1,159 @@
+// Package retry tries calls to flaky endpoints again with a doubling backoff
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Config is how many times and how long to try an endpoint
+type Config struct {
+	Attempts int    `json:"attempts"` //total tries including the first
+	Backoff  string `json:"backoff"`  //wait before the first retry. doubles after each retry
+	Timeout  string `json:"timeout"`  //limit for each try
+	// fraction of each backoff that is randomized so clients failing together don't retry together
+	// a pointer so an explicit 0 turns off the default
+	Jitter *float64 `json:"jitter"`
+}
+
+// Policy is a resolved Config
+type Policy struct {
+	Attempts int
+	Backoff  time.Duration
+	Timeout  time.Duration
+	Jitter   float64
+}
+
+// Policy fills in missing values from defaults and validates the rest
+func (c Config) Policy(defaults Policy) (Policy, error) {
+	policy := defaults
+	if c.Attempts < 0 {
+		return policy, fmt.Errorf("attempts must not be negative: %d", c.Attempts)
+	}
+	if c.Attempts > 0 {
+		policy.Attempts = c.Attempts
+	}
+	var err error
+	if c.Backoff != "" {
+		policy.Backoff, err = time.ParseDuration(c.Backoff)
+		if err != nil {
+			return policy, err
+		}
+		if policy.Backoff < 0 {
+			return policy, fmt.Errorf("backoff must not be negative: %s", c.Backoff)
+		}
+	}
+	if c.Timeout != "" {
+		policy.Timeout, err = time.ParseDuration(c.Timeout)
+		if err != nil {
+			return policy, err
+		}
+		if policy.Timeout <= 0 {
+			return policy, fmt.Errorf("timeout must be positive: %s", c.Timeout)
+		}
+	}
+	if c.Jitter != nil {
+		if *c.Jitter < 0 || *c.Jitter > 1 {
+			return policy, fmt.Errorf("jitter must be between 0 and 1: %g", *c.Jitter)
+		}
+		policy.Jitter = *c.Jitter
+	}
+	return policy, nil
+}
+
+// Do calls try until it succeeds or runs out of attempts
+// each try gets its own timeout
+func (policy Policy) Do(ctx context.Context, try func(ctx context.Context) error) error {
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		tryCtx, cancel := context.WithTimeout(ctx, policy.Timeout)
+		err := try(tryCtx)
+		cancel()
+		var stop *permanentError
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if err == nil {
+			return nil
+		}
+		var later *delayedError
+		if errors.As(err, &later) {
+			err = later.err
+		}
+		if attempt >= policy.Attempts {
+			if attempt > 1 {
+				return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+		wait := policy.jitter(backoff)
+		if later != nil && later.wait > wait {
+			// the server knows better when it can take another call
+			wait = later.wait
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// jitter spreads the backoff randomly by up to the jitter fraction either way
+func (policy Policy) jitter(backoff time.Duration) time.Duration {
+	if policy.Jitter <= 0 {
+		return backoff
+	}
+	spread := float64(backoff) * policy.Jitter
+	return backoff + time.Duration(spread*(2*rand.Float64()-1))
+}
+
+// permanentError is not worth retrying
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent marks err so Policy.Do returns it without retrying
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// delayedError should not be retried sooner than wait
+type delayedError struct {
+	err  error
+	wait time.Duration
+}
+
+func (e *delayedError) Error() string {
+	return e.err.Error()
+}
+
+// After marks err so Policy.Do waits at least wait before the next try
+func After(err error, wait time.Duration) error {
+	return &delayedError{err: err, wait: wait}
+}
+
+// RetryAfter reads the Retry-After header as seconds or an http date. 0 if missing
+func RetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfigPolicyJitter(t *testing.T) {
+	zero, half, negative, over := 0.0, 0.5, -0.1, 1.5
+	defaults := Policy{Attempts: 3, Backoff: time.Second, Timeout: time.Second, Jitter: 0.2}
+	tests := []struct {
+		name   string
+		jitter *float64
+		want   float64
+		err    bool
+	}{
+		{"missing keeps the default", nil, 0.2, false},
+		{"zero turns it off", &zero, 0, false},
+		{"set overrides the default", &half, 0.5, false},
+		{"negative", &negative, 0, true},
+		{"over one", &over, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := Config{Jitter: test.jitter}.Policy(defaults)
+			if (err != nil) != test.err {
+				t.Fatalf("error %v, want error %v", err, test.err)
+			}
+			if !test.err && policy.Jitter != test.want {
+				t.Errorf("jitter %g, want %g", policy.Jitter, test.want)
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name     string
+		attempts int
+		err      func(try int) error
+		tries    int
+		wait     time.Duration // least time Do should take
+	}{
+		{"success stops", 3, func(try int) error { return nil }, 1, 0},
+		{"retries until success", 3, func(try int) error {
+			if try < 2 {
+				return failed
+			}
+			return nil
+		}, 2, 0},
+		{"stops at the attempt cap", 3, func(try int) error { return failed }, 3, 0},
+		{"a single attempt never retries", 1, func(try int) error { return failed }, 1, 0},
+		{"permanent stops right away", 3, func(try int) error { return Permanent(failed) }, 1, 0},
+		{"after waits longer than the backoff", 2, func(try int) error { return After(failed, 50*time.Millisecond) }, 2, 50 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := Policy{Attempts: test.attempts, Backoff: time.Millisecond, Timeout: time.Second}
+			tries := 0
+			started := time.Now()
+			err := policy.Do(context.Background(), func(ctx context.Context) error {
+				tries++
+				return test.err(tries)
+			})
+			if tries != test.tries {
+				t.Errorf("tried %d times, want %d", tries, test.tries)
+			}
+			if elapsed := time.Since(started); elapsed < test.wait {
+				t.Errorf("took %s, want at least %s", elapsed, test.wait)
+			}
+			if final := test.err(tries); final != nil && !errors.Is(err, failed) {
+				t.Errorf("returned %v, want it to wrap %v", err, failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+CREATE TABLE IF NOT EXISTS whales (
+	blockchain TEXT NOT NULL,
+	address TEXT NOT NULL,
+	owner TEXT,
+	owner_type TEXT,
+	PRIMARY KEY (blockchain, address)
+);
+
+-- every fetched transaction. the same hash can move several symbols between several wallets
+CREATE TABLE IF NOT EXISTS whale_transactions (
+	blockchain TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	symbol TEXT NOT NULL,
+	transaction_type TEXT NOT NULL,
+	from_address TEXT NOT NULL,
+	from_owner TEXT,
+	from_owner_type TEXT,
+	to_address TEXT NOT NULL,
+	to_owner TEXT,
+	to_owner_type TEXT,
+	amount DOUBLE PRECISION NOT NULL,
+	amount_usd DOUBLE PRECISION NOT NULL,
+	timestamp TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (blockchain, hash, symbol, from_address, to_address)
+);
+CREATE INDEX IF NOT EXISTS whale_transactions_timestamp ON whale_transactions (timestamp);
+
+-- net usd flow per symbol of each reported period
+CREATE TABLE IF NOT EXISTS summaries (
+	period_start TIMESTAMPTZ NOT NULL,
+	period_end TIMESTAMPTZ NOT NULL,
+	section TEXT NOT NULL,
+	symbol TEXT NOT NULL,
+	amount_usd DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (period_start, section, symbol)
+);
+
+-- owner labels that belong to the same entity like binance.je and binance
+CREATE TABLE IF NOT EXISTS entities (
+	label TEXT PRIMARY KEY,
+	entity TEXT NOT NULL
+);
+
+-- symbols that look like wrapped or bridged variants of another and what they were suggested to be remapped to
+CREATE TABLE IF NOT EXISTS remap_suggestions (
+	symbol TEXT PRIMARY KEY,
+	remap TEXT NOT NULL,
+	first_seen TIMESTAMPTZ NOT NULL,
+	last_seen TIMESTAMPTZ NOT NULL,
+	amount_usd DOUBLE PRECISION NOT NULL
+);
+
+-- who each source says owns an address like a manual label, an imported list, or an ens name
+-- addresses are lowercase. whale alert's own labels stay in whales
+CREATE TABLE IF NOT EXISTS wallet_labels (
+	blockchain TEXT NOT NULL,
+	address TEXT NOT NULL,
+	source TEXT NOT NULL,
+	owner TEXT,
+	owner_type TEXT,
+	confidence DOUBLE PRECISION NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (blockchain, address, source)
+);
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/retry"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+
+	// registers the sqlite driver. pure go so the binary still cross compiles
+	_ "modernc.org/sqlite"
+)
+
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// sqliteSchema is created on every connection since a sqlite log is just a file without a setup step
+//
+//go:embed sqlite.sql
+var sqliteSchema string
+
+// ValidDriver is whether the driver is supported. empty is postgres
+func ValidDriver(driver string) bool {
+	return driver == "" || driver == Postgres || driver == SQLite
+}
+
+func (db DB) sqlite() bool {
+	return db.Driver == SQLite
+}
+
+// Connect opens the log
+// a sqlite log is created with its tables if it doesn't exist yet
+func (db DB) Connect(ctx context.Context) (*sql.DB, error) {
+	var conn *sql.DB
+	err := db.Policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if db.sqlite() {
+			conn, err = sql.Open(SQLite, db.URL)
+			if err != nil {
+				return retry.Permanent(err)
+			}
+			_, err = conn.ExecContext(ctx, sqliteSchema)
+			if err != nil {
+				conn.Close()
+				return fmt.Errorf("create sqlite tables: %w", err)
+			}
+			return nil
+		}
+		config, err := pgx.ParseConfig(db.URL)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		if db.Schema != "" {
+			config.RuntimeParams["search_path"] = db.Schema
+		}
+		conn = stdlib.OpenDB(*config)
+		err = conn.PingContext(ctx)
+		if err != nil {
+			conn.Close()
+		}
+		return err
+	})
+	return conn, err
+}
+
+// insertRows adds the rows to the table skipping any that are already there
+// postgres copies them into a temporary table in one round trip then inserts them with one statement
+// sqlite inserts them one by one in a single transaction
+// nothing is inserted if any row fails
+func (db DB) insertRows(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	if len(rows) < 1 {
+		return nil
+	}
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	list := strings.Join(columns, ", ")
+	if db.sqlite() {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		params := make([]string, len(columns))
+		for i := range columns {
+			params[i] = fmt.Sprintf("$%d", i+1)
+		}
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING;", table, list, strings.Join(params, ", ")))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, row := range rows {
+			_, err = stmt.ExecContext(ctx, row...)
+			if err != nil {
+				return fmt.Errorf("insert into %s: %w", table, err)
+			}
+		}
+		return tx.Commit()
+	}
+	raw, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer raw.Close()
+	return raw.Raw(func(driverConn interface{}) error {
+		pg := driverConn.(*stdlib.Conn).Conn()
+		tx, err := pg.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		staging := table + "_staging"
+		_, err = tx.Exec(ctx, fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP;", staging, table))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{staging}, columns, pgx.CopyFromRows(rows))
+		if err != nil {
+			return fmt.Errorf("copy into %s: %w", table, err)
+		}
+		_, err = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT DO NOTHING;", table, list, list, staging))
+		if err != nil {
+			return fmt.Errorf("insert into %s: %w", table, err)
+		}
+		return tx.Commit(ctx)
+	})
+}
+
+// timeValue is a unix seconds timestamp as the type the stored column takes
+func (db DB) timeValue(unix int64) interface{} {
+	if db.sqlite() {
+		return unix
+	}
+	return time.Unix(unix, 0).UTC()
+}
+
+// the sql that differs between postgres and sqlite
+// postgres keeps timestamps as timestamptz and sqlite as unix seconds
+
+// time is the stored timestamp of a unix seconds parameter like $1
+func (db DB) time(param string) string {
+	if db.sqlite() {
+		return param
+	}
+	return "to_timestamp(" + param + ")"
+}
+
+// epoch is the unix seconds of a stored timestamp
+func (db DB) epoch(column string) string {
+	if db.sqlite() {
+		return column
+	}
+	return "EXTRACT(EPOCH FROM " + column + ")::BIGINT"
+}
+
+// weekday is the day of the week of a stored timestamp in utc with sunday as 0
+func (db DB) weekday(column string) string {
+	if db.sqlite() {
+		return "CAST(strftime('%w', " + column + ", 'unixepoch') AS INTEGER)"
+	}
+	return "EXTRACT(DOW FROM " + column + " AT TIME ZONE 'UTC')"
+}
+
+// hour is the hour of a stored timestamp in utc
+func (db DB) hour(column string) string {
+	if db.sqlite() {
+		return "CAST(strftime('%H', " + column + ", 'unixepoch') AS INTEGER)"
+	}
+	return "EXTRACT(HOUR FROM " + column + " AT TIME ZONE 'UTC')"
+}
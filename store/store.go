@@ -0,0 +1,788 @@
+// Package store logs transactions and summaries in postgres or sqlite for comparisons with previous periods
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/enzosv/whalesummary/retry"
+	"github.com/enzosv/whalesummary/summary"
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// Averages is the trailing average magnitude of net flow per period by section then symbol
+type Averages map[string]map[string]float64
+
+// DB is the optional log
+type DB struct {
+	URL    string       // postgres url or sqlite file
+	Driver string       // Postgres or SQLite. defaults to Postgres
+	Schema string       // search_path of every postgres connection so tenants can share a database
+	Policy retry.Policy // resolved by the caller
+}
+
+// Enabled is whether a url is configured
+func (db DB) Enabled() bool {
+	return db.URL != ""
+}
+
+// LogWhales remembers the owner of every address
+// the first owner logged for an address is kept
+func LogWhales(ctx context.Context, db DB, transactions []whalealert.Transaction) error {
+	rows := make([][]interface{}, 0, len(transactions)*2)
+	for _, transaction := range transactions {
+		for _, wallet := range []whalealert.Wallet{transaction.From, transaction.To} {
+			rows = append(rows, []interface{}{transaction.Blockchain, wallet.Address, nullable(wallet.Owner), wallet.OwnerType})
+		}
+	}
+	return db.insertRows(ctx, "whales", []string{"blockchain", "address", "owner", "owner_type"}, rows)
+}
+
+// LogTransactions stores every fetched transaction so later runs can analyze and dedupe history
+func LogTransactions(ctx context.Context, db DB, transactions []whalealert.Transaction) error {
+	rows := make([][]interface{}, len(transactions))
+	for i, transaction := range transactions {
+		rows[i] = []interface{}{transaction.Blockchain, transaction.Hash, transaction.Symbol, transaction.TransactionType,
+			transaction.From.Address, nullable(transaction.From.Owner), transaction.From.OwnerType,
+			transaction.To.Address, nullable(transaction.To.Owner), transaction.To.OwnerType,
+			transaction.Amount, transaction.AmountUsd, db.timeValue(int64(transaction.Timestamp))}
+	}
+	return db.insertRows(ctx, "whale_transactions", []string{"blockchain", "hash", "symbol", "transaction_type",
+		"from_address", "from_owner", "from_owner_type", "to_address", "to_owner", "to_owner_type",
+		"amount", "amount_usd", "timestamp"}, rows)
+}
+
+// nullable is nil for an empty owner so unknown owners are stored as null
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (db DB) summaryInsert() string {
+	return `
+		INSERT INTO summaries
+		(period_start, period_end, section, symbol, amount_usd)
+		VALUES (` + db.time("$1") + `, ` + db.time("$2") + `, $3, $4, $5)
+		ON CONFLICT (period_start, section, symbol) DO UPDATE SET amount_usd = EXCLUDED.amount_usd;
+	`
+}
+
+// LogSummary stores the net flow per section then symbol of a period so later periods can be compared against it
+func LogSummary(ctx context.Context, db DB, start, end int64, sections map[string]map[string]float64) error {
+	query := db.summaryInsert()
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for section, flows := range sections {
+		for symbol, value := range flows {
+			_, err = conn.ExecContext(ctx, query, start, end, section, symbol, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReplaceSummary swaps the stored net flows of a period for the sections
+// so symbols that no longer flow are gone instead of keeping their old value
+func ReplaceSummary(ctx context.Context, db DB, start, end int64, sections map[string]map[string]float64) error {
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `DELETE FROM summaries WHERE period_start = `+db.time("$1")+`;`, start)
+	if err != nil {
+		return err
+	}
+	query := db.summaryInsert()
+	for section, flows := range sections {
+		for symbol, value := range flows {
+			_, err = tx.ExecContext(ctx, query, start, end, section, symbol, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Period is the start and end of a stored summary
+type Period struct {
+	Start int64
+	End   int64
+}
+
+// FetchPeriods is every period with a stored summary that started in the range, oldest first
+func FetchPeriods(ctx context.Context, db DB, since, until int64) ([]Period, error) {
+	query := `
+		SELECT DISTINCT ` + db.epoch("period_start") + `, ` + db.epoch("period_end") + `
+		FROM summaries
+		WHERE period_start >= ` + db.time("$1") + ` AND period_start < ` + db.time("$2") + `
+		ORDER BY 1;
+	`
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, query, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var periods []Period
+	for rows.Next() {
+		var period Period
+		err = rows.Scan(&period.Start, &period.End)
+		if err != nil {
+			return periods, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+// ReplaceTransactions swaps the stored transactions in the range for the transactions
+// for windows whale alert revised or removed transactions of after they were logged
+func ReplaceTransactions(ctx context.Context, db DB, since, until int64, transactions []whalealert.Transaction) error {
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `DELETE FROM whale_transactions WHERE timestamp >= `+db.time("$1")+` AND timestamp < `+db.time("$2")+`;`, since, until)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO whale_transactions
+		(blockchain, hash, symbol, transaction_type, from_address, from_owner, from_owner_type, to_address, to_owner, to_owner_type, amount, amount_usd, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT DO NOTHING;
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, transaction := range transactions {
+		_, err = stmt.ExecContext(ctx, transaction.Blockchain, transaction.Hash, transaction.Symbol, transaction.TransactionType,
+			transaction.From.Address, nullable(transaction.From.Owner), transaction.From.OwnerType,
+			transaction.To.Address, nullable(transaction.To.Owner), transaction.To.OwnerType,
+			transaction.Amount, transaction.AmountUsd, db.timeValue(int64(transaction.Timestamp)))
+		if err != nil {
+			return fmt.Errorf("insert into whale_transactions: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// LogRemapSuggestions remembers every suggested remap with when it was seen and the usd value it moved
+// and returns the ones never suggested before so each is only brought up once
+func LogRemapSuggestions(ctx context.Context, db DB, seen int64, suggestions []summary.RemapSuggestion) ([]summary.RemapSuggestion, error) {
+	query := `
+		INSERT INTO remap_suggestions
+		(symbol, remap, first_seen, last_seen, amount_usd)
+		VALUES ($1, $2, ` + db.time("$3") + `, ` + db.time("$3") + `, $4)
+		ON CONFLICT (symbol) DO UPDATE SET
+		remap = EXCLUDED.remap, last_seen = EXCLUDED.last_seen, amount_usd = remap_suggestions.amount_usd + EXCLUDED.amount_usd;
+	`
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var fresh []summary.RemapSuggestion
+	for _, suggestion := range suggestions {
+		var previous int
+		err = conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM remap_suggestions WHERE symbol = $1;`, suggestion.Symbol).Scan(&previous)
+		if err != nil {
+			return fresh, err
+		}
+		_, err = conn.ExecContext(ctx, query, suggestion.Symbol, suggestion.Remap, seen, suggestion.AmountUsd)
+		if err != nil {
+			return fresh, err
+		}
+		if previous == 0 {
+			fresh = append(fresh, suggestion)
+		}
+	}
+	return fresh, nil
+}
+
+// FetchAverages is the average magnitude of net flow per period by section then symbol
+// periods where a symbol had no flow count as zero
+func FetchAverages(ctx context.Context, db DB, since, until int64) (Averages, error) {
+	query := `
+		WITH periods AS (
+			SELECT COUNT(DISTINCT period_start) AS n
+			FROM summaries
+			WHERE period_start >= ` + db.time("$1") + ` AND period_start < ` + db.time("$2") + `
+		)
+		SELECT section, symbol, SUM(ABS(amount_usd)) / (SELECT n FROM periods)
+		FROM summaries
+		WHERE period_start >= ` + db.time("$1") + ` AND period_start < ` + db.time("$2") + `
+		GROUP BY section, symbol;
+	`
+	return queryAverages(ctx, db, query, since, until)
+}
+
+// queryAverages reads rows of section, symbol, and average into Averages
+func queryAverages(ctx context.Context, db DB, query string, args ...interface{}) (Averages, error) {
+	averages := Averages{}
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return averages, err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return averages, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var section, symbol string
+		var average float64
+		err = rows.Scan(&section, &symbol, &average)
+		if err != nil {
+			return averages, err
+		}
+		if averages[section] == nil {
+			averages[section] = map[string]float64{}
+		}
+		averages[section][symbol] = average
+	}
+	return averages, rows.Err()
+}
+
+// FetchEntities is the entity of every label in the entities table
+func FetchEntities(ctx context.Context, db DB) (map[string]string, error) {
+	if db.URL == "" {
+		return nil, nil
+	}
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, `SELECT label, entity FROM entities;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := map[string]string{}
+	for rows.Next() {
+		var label, entity string
+		err = rows.Scan(&label, &entity)
+		if err != nil {
+			return entities, err
+		}
+		entities[strings.ToLower(label)] = entity
+	}
+	return entities, rows.Err()
+}
+
+// FetchPeriodFlows loads stored net flows of a section by symbol then period start
+func FetchPeriodFlows(ctx context.Context, db DB, section string, since, until int64) ([]int64, map[string]map[int64]float64, error) {
+	query := `
+		SELECT ` + db.epoch("period_start") + `, symbol, amount_usd
+		FROM summaries
+		WHERE section = $1 AND period_start >= ` + db.time("$2") + ` AND period_start < ` + db.time("$3") + `
+		ORDER BY period_start;
+	`
+	flows := map[string]map[int64]float64{}
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, flows, err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, query, section, since, until)
+	if err != nil {
+		return nil, flows, err
+	}
+	defer rows.Close()
+	var periods []int64
+	for rows.Next() {
+		var period int64
+		var symbol string
+		var value float64
+		err = rows.Scan(&period, &symbol, &value)
+		if err != nil {
+			return periods, flows, err
+		}
+		if len(periods) < 1 || periods[len(periods)-1] != period {
+			periods = append(periods, period)
+		}
+		if flows[symbol] == nil {
+			flows[symbol] = map[int64]float64{}
+		}
+		flows[symbol][period] = value
+	}
+	return periods, flows, rows.Err()
+}
+
+// FetchSeasonalAverages is the average magnitude of net flow of periods that started
+// on the same weekday and hour in utc as start over the past weeks
+// so flows that are routinely heavy at that time aren't reported as unusual
+func FetchSeasonalAverages(ctx context.Context, db DB, start int64, weeks int) (Averages, error) {
+	query := `
+		WITH matching AS (
+			SELECT period_start, section, symbol, amount_usd
+			FROM summaries
+			WHERE period_start >= ` + db.time("$1") + ` AND period_start < ` + db.time("$2") + `
+			AND ` + db.weekday("period_start") + ` = $3
+			AND ` + db.hour("period_start") + ` = $4
+		), periods AS (
+			SELECT COUNT(DISTINCT period_start) AS n
+			FROM matching
+		)
+		SELECT section, symbol, SUM(ABS(amount_usd)) / (SELECT n FROM periods)
+		FROM matching
+		GROUP BY section, symbol;
+	`
+	t := time.Unix(start, 0).UTC()
+	since := t.AddDate(0, 0, -7*weeks).Unix()
+	return queryAverages(ctx, db, query, since, start, int(t.Weekday()), t.Hour())
+}
+
+// Flow is the stored net flow of a symbol in one section of one period
+type Flow struct {
+	Start     int64   `json:"start"`
+	End       int64   `json:"end"`
+	Section   string  `json:"section"`
+	AmountUsd float64 `json:"amount_usd"`
+}
+
+// FetchSummary sums the stored net flows of periods that started in the range by section then symbol
+func FetchSummary(ctx context.Context, db DB, since, until int64) (map[string]map[string]float64, error) {
+	query := `
+		SELECT section, symbol, SUM(amount_usd)
+		FROM summaries
+		WHERE period_start >= ` + db.time("$1") + ` AND period_start < ` + db.time("$2") + `
+		GROUP BY section, symbol;
+	`
+	// same shape as averages
+	return queryAverages(ctx, db, query, since, until)
+}
+
+// FetchSymbolFlows is every stored net flow of a symbol in periods that started in the range, oldest first
+func FetchSymbolFlows(ctx context.Context, db DB, symbol string, since, until int64) ([]Flow, error) {
+	query := `
+		SELECT ` + db.epoch("period_start") + `, ` + db.epoch("period_end") + `, section, amount_usd
+		FROM summaries
+		WHERE LOWER(symbol) = LOWER($1) AND period_start >= ` + db.time("$2") + ` AND period_start < ` + db.time("$3") + `
+		ORDER BY period_start, section;
+	`
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, query, symbol, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	flows := []Flow{}
+	for rows.Next() {
+		var flow Flow
+		err = rows.Scan(&flow.Start, &flow.End, &flow.Section, &flow.AmountUsd)
+		if err != nil {
+			return flows, err
+		}
+		flows = append(flows, flow)
+	}
+	return flows, rows.Err()
+}
+
+// transactionColumns are scanned by queryTransactions
+func (db DB) transactionColumns() string {
+	return `
+	blockchain, hash, symbol, transaction_type,
+	from_address, COALESCE(from_owner, ''), COALESCE(from_owner_type, ''),
+	to_address, COALESCE(to_owner, ''), COALESCE(to_owner_type, ''),
+	amount, amount_usd, ` + db.epoch("timestamp") + `
+`
+}
+
+// FetchTransactions is the stored transactions in the range, newest first
+// symbol is optional. limit is the most rows to return
+func FetchTransactions(ctx context.Context, db DB, since, until int64, symbol string, limit int) ([]whalealert.Transaction, error) {
+	query := `
+		SELECT ` + db.transactionColumns() + `
+		FROM whale_transactions
+		WHERE timestamp >= ` + db.time("$1") + ` AND timestamp < ` + db.time("$2") + `
+		AND ($3 = '' OR LOWER(symbol) = LOWER($3))
+		ORDER BY timestamp DESC
+		LIMIT $4;
+	`
+	return queryTransactions(ctx, db, query, since, until, symbol, limit)
+}
+
+// FetchLargestTransactions is the stored transactions in the range with the largest usd value first
+// symbol is optional. limit is the most rows to return
+func FetchLargestTransactions(ctx context.Context, db DB, since, until int64, symbol string, limit int) ([]whalealert.Transaction, error) {
+	query := `
+		SELECT ` + db.transactionColumns() + `
+		FROM whale_transactions
+		WHERE timestamp >= ` + db.time("$1") + ` AND timestamp < ` + db.time("$2") + `
+		AND ($3 = '' OR LOWER(symbol) = LOWER($3))
+		ORDER BY amount_usd DESC
+		LIMIT $4;
+	`
+	return queryTransactions(ctx, db, query, since, until, symbol, limit)
+}
+
+// FetchLoggedTransactions is the stored transactions with any of the hashes in the range
+// so transactions fetched again by an overlapping or retried window can be told apart
+func FetchLoggedTransactions(ctx context.Context, db DB, since, until int64, hashes []string) ([]whalealert.Transaction, error) {
+	var transactions []whalealert.Transaction
+	err := db.queryAddresses(ctx, hashes, 2, func(conn *sql.DB, params string, args []interface{}) error {
+		rows, err := conn.QueryContext(ctx, `
+			SELECT `+db.transactionColumns()+`
+			FROM whale_transactions
+			WHERE timestamp >= `+db.time("$1")+` AND timestamp < `+db.time("$2")+` AND hash IN (`+params+`);
+		`, append([]interface{}{since, until}, args...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		transactions, err = scanTransactions(rows, transactions)
+		return err
+	})
+	return transactions, err
+}
+
+// queryTransactions reads rows of transactionColumns
+func queryTransactions(ctx context.Context, db DB, query string, args ...interface{}) ([]whalealert.Transaction, error) {
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTransactions(rows, []whalealert.Transaction{})
+}
+
+// scanTransactions appends the rows of transactionColumns to transactions
+func scanTransactions(rows *sql.Rows, transactions []whalealert.Transaction) ([]whalealert.Transaction, error) {
+	for rows.Next() {
+		var transaction whalealert.Transaction
+		var timestamp int64
+		err := rows.Scan(&transaction.Blockchain, &transaction.Hash, &transaction.Symbol, &transaction.TransactionType,
+			&transaction.From.Address, &transaction.From.Owner, &transaction.From.OwnerType,
+			&transaction.To.Address, &transaction.To.Owner, &transaction.To.OwnerType,
+			&transaction.Amount, &transaction.AmountUsd, &timestamp)
+		if err != nil {
+			return transactions, err
+		}
+		transaction.Timestamp = int(timestamp)
+		transaction.TransactionCount = 1
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// Whale is what the log knows about an address on one blockchain
+type Whale struct {
+	Blockchain string
+	Address    string
+	Owner      string
+	OwnerType  string
+	FirstSeen  int64 // 0 if no transaction was stored
+	LastSeen   int64
+	Count      int     // stored transactions
+	VolumeUsd  float64 // usd value of the stored transactions
+}
+
+// FetchWhales is the address on every blockchain it was seen on
+// whale alert attributions come from the whales table and activity from whale_transactions
+func FetchWhales(ctx context.Context, db DB, address string) ([]Whale, error) {
+	query := `
+		WITH activity AS (
+			SELECT blockchain, LOWER($1) AS address,
+			MIN(` + db.epoch("timestamp") + `) AS first_seen, MAX(` + db.epoch("timestamp") + `) AS last_seen, COUNT(*) AS n, SUM(amount_usd) AS volume
+			FROM whale_transactions
+			WHERE LOWER(from_address) = LOWER($1) OR LOWER(to_address) = LOWER($1)
+			GROUP BY blockchain
+		), labels AS (
+			SELECT blockchain, address, COALESCE(owner, '') AS owner, COALESCE(owner_type, '') AS owner_type
+			FROM whales
+			WHERE LOWER(address) = LOWER($1)
+		)
+		SELECT l.blockchain, l.address, l.owner, l.owner_type,
+		COALESCE(a.first_seen, 0), COALESCE(a.last_seen, 0), COALESCE(a.n, 0), COALESCE(a.volume, 0)
+		FROM labels l
+		LEFT JOIN activity a ON a.blockchain = l.blockchain
+		UNION ALL
+		-- activity on blockchains without a label. sqlite has no full outer join
+		SELECT a.blockchain, a.address, '', '', a.first_seen, a.last_seen, a.n, a.volume
+		FROM activity a
+		WHERE NOT EXISTS (SELECT 1 FROM labels l WHERE l.blockchain = a.blockchain)
+		ORDER BY 6 DESC;
+	`
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, query, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var whales []Whale
+	for rows.Next() {
+		var whale Whale
+		err = rows.Scan(&whale.Blockchain, &whale.Address, &whale.Owner, &whale.OwnerType,
+			&whale.FirstSeen, &whale.LastSeen, &whale.Count, &whale.VolumeUsd)
+		if err != nil {
+			return whales, err
+		}
+		whales = append(whales, whale)
+	}
+	return whales, rows.Err()
+}
+
+// FetchAddressTransactions is the largest stored transactions from or to the address since, largest first
+func FetchAddressTransactions(ctx context.Context, db DB, address string, since int64, limit int) ([]whalealert.Transaction, error) {
+	query := `
+		SELECT ` + db.transactionColumns() + `
+		FROM whale_transactions
+		WHERE (LOWER(from_address) = LOWER($1) OR LOWER(to_address) = LOWER($1))
+		AND timestamp >= ` + db.time("$2") + `
+		ORDER BY amount_usd DESC
+		LIMIT $3;
+	`
+	return queryTransactions(ctx, db, query, address, since, limit)
+}
+
+// FetchMoverTransactions is the newest stored transactions in the range from or to any of the addresses or owners
+// names are lowercase like an address and every owner label of an entity
+func FetchMoverTransactions(ctx context.Context, db DB, names []string, since, until int64, limit int) ([]whalealert.Transaction, error) {
+	var transactions []whalealert.Transaction
+	err := db.queryAddresses(ctx, names, 3, func(conn *sql.DB, params string, args []interface{}) error {
+		rows, err := conn.QueryContext(ctx, `
+			SELECT `+db.transactionColumns()+`
+			FROM whale_transactions
+			WHERE timestamp >= `+db.time("$1")+` AND timestamp < `+db.time("$2")+`
+			AND (LOWER(from_address) IN (`+params+`) OR LOWER(to_address) IN (`+params+`)
+			OR LOWER(from_owner) IN (`+params+`) OR LOWER(to_owner) IN (`+params+`))
+			ORDER BY timestamp DESC
+			LIMIT $3;
+		`, append([]interface{}{since, until, limit}, args...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		transactions, err = scanTransactions(rows, transactions)
+		return err
+	})
+	return transactions, err
+}
+
+// Counterparty is what an address sent to or received from one owner in one symbol
+type Counterparty struct {
+	Blockchain string
+	Address    string
+	Sent       bool // false if received
+	Owner      string
+	OwnerType  string
+	Symbol     string
+	Count      int
+	AmountUsd  float64
+}
+
+// sources of wallet labels
+const (
+	LabelWhaleAlert = "whale_alert" // the owner whale alert labeled the address with
+	LabelManual     = "manual"      // configured or added to wallet_labels by hand
+	LabelList       = "list"        // imported from a csv list
+	LabelENS        = "ens"         // the reverse ens name of an ethereum address
+	LabelHistory    = "history"     // classified from the transactions of the address in the log
+)
+
+// Label is who a source says owns an address and how far it is trusted from 0 to 1
+type Label struct {
+	Blockchain string
+	Address    string
+	Source     string
+	Owner      string
+	OwnerType  string // empty if the source only names the owner like ens
+	Confidence float64
+	Updated    int64
+}
+
+// FetchWhaleLabels is the owner the whales table remembers for each of the addresses
+// addresses it only knows as unknown are left out. the confidence is left to the caller
+func FetchWhaleLabels(ctx context.Context, db DB, addresses []string) ([]Label, error) {
+	var labels []Label
+	err := db.queryAddresses(ctx, addresses, 0, func(conn *sql.DB, params string, args []interface{}) error {
+		rows, err := conn.QueryContext(ctx, `
+			SELECT blockchain, address, COALESCE(owner, ''), owner_type
+			FROM whales
+			WHERE address IN (`+params+`) AND owner_type IS NOT NULL AND owner_type NOT IN ('', 'unknown');
+		`, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			label := Label{Source: LabelWhaleAlert}
+			err = rows.Scan(&label.Blockchain, &label.Address, &label.Owner, &label.OwnerType)
+			if err != nil {
+				return err
+			}
+			labels = append(labels, label)
+		}
+		return rows.Err()
+	})
+	return labels, err
+}
+
+// FetchWalletLabels is every label of the addresses in wallet_labels, most confident first
+func FetchWalletLabels(ctx context.Context, db DB, addresses []string) ([]Label, error) {
+	lower := make([]string, len(addresses))
+	for i, address := range addresses {
+		lower[i] = strings.ToLower(address)
+	}
+	var labels []Label
+	err := db.queryAddresses(ctx, lower, 0, func(conn *sql.DB, params string, args []interface{}) error {
+		rows, err := conn.QueryContext(ctx, `
+			SELECT blockchain, address, source, COALESCE(owner, ''), COALESCE(owner_type, ''), confidence, `+db.epoch("updated_at")+`
+			FROM wallet_labels
+			WHERE address IN (`+params+`)
+			ORDER BY confidence DESC;
+		`, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var label Label
+			err = rows.Scan(&label.Blockchain, &label.Address, &label.Source, &label.Owner, &label.OwnerType, &label.Confidence, &label.Updated)
+			if err != nil {
+				return err
+			}
+			labels = append(labels, label)
+		}
+		return rows.Err()
+	})
+	return labels, err
+}
+
+// LogWalletLabels remembers the labels with when they were last applied
+// a source labeling the same address again replaces what it said before
+func LogWalletLabels(ctx context.Context, db DB, updated int64, labels []Label) error {
+	if len(labels) < 1 {
+		return nil
+	}
+	query := `
+		INSERT INTO wallet_labels
+		(blockchain, address, source, owner, owner_type, confidence, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, ` + db.time("$7") + `)
+		ON CONFLICT (blockchain, address, source) DO UPDATE SET
+		owner = EXCLUDED.owner, owner_type = EXCLUDED.owner_type, confidence = EXCLUDED.confidence, updated_at = EXCLUDED.updated_at;
+	`
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, label := range labels {
+		_, err = conn.ExecContext(ctx, query, strings.ToLower(label.Blockchain), strings.ToLower(label.Address), label.Source,
+			nullable(label.Owner), nullable(label.OwnerType), label.Confidence, updated)
+		if err != nil {
+			return fmt.Errorf("wallet label %s: %w", label.Address, err)
+		}
+	}
+	return nil
+}
+
+// FetchCounterparties is who each of the addresses sent to and received from since, per owner and symbol
+func FetchCounterparties(ctx context.Context, db DB, since int64, addresses []string) ([]Counterparty, error) {
+	var counterparties []Counterparty
+	err := db.queryAddresses(ctx, addresses, 1, func(conn *sql.DB, params string, args []interface{}) error {
+		rows, err := conn.QueryContext(ctx, `
+			SELECT blockchain, from_address, 'sent', COALESCE(to_owner, ''), COALESCE(to_owner_type, ''), symbol, COUNT(*), SUM(amount_usd)
+			FROM whale_transactions
+			WHERE from_address IN (`+params+`) AND timestamp >= `+db.time("$1")+`
+			GROUP BY blockchain, from_address, to_owner, to_owner_type, symbol
+			UNION ALL
+			SELECT blockchain, to_address, 'received', COALESCE(from_owner, ''), COALESCE(from_owner_type, ''), symbol, COUNT(*), SUM(amount_usd)
+			FROM whale_transactions
+			WHERE to_address IN (`+params+`) AND timestamp >= `+db.time("$1")+`
+			GROUP BY blockchain, to_address, from_owner, from_owner_type, symbol;
+		`, append([]interface{}{since}, args...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var counterparty Counterparty
+			var direction string
+			err = rows.Scan(&counterparty.Blockchain, &counterparty.Address, &direction, &counterparty.Owner, &counterparty.OwnerType,
+				&counterparty.Symbol, &counterparty.Count, &counterparty.AmountUsd)
+			if err != nil {
+				return err
+			}
+			counterparty.Sent = direction == "sent"
+			counterparties = append(counterparties, counterparty)
+		}
+		return rows.Err()
+	})
+	return counterparties, err
+}
+
+// queryAddresses runs the query for the addresses or other keys a few hundred at a time
+// params are the placeholders of an IN list after the first placeholders the query uses itself
+func (db DB) queryAddresses(ctx context.Context, addresses []string, first int, query func(conn *sql.DB, params string, args []interface{}) error) error {
+	if len(addresses) < 1 {
+		return nil
+	}
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	const batch = 500
+	for start := 0; start < len(addresses); start += batch {
+		end := start + batch
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		params := make([]string, end-start)
+		args := make([]interface{}, end-start)
+		for i, address := range addresses[start:end] {
+			params[i] = fmt.Sprintf("$%d", first+i+1)
+			args[i] = address
+		}
+		err = query(conn, strings.Join(params, ", "), args)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package summary
+
+import "strings"
+
+// Message is the analysis of a job as titled sections of lines
+// so renderers other than the text of a chat message can lay it out without parsing it
+type Message struct {
+	Sections []Section `json:"sections"`
+}
+
+// Section is a group of lines under one title like the exchange inflows of a window
+type Section struct {
+	Name  string `json:"name"`  //what the lines are about like transfers, bridges, or pace
+	Title string `json:"title"` //like Exchange Inflow:
+	Lines []Line `json:"lines"`
+}
+
+// Line is one row of a section
+// text is already in the markup of the recipient while the other fields are the values it shows
+type Line struct {
+	Text    string  `json:"text"`
+	Indent  int     `json:"indent"`            //spaces before the text in a chat message
+	Group   string  `json:"group,omitempty"`   //asset class, chain, exchange, or entity of the line
+	Symbol  string  `json:"symbol,omitempty"`  //lowercase
+	Value   float64 `json:"value,omitempty"`   //usd. negative for outflows and decreases
+	Verdict string  `json:"verdict,omitempty"` //how the value reads like bull, bear, or accelerating
+	Score   float64 `json:"score,omitempty"`   //how bullish every flow of the symbol reads together from -100 to 100
+}
+
+// Empty is whether there is nothing to report
+func (m Message) Empty() bool {
+	return len(m.Sections) < 1
+}
+
+// Text is the message as lines of a chat message with every title followed by its indented lines
+func (m Message) Text() string {
+	var lines []string
+	for _, section := range m.Sections {
+		if section.Title != "" {
+			lines = append(lines, section.Title)
+		}
+		for _, line := range section.Lines {
+			lines = append(lines, strings.Repeat(" ", line.Indent)+line.Text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
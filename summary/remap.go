@@ -0,0 +1,101 @@
+package summary
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// wrappedSymbols are wrapped and bridged variants whale alert reports under their own symbol
+var wrappedSymbols = map[string]string{
+	"weth":    "eth",
+	"wbtc":    "btc",
+	"btcb":    "btc",
+	"btc.b":   "btc",
+	"wbnb":    "bnb",
+	"wavax":   "avax",
+	"wsol":    "sol",
+	"wtrx":    "trx",
+	"wmatic":  "matic",
+	"usdc.e":  "usdc",
+	"usdt.e":  "usdt",
+	"usdbc":   "usdc",
+	"bsc-usd": "usdt",
+}
+
+// variant prefixes and suffixes of bridges and wrappers like axlusdc, eth.e, and btcb
+var (
+	wrappedPrefixes = []string{"w", "axl"}
+	wrappedSuffixes = []string{".e", ".b", "b", "e"}
+)
+
+// RemapSuggestion is a symbol that looks like a variant of another with the usd value it moved
+type RemapSuggestion struct {
+	Symbol    string
+	Remap     string
+	AmountUsd float64
+}
+
+// SuggestRemaps proposes remaps for symbols of the transactions that look like wrapped or bridged variants
+// ones in the table are always proposed while a pattern only counts if what it strips is a symbol
+// of the transactions or in known so unrelated tickers that happen to start with w aren't
+// symbols already in the tickermap, remapped to, or ignored are left alone. largest value first
+func SuggestRemaps(transactions []whalealert.Transaction, tickermap map[string]string, known, ignored []string) []RemapSuggestion {
+	skip := map[string]bool{}
+	for _, symbol := range ignored {
+		skip[strings.ToLower(symbol)] = true
+	}
+	bases := map[string]bool{}
+	for _, symbol := range known {
+		bases[strings.ToLower(symbol)] = true
+	}
+	for symbol, target := range tickermap {
+		skip[strings.ToLower(symbol)] = true
+		bases[strings.ToLower(target)] = true
+	}
+	values := map[string]float64{}
+	for _, transaction := range transactions {
+		symbol := strings.ToLower(transaction.Symbol)
+		values[symbol] += transaction.AmountUsd
+		bases[symbol] = true
+	}
+	var suggestions []RemapSuggestion
+	for symbol, value := range values {
+		if skip[symbol] {
+			continue
+		}
+		remap, ok := wrappedSymbols[symbol]
+		if !ok {
+			remap, ok = strippedVariant(symbol, bases)
+		}
+		if !ok || remap == symbol {
+			continue
+		}
+		suggestions = append(suggestions, RemapSuggestion{Symbol: symbol, Remap: remap, AmountUsd: value})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].AmountUsd == suggestions[j].AmountUsd {
+			return suggestions[i].Symbol < suggestions[j].Symbol
+		}
+		return suggestions[i].AmountUsd > suggestions[j].AmountUsd
+	})
+	return suggestions
+}
+
+// strippedVariant is the known symbol left after removing a variant prefix or suffix
+func strippedVariant(symbol string, bases map[string]bool) (string, bool) {
+	for _, prefix := range wrappedPrefixes {
+		base := strings.TrimPrefix(symbol, prefix)
+		if base != symbol && len(base) > 1 && bases[base] {
+			return base, true
+		}
+	}
+	for _, suffix := range wrappedSuffixes {
+		base := strings.TrimSuffix(symbol, suffix)
+		if base != symbol && len(base) > 1 && bases[base] {
+			return base, true
+		}
+	}
+	return "", false
+}
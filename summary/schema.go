@@ -0,0 +1,130 @@
+package summary
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SchemaVersion is bumped whenever Payload changes in a way consumers would notice
+const SchemaVersion = 1
+
+// Schema is the json schema of Payload
+//
+//go:embed schema.v1.json
+var Schema []byte
+
+// Payload is the structured summary shared by every json output
+type Payload struct {
+	Version  int                           `json:"version"`
+	Start    int64                         `json:"start"`
+	End      int64                         `json:"end"`
+	Sections map[string]map[string]float64 `json:"sections"`
+	// only in the output of a run, not of stored summaries
+	Unhandled []Unhandled                  `json:"unhandled,omitempty"`
+	Verdicts  map[string]map[string]string `json:"verdicts,omitempty"` //section to symbol to how its flow reads like bull or bear
+}
+
+// NewPayload is the payload of the current schema version
+func NewPayload(start, end int64, sections map[string]map[string]float64) Payload {
+	if sections == nil {
+		sections = map[string]map[string]float64{}
+	}
+	return Payload{Version: SchemaVersion, Start: start, End: end, Sections: sections}
+}
+
+// Validate checks the payload against Schema so consumers can rely on it
+func (payload Payload) Validate() error {
+	var schema map[string]interface{}
+	err := json.Unmarshal(Schema, &schema)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	err = json.Unmarshal(body, &value)
+	if err != nil {
+		return err
+	}
+	return validate(value, schema, "$")
+}
+
+// validate supports the keywords Schema uses
+// type, const, required, properties, and additionalProperties
+func validate(value interface{}, schema map[string]interface{}, path string) error {
+	if expected, ok := schema["const"]; ok && fmt.Sprint(expected) != fmt.Sprint(value) {
+		return fmt.Errorf("%s must be %v", path, expected)
+	}
+	if expected, ok := schema["type"].(string); ok && !hasType(value, expected) {
+		return fmt.Errorf("%s must be %s", path, expected)
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	required, _ := schema["required"].([]interface{})
+	for _, key := range required {
+		if _, ok := object[fmt.Sprint(key)]; !ok {
+			return fmt.Errorf("%s.%s is required", path, key)
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	// the first error is the same every time
+	sort.Strings(keys)
+	for _, key := range keys {
+		child, ok := properties[key].(map[string]interface{})
+		if !ok {
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s.%s is not allowed", path, key)
+				}
+				continue
+			case map[string]interface{}:
+				child = additional
+			default:
+				continue
+			}
+		}
+		err := validate(object[key], child, path+"."+key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasType(value interface{}, expected string) bool {
+	switch expected {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+// Package summary nets whale alert transactions into flows per symbol
+package summary
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+// BridgeFlow is a lock on one blockchain matched with an unlock of the same asset on another
+type BridgeFlow struct {
+	Symbol string
+	From   string
+	To     string
+}
+
+// Rotation is a burn of one stable coin matched with a mint of another for the same holder
+type Rotation struct {
+	From string
+	To   string
+}
+
+// Dedupe drops transfers reported more than once
+// such as by more than one source or across overlapping pages
+func Dedupe(transactions []whalealert.Transaction) ([]whalealert.Transaction, int) {
+	seen := map[string]bool{}
+	var unique []whalealert.Transaction
+	for _, transaction := range transactions {
+		key := Key(transaction)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, transaction)
+	}
+	return unique, len(transactions) - len(unique)
+}
+
+// Key identifies a transfer across sources
+// the same hash can move several amounts between several wallets
+func Key(transaction whalealert.Transaction) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%.8f",
+		transaction.Blockchain, strings.ToLower(transaction.Hash),
+		strings.ToLower(transaction.From.Address), strings.ToLower(transaction.To.Address),
+		transaction.Amount)
+}
+
+// MatchBridges pairs locks with unlocks of the same asset and amount on a different blockchain
+// returns the matched pairs and the transactions that were not part of a pair
+func MatchBridges(transactions []whalealert.Transaction, tickermap map[string]string) (map[BridgeFlow]float64, []whalealert.Transaction) {
+	bridges := map[BridgeFlow]float64{}
+	matched := make([]bool, len(transactions))
+	for i, lock := range transactions {
+		if lock.TransactionType != whalealert.LOCK.String() {
+			continue
+		}
+		symbol := RemapSymbol(lock.Symbol, tickermap)
+		for j, unlock := range transactions {
+			if matched[j] || unlock.TransactionType != whalealert.UNLOCK.String() {
+				continue
+			}
+			if unlock.Blockchain == lock.Blockchain || unlock.Timestamp < lock.Timestamp {
+				continue
+			}
+			if RemapSymbol(unlock.Symbol, tickermap) != symbol {
+				continue
+			}
+			// bridges take a fee so amounts rarely match exactly
+			if math.Abs(lock.Amount-unlock.Amount) > lock.Amount*0.01 {
+				continue
+			}
+			matched[i] = true
+			matched[j] = true
+			bridges[BridgeFlow{Symbol: symbol, From: lock.Blockchain, To: unlock.Blockchain}] += lock.AmountUsd
+			break
+		}
+	}
+	var remaining []whalealert.Transaction
+	for i, transaction := range transactions {
+		if !matched[i] {
+			remaining = append(remaining, transaction)
+		}
+	}
+	return bridges, remaining
+}
+
+// MatchRotations pairs burns of a stable coin with mints of a comparable amount of another stable coin
+// when the burner and the minter are the same, it is a swap between issuers rather than fiat moving
+func MatchRotations(transactions []whalealert.Transaction, tickermap map[string]string, stablecoins []string) (map[Rotation]float64, []whalealert.Transaction) {
+	rotations := map[Rotation]float64{}
+	matched := make([]bool, len(transactions))
+	for i, burn := range transactions {
+		burned := RemapSymbol(burn.Symbol, tickermap)
+		if burn.TransactionType != whalealert.BURN.String() || !IsStableCoin(burned, stablecoins) {
+			continue
+		}
+		for j, mint := range transactions {
+			if matched[j] || mint.TransactionType != whalealert.MINT.String() {
+				continue
+			}
+			minted := RemapSymbol(mint.Symbol, tickermap)
+			if minted == burned || !IsStableCoin(minted, stablecoins) {
+				continue
+			}
+			if !sameHolder(burn.From, mint.To) {
+				continue
+			}
+			if math.Abs(burn.AmountUsd-mint.AmountUsd) > math.Max(burn.AmountUsd, mint.AmountUsd)*0.05 {
+				continue
+			}
+			matched[i] = true
+			matched[j] = true
+			rotations[Rotation{From: burned, To: minted}] += math.Min(burn.AmountUsd, mint.AmountUsd)
+			break
+		}
+	}
+	var remaining []whalealert.Transaction
+	for i, transaction := range transactions {
+		if !matched[i] {
+			remaining = append(remaining, transaction)
+		}
+	}
+	return rotations, remaining
+}
+
+// sameHolder compares owners when whale alert knows them and addresses otherwise
+func sameHolder(a, b whalealert.Wallet) bool {
+	if a.Owner != "" && b.Owner != "" {
+		return strings.EqualFold(a.Owner, b.Owner)
+	}
+	return a.Address != "" && strings.EqualFold(a.Address, b.Address)
+}
+
+// RemapSymbol treats wrapped and bridged tickers like the original
+func RemapSymbol(symbol string, tickermap map[string]string) string {
+	// remap symbol like pax is actually usdp
+	if value, ok := tickermap[symbol]; ok {
+		return value
+	}
+	return symbol
+}
+
+// Flows nets mints and burns, exchange transfers, and locks per symbol
+// other transaction types are returned as unhandled
+func Flows(transactions []whalealert.Transaction, tickermap map[string]string) (map[string]float64, map[string]float64, map[string]float64, []whalealert.Transaction) {
+	transfers := map[string]float64{}
+	supply := map[string]float64{}
+	locks := map[string]float64{}
+	var unhandled []whalealert.Transaction
+
+	for _, transaction := range transactions {
+		// TODO: side effect log addresses
+		symbol := RemapSymbol(transaction.Symbol, tickermap)
+		if transaction.TransactionType == whalealert.MINT.String() {
+			supply[symbol] += transaction.AmountUsd
+			continue
+		}
+		if transaction.TransactionType == whalealert.BURN.String() {
+			supply[symbol] -= transaction.AmountUsd
+			continue
+		}
+		if transaction.TransactionType == whalealert.UNLOCK.String() {
+			locks[symbol] -= transaction.AmountUsd
+		}
+		if transaction.TransactionType == whalealert.LOCK.String() {
+			locks[symbol] += transaction.AmountUsd
+		}
+		if transaction.TransactionType != whalealert.TRANSFER.String() {
+			unhandled = append(unhandled, transaction)
+			continue
+		}
+		if transaction.From.OwnerType == transaction.To.OwnerType {
+			// ignore internal
+			continue
+		}
+		if transaction.From.OwnerType == "exchange" {
+			// exchange outflow
+			transfers[symbol] -= transaction.AmountUsd
+			continue
+		}
+		if transaction.To.OwnerType == "exchange" {
+			// exchange inflow
+			transfers[symbol] += transaction.AmountUsd
+			continue
+		}
+		// everything else is ignored
+		// TODO: handle others
+	}
+	return supply, transfers, locks, unhandled
+
+}
+
+func IsStableCoin(symbol string, stablecoins []string) bool {
+	return ContainsSymbol(symbol, stablecoins)
+}
+
+func ContainsSymbol(symbol string, symbols []string) bool {
+	lowercaseSymbol := strings.ToLower(symbol)
+	for _, ticker := range symbols {
+		// is this better than strings.EqualFold(ticker, symbol)
+		if strings.ToLower(ticker) == lowercaseSymbol {
+			return true
+		}
+	}
+	return false
+}
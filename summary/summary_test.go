@@ -0,0 +1,118 @@
+package summary
+
+import (
+	"testing"
+
+	"github.com/enzosv/whalesummary/whalealert"
+)
+
+func transfer(hash, from, to string, amount float64) whalealert.Transaction {
+	return whalealert.Transaction{Blockchain: "ethereum", Symbol: "usdt", TransactionType: "transfer", Hash: hash,
+		From: whalealert.Wallet{Address: from}, To: whalealert.Wallet{Address: to}, Amount: amount, AmountUsd: amount, Timestamp: 1672531200}
+}
+
+func TestDedupe(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []whalealert.Transaction
+		unique       int
+	}{
+		{"same hash across overlapping windows", []whalealert.Transaction{
+			transfer("0xabc", "a", "b", 100), transfer("0xdef", "c", "d", 50), transfer("0xabc", "a", "b", 100),
+		}, 2},
+		{"hash and addresses differ only in case", []whalealert.Transaction{
+			transfer("0xABC", "A", "b", 100), transfer("0xabc", "a", "B", 100),
+		}, 1},
+		{"legs of one hash to different wallets", []whalealert.Transaction{
+			transfer("0xabc", "a", "b", 100), transfer("0xabc", "a", "c", 100),
+		}, 2},
+		{"legs of one hash with different amounts", []whalealert.Transaction{
+			transfer("0xabc", "a", "b", 100), transfer("0xabc", "a", "b", 25),
+		}, 2},
+		{"same hash on another blockchain", func() []whalealert.Transaction {
+			other := transfer("0xabc", "a", "b", 100)
+			other.Blockchain = "polygon"
+			return []whalealert.Transaction{transfer("0xabc", "a", "b", 100), other}
+		}(), 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unique, dropped := Dedupe(test.transactions)
+			if len(unique) != test.unique || dropped != len(test.transactions)-test.unique {
+				t.Errorf("kept %d and dropped %d, want %d kept", len(unique), dropped, test.unique)
+			}
+		})
+	}
+}
+
+// the first report of a transfer is the one kept
+func TestDedupeKeepsFirst(t *testing.T) {
+	first := transfer("0xabc", "a", "b", 100)
+	first.From.Owner = "binance"
+	unique, _ := Dedupe([]whalealert.Transaction{first, transfer("0xabc", "a", "b", 100)})
+	if len(unique) != 1 || unique[0].From.Owner != "binance" {
+		t.Errorf("kept %+v, want the first report", unique)
+	}
+}
+
+func bridgeLeg(kind whalealert.TransactionType, blockchain, symbol string, amount float64, timestamp int) whalealert.Transaction {
+	return whalealert.Transaction{Blockchain: blockchain, Symbol: symbol, TransactionType: kind.String(), Hash: blockchain,
+		Amount: amount, AmountUsd: amount, Timestamp: timestamp}
+}
+
+func TestMatchBridges(t *testing.T) {
+	remap := map[string]string{"weth": "eth"}
+	tests := []struct {
+		name         string
+		transactions []whalealert.Transaction
+		bridges      map[BridgeFlow]float64
+		remaining    int
+	}{
+		{"matched pair", []whalealert.Transaction{
+			bridgeLeg(whalealert.LOCK, "ethereum", "eth", 1000, 100),
+			bridgeLeg(whalealert.UNLOCK, "polygon", "weth", 995, 160),
+		}, map[BridgeFlow]float64{{Symbol: "eth", From: "ethereum", To: "polygon"}: 1000}, 0},
+		{"unlock before the lock", []whalealert.Transaction{
+			bridgeLeg(whalealert.LOCK, "ethereum", "eth", 1000, 100),
+			bridgeLeg(whalealert.UNLOCK, "polygon", "eth", 1000, 50),
+		}, map[BridgeFlow]float64{}, 2},
+		{"unlock on the same blockchain", []whalealert.Transaction{
+			bridgeLeg(whalealert.LOCK, "ethereum", "eth", 1000, 100),
+			bridgeLeg(whalealert.UNLOCK, "ethereum", "eth", 1000, 160),
+		}, map[BridgeFlow]float64{}, 2},
+		{"amounts more than the fee apart", []whalealert.Transaction{
+			bridgeLeg(whalealert.LOCK, "ethereum", "eth", 1000, 100),
+			bridgeLeg(whalealert.UNLOCK, "polygon", "eth", 980, 160),
+		}, map[BridgeFlow]float64{}, 2},
+		{"lock without an unlock", []whalealert.Transaction{
+			bridgeLeg(whalealert.LOCK, "ethereum", "eth", 1000, 100),
+			bridgeLeg(whalealert.TRANSFER, "polygon", "eth", 1000, 160),
+		}, map[BridgeFlow]float64{}, 2},
+		{"two unlocks for one lock pair the first", []whalealert.Transaction{
+			bridgeLeg(whalealert.LOCK, "ethereum", "eth", 1000, 100),
+			bridgeLeg(whalealert.UNLOCK, "polygon", "eth", 1000, 160),
+			bridgeLeg(whalealert.UNLOCK, "arbitrum", "eth", 1000, 170),
+		}, map[BridgeFlow]float64{{Symbol: "eth", From: "ethereum", To: "polygon"}: 1000}, 1},
+		{"two locks for one unlock", []whalealert.Transaction{
+			bridgeLeg(whalealert.LOCK, "ethereum", "eth", 1000, 100),
+			bridgeLeg(whalealert.LOCK, "bsc", "eth", 1000, 110),
+			bridgeLeg(whalealert.UNLOCK, "polygon", "eth", 1000, 160),
+		}, map[BridgeFlow]float64{{Symbol: "eth", From: "ethereum", To: "polygon"}: 1000}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bridges, remaining := MatchBridges(test.transactions, remap)
+			if len(bridges) != len(test.bridges) {
+				t.Fatalf("got bridges %v, want %v", bridges, test.bridges)
+			}
+			for flow, value := range test.bridges {
+				if bridges[flow] != value {
+					t.Errorf("%+v is %g, want %g", flow, bridges[flow], value)
+				}
+			}
+			if len(remaining) != test.remaining {
+				t.Errorf("%d remaining, want %d", len(remaining), test.remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package summary
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/enzosv/whalesummary/whalealert"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const (
+	ignoreUnhandled = "ignore"
+	logUnhandled    = "log"
+)
+
+// handledTypes can be the target of an unhandled policy
+var handledTypes = []string{whalealert.TRANSFER.String(), whalealert.MINT.String(), whalealert.BURN.String(), whalealert.LOCK.String(), whalealert.UNLOCK.String()}
+
+// Reclassify summarizes transaction types as the handled type their policy names
+func Reclassify(transactions []whalealert.Transaction, policies map[string]string) []whalealert.Transaction {
+	if len(policies) < 1 {
+		return transactions
+	}
+	reclassified := make([]whalealert.Transaction, len(transactions))
+	for i, transaction := range transactions {
+		policy := strings.ToLower(policies[transaction.TransactionType])
+		if ContainsSymbol(policy, handledTypes) {
+			transaction.TransactionType = policy
+		}
+		reclassified[i] = transaction
+	}
+	return reclassified
+}
+
+// Unhandled is every transaction of one type on one blockchain that no section took
+type Unhandled struct {
+	Type       string  `json:"type"`
+	Blockchain string  `json:"blockchain"`
+	Count      int     `json:"count"`
+	AmountUsd  float64 `json:"amount_usd"`
+}
+
+// GroupUnhandled aggregates repeats of the same type and blockchain, largest first
+// types with an ignore policy are left out
+// payloads keep this grouping of schema v1 while UnhandledLines groups by owner types for the log channel
+func GroupUnhandled(unhandled []whalealert.Transaction, policies map[string]string) []Unhandled {
+	groups := map[[2]string]*Unhandled{}
+	var keys [][2]string
+	for _, transaction := range unhandled {
+		if strings.EqualFold(policies[transaction.TransactionType], ignoreUnhandled) {
+			continue
+		}
+		key := [2]string{transaction.TransactionType, transaction.Blockchain}
+		if groups[key] == nil {
+			groups[key] = &Unhandled{Type: key[0], Blockchain: key[1]}
+			keys = append(keys, key)
+		}
+		groups[key].Count++
+		groups[key].AmountUsd += transaction.AmountUsd
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return groups[keys[i]].AmountUsd > groups[keys[j]].AmountUsd
+	})
+	grouped := make([]Unhandled, len(keys))
+	for i, key := range keys {
+		grouped[i] = *groups[key]
+	}
+	return grouped
+}
+
+// DefaultUnhandledLimit is how many groups UnhandledLines lists unless told otherwise
+const DefaultUnhandledLimit = 20
+
+// unhandledGroup is every transaction of one type between one pair of owner types
+type unhandledGroup struct {
+	Type        string
+	From        string
+	To          string
+	Count       int
+	AmountUsd   float64
+	blockchains map[string]float64
+}
+
+// UnhandledLines renders unhandled transactions grouped by type and the owner types they moved between
+// on a line like 7× 'lock' unknown → exchange on avalanche: $1,200,000, largest first
+// past limit groups the rest are summed on one line so a burst of a new type can't flood the log
+func UnhandledLines(unhandled []whalealert.Transaction, policies map[string]string, limit int) []string {
+	if limit < 1 {
+		limit = DefaultUnhandledLimit
+	}
+	ownerType := func(wallet whalealert.Wallet) string {
+		if wallet.OwnerType == "" {
+			return "unknown"
+		}
+		return strings.ToLower(wallet.OwnerType)
+	}
+	groups := map[[3]string]*unhandledGroup{}
+	var keys [][3]string
+	for _, transaction := range unhandled {
+		if strings.EqualFold(policies[transaction.TransactionType], ignoreUnhandled) {
+			continue
+		}
+		key := [3]string{transaction.TransactionType, ownerType(transaction.From), ownerType(transaction.To)}
+		if groups[key] == nil {
+			groups[key] = &unhandledGroup{Type: key[0], From: key[1], To: key[2], blockchains: map[string]float64{}}
+			keys = append(keys, key)
+		}
+		groups[key].Count++
+		groups[key].AmountUsd += transaction.AmountUsd
+		groups[key].blockchains[transaction.Blockchain] += transaction.AmountUsd
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return groups[keys[i]].AmountUsd > groups[keys[j]].AmountUsd
+	})
+	p := message.NewPrinter(language.English)
+	var lines []string
+	for i, key := range keys {
+		if i == limit {
+			var count int
+			var amount float64
+			for _, rest := range keys[limit:] {
+				count += groups[rest].Count
+				amount += groups[rest].AmountUsd
+			}
+			lines = append(lines, p.Sprintf("  and %d more groups: %d× $%.0f", len(keys)-limit, count, amount))
+			break
+		}
+		group := groups[key]
+		lines = append(lines, p.Sprintf("  %d× '%s' %s → %s on %s: $%.0f", group.Count, group.Type, group.From, group.To, largestBlockchains(group.blockchains), group.AmountUsd))
+	}
+	return lines
+}
+
+// largestBlockchains names the 3 blockchains that moved the most and how many more there are
+func largestBlockchains(values map[string]float64) string {
+	blockchains := make([]string, 0, len(values))
+	for blockchain := range values {
+		blockchains = append(blockchains, blockchain)
+	}
+	sort.Slice(blockchains, func(i, j int) bool {
+		if values[blockchains[i]] == values[blockchains[j]] {
+			return blockchains[i] < blockchains[j]
+		}
+		return values[blockchains[i]] > values[blockchains[j]]
+	})
+	if len(blockchains) <= 3 {
+		return strings.Join(blockchains, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(blockchains[:3], ", "), len(blockchains)-3)
+}
+
+// ValidUnhandled is whether a policy is ignore, log, or a handled type
+func ValidUnhandled(policy string) error {
+	policy = strings.ToLower(policy)
+	if policy == ignoreUnhandled || policy == logUnhandled || ContainsSymbol(policy, handledTypes) {
+		return nil
+	}
+	return fmt.Errorf("%q is not ignore, log, or one of %s", policy, strings.Join(handledTypes, ", "))
+}
@@ -0,0 +1,208 @@
+package whalealert
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// fetcher is the budget and rate limit shared by every page of one fetch
+type fetcher struct {
+	config Config
+	mu     sync.Mutex
+	calls  int
+	next   time.Time // earliest time of the next call under the rate limit
+}
+
+// spend counts a call including retries against the budget
+// false once the budget is spent
+func (f *fetcher) spend() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.config.Budget > 0 && f.calls >= f.config.Budget {
+		return false
+	}
+	f.calls++
+	return true
+}
+
+// wait blocks until the rate limit allows another page
+// retries only wait for their backoff
+func (f *fetcher) wait() {
+	if f.config.Rate < 1 {
+		return
+	}
+	f.mu.Lock()
+	now := time.Now()
+	if f.next.Before(now) {
+		f.next = now
+	}
+	at := f.next
+	f.next = f.next.Add(time.Minute / time.Duration(f.config.Rate))
+	f.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
+
+// Session shares one budget and rate limit across fetches of many windows like an import
+type Session struct {
+	f *fetcher
+}
+
+func NewSession(config Config) *Session {
+	return &Session{f: &fetcher{config: config}}
+}
+
+// Fetch pages through one window from start until but excluding end
+// sequentially and without resuming since the caller tracks what it fetched
+func (s *Session) Fetch(start, end int64) ([]Transaction, error) {
+	return s.f.fetchRange(start, end, "", nil, nil)
+}
+
+// Calls is how many calls were spent including retries
+func (s *Session) Calls() int {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	return s.f.calls
+}
+
+// subWindow is part of the fetched range. start inclusive and end exclusive
+type subWindow struct {
+	start int64
+	end   int64
+}
+
+// subWindows splits the range into consecutive sub-windows of SubWindow
+// or evenly among the workers if it isn't set
+func (config Config) subWindows(start, end int64) ([]subWindow, error) {
+	length := (end - start + int64(config.Workers) - 1) / int64(config.Workers)
+	if config.SubWindow != "" {
+		duration, err := time.ParseDuration(config.SubWindow)
+		if err != nil {
+			return nil, err
+		}
+		if duration < time.Second {
+			return nil, fmt.Errorf("sub_window must be at least a second: %s", config.SubWindow)
+		}
+		length = int64(duration / time.Second)
+	}
+	if length < 1 {
+		length = 1
+	}
+	var windows []subWindow
+	for s := start; s < end; s += length {
+		e := s + length
+		if e > end {
+			e = end
+		}
+		windows = append(windows, subWindow{start: s, end: e})
+	}
+	return windows, nil
+}
+
+// fetchParallel fetches sub-windows with a pool of workers and merges them in order
+// if the budget runs out, sub-windows after the cutoff are dropped so the result has no gaps
+func (f *fetcher) fetchParallel(start, end int64) ([]Transaction, error) {
+	windows, err := f.config.subWindows(start, end)
+	if err != nil {
+		return nil, err
+	}
+	progress := resumeFetch(f.config.State, start, end)
+	done := map[int64]bool{}
+	for _, s := range progress.Done {
+		done[s] = true
+	}
+	results := make([][]Transaction, len(windows))
+	errs := make([]error, len(windows))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var saving sync.Mutex
+	for w := 0; w < f.config.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				window := windows[i]
+				results[i], errs[i] = f.fetchRange(window.start, window.end, "", nil, nil)
+				if errs[i] != nil {
+					continue
+				}
+				saving.Lock()
+				progress.Done = append(progress.Done, window.start)
+				progress.Transactions = append(progress.Transactions, results[i]...)
+				err := saveFetch(f.config.State, fetchProgress{Start: start, End: end, Transactions: progress.Transactions, Done: progress.Done})
+				saving.Unlock()
+				if err != nil {
+					fmt.Println(err)
+				}
+			}
+		}()
+	}
+	for i, window := range windows {
+		if !done[window.start] {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	resumed := map[int64][]Transaction{}
+	for _, transaction := range progress.Transactions {
+		window := transactionWindow(transaction, windows)
+		if done[window] {
+			resumed[window] = append(resumed[window], transaction)
+		}
+	}
+	var transactions []Transaction
+	var fetchErr error
+	for i, window := range windows {
+		if done[window.start] {
+			transactions = append(transactions, resumed[window.start]...)
+			continue
+		}
+		transactions = append(transactions, results[i]...)
+		if errs[i] != nil && fetchErr == nil {
+			fetchErr = errs[i]
+		}
+		var truncated *BudgetError
+		if errors.As(errs[i], &truncated) {
+			// later sub-windows would leave a gap after the cutoff
+			fetchErr = errs[i]
+			break
+		}
+	}
+	if fetchErr == nil {
+		clearFetch(f.config.State)
+	}
+	return unique(transactions), fetchErr
+}
+
+// transactionWindow is the start of the sub-window the transaction belongs to
+func transactionWindow(transaction Transaction, windows []subWindow) int64 {
+	timestamp := int64(transaction.Timestamp)
+	for _, window := range windows {
+		if timestamp >= window.start && timestamp < window.end {
+			return window.start
+		}
+	}
+	return -1
+}
+
+// unique drops transactions returned by more than one sub-window by id or by hash if there is none
+func unique(transactions []Transaction) []Transaction {
+	seen := map[string]bool{}
+	var merged []Transaction
+	for _, transaction := range transactions {
+		key := "id|" + transaction.ID
+		if transaction.ID == "" {
+			key = fmt.Sprintf("hash|%s|%s|%s|%s|%.8f", transaction.Blockchain, transaction.Hash,
+				transaction.From.Address, transaction.To.Address, transaction.Amount)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, transaction)
+	}
+	return merged
+}
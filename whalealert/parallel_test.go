@@ -0,0 +1,96 @@
+package whalealert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/enzosv/whalesummary/retry"
+)
+
+// chunkServer answers each sub-window with the transactions starting in it
+// failing are sub-window starts that get a 500
+type chunkServer struct {
+	mu      sync.Mutex
+	chunks  map[int64][]Transaction
+	failing map[int64]bool
+	calls   []int64
+}
+
+func (s *chunkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+	s.mu.Lock()
+	s.calls = append(s.calls, start)
+	failing := s.failing[start]
+	s.mu.Unlock()
+	if start == 1000 {
+		// the first sub-window finishes last
+		time.Sleep(20 * time.Millisecond)
+	}
+	if failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(Response{Result: "success", Count: len(s.chunks[start]), Transactions: s.chunks[start]})
+}
+
+func TestFetchParallel(t *testing.T) {
+	tx := func(id string, timestamp int) Transaction {
+		return Transaction{ID: id, Blockchain: "ethereum", Symbol: "usdt", Timestamp: timestamp}
+	}
+	chunks := map[int64][]Transaction{
+		1000: {tx("1", 1010), tx("2", 1090)},
+		1100: {tx("3", 1150)},
+		// whale alert's inclusive end repeats a transaction at the boundary
+		1200: {tx("3", 1150), tx("4", 1250)},
+		1300: {tx("5", 1399)},
+	}
+	state := filepath.Join(t.TempDir(), "fetch_state.json")
+	server := &chunkServer{chunks: chunks, failing: map[int64]bool{1200: true}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	config := Config{URL: httpServer.URL, Limit: 100, Workers: 2, SubWindow: "100s", State: state,
+		Policy: retry.Policy{Attempts: 1, Timeout: time.Second}}
+
+	transactions, err := FetchTransactions(config, 1000, 1400)
+	if err == nil {
+		t.Fatal("expected the failing sub-window to fail the fetch")
+	}
+	if got := ids(transactions); got != "1235" {
+		t.Errorf("partial fetch returned %s, want 1235", got)
+	}
+	if _, err := os.Stat(state); err != nil {
+		t.Fatalf("progress of the partial fetch was not saved: %s", err)
+	}
+
+	server.mu.Lock()
+	server.failing, server.calls = nil, nil
+	server.mu.Unlock()
+	transactions, err = FetchTransactions(config, 1000, 1400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(transactions); got != "12345" {
+		t.Errorf("resumed fetch returned %s in order and deduped, want 12345", got)
+	}
+	if len(server.calls) != 1 || server.calls[0] != 1200 {
+		t.Errorf("resumed fetch called whale alert for %v, want only the failed sub-window 1200", server.calls)
+	}
+	if _, err := os.Stat(state); !os.IsNotExist(err) {
+		t.Errorf("progress kept after a complete fetch: %v", err)
+	}
+}
+
+func ids(transactions []Transaction) string {
+	var joined string
+	for _, transaction := range transactions {
+		joined += transaction.ID
+	}
+	return joined
+}
@@ -0,0 +1,65 @@
+package whalealert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// fetchProgress is what an interrupted fetch needs to continue
+type fetchProgress struct {
+	Start        int64         `json:"start"`
+	End          int64         `json:"end"`
+	Cursor       string        `json:"cursor"`
+	Transactions []Transaction `json:"transactions"`
+	// starts of sub-windows a parallel fetch completed. their transactions are in Transactions
+	Done []int64 `json:"done,omitempty"`
+}
+
+// resumeFetch returns the pages already fetched for the same window
+// progress of any other window is stale and ignored
+func resumeFetch(path string, start, end int64) fetchProgress {
+	if path == "" {
+		return fetchProgress{}
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fetchProgress{}
+	}
+	var progress fetchProgress
+	err = json.Unmarshal(body, &progress)
+	if err != nil || progress.Start != start || progress.End != end {
+		return fetchProgress{}
+	}
+	if len(progress.Done) > 0 {
+		fmt.Printf("resuming fetch with %d transactions of %d sub-windows\n", len(progress.Transactions), len(progress.Done))
+	} else {
+		fmt.Printf("resuming fetch with %d transactions from cursor %s\n", len(progress.Transactions), progress.Cursor)
+	}
+	return progress
+}
+
+// saveFetch replaces the file atomically so a crash never leaves it half written
+func saveFetch(path string, progress fetchProgress) error {
+	if path == "" {
+		return nil
+	}
+	body, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, body, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func clearFetch(path string) {
+	if path == "" {
+		return
+	}
+	os.Remove(path)
+}
@@ -0,0 +1,131 @@
+package whalealert
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const WHALESTREAMURL = "wss://leviathan.whale-alert.io/ws"
+
+// StreamConfig is the websocket feed of real time alerts
+type StreamConfig struct {
+	URL       string `json:"url"`        //defaults to WHALESTREAMURL
+	Flush     string `json:"flush"`      //how often buffered transactions are summarized. defaults to 1m
+	Buffer    int    `json:"buffer"`     //transactions received but not yet classified. defaults to 10000
+	Overflow  string `json:"overflow"`   //drop_newest, drop_oldest, or block the websocket when the buffer is full. defaults to drop_newest
+	MaxWindow int    `json:"max_window"` //most transactions summarized per flush. the smallest are dropped past it. defaults to 50000
+}
+
+func (config StreamConfig) baseURL() string {
+	if config.URL != "" {
+		return config.URL
+	}
+	return WHALESTREAMURL
+}
+
+// Alert is a real time alert of the whale alert websocket
+// amounts has one entry per symbol moved by the transaction
+type Alert struct {
+	Timestamp       int    `json:"timestamp"`
+	Blockchain      string `json:"blockchain"`
+	TransactionType string `json:"transaction_type"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Amounts         []struct {
+		Symbol   string  `json:"symbol"`
+		Amount   float64 `json:"amount"`
+		ValueUSD float64 `json:"value_usd"`
+	} `json:"amounts"`
+	Transaction struct {
+		Hash            string `json:"hash"`
+		SubTransactions []struct {
+			Symbol  string   `json:"symbol"`
+			Inputs  []Wallet `json:"inputs"`
+			Outputs []Wallet `json:"outputs"`
+		} `json:"sub_transactions"`
+	} `json:"transaction"`
+}
+
+// Transactions converts the alert to the shape of the rest api
+// wallets come from the matching sub transaction with the alert's owner names as fallback
+func (alert Alert) Transactions() []Transaction {
+	var transactions []Transaction
+	for _, amount := range alert.Amounts {
+		from := streamWallet(alert.From)
+		to := streamWallet(alert.To)
+		for _, sub := range alert.Transaction.SubTransactions {
+			if !strings.EqualFold(sub.Symbol, amount.Symbol) {
+				continue
+			}
+			if len(sub.Inputs) > 0 {
+				from = sub.Inputs[0]
+			}
+			if len(sub.Outputs) > 0 {
+				to = sub.Outputs[0]
+			}
+			break
+		}
+		transactions = append(transactions, Transaction{
+			Blockchain:       alert.Blockchain,
+			Symbol:           strings.ToLower(amount.Symbol),
+			TransactionType:  alert.TransactionType,
+			Hash:             alert.Transaction.Hash,
+			From:             from,
+			To:               to,
+			Timestamp:        alert.Timestamp,
+			Amount:           amount.Amount,
+			AmountUsd:        amount.ValueUSD,
+			TransactionCount: 1,
+		})
+	}
+	return transactions
+}
+
+func streamWallet(owner string) Wallet {
+	if owner == "" || strings.EqualFold(owner, "unknown wallet") {
+		return Wallet{OwnerType: "unknown"}
+	}
+	return Wallet{Owner: owner}
+}
+
+// Subscribe hands the transactions of every alert to handle until the connection fails
+// connected is whether the subscription was accepted before failing
+func Subscribe(ctx context.Context, config Config, handle func([]Transaction)) (bool, error) {
+	base, err := url.Parse(config.Stream.baseURL())
+	if err != nil {
+		return false, err
+	}
+	params := base.Query()
+	params.Set("api_key", config.APIKey)
+	base.RawQuery = params.Encode()
+	dialCtx, cancel := context.WithTimeout(ctx, config.Policy.Timeout)
+	conn, _, err := websocket.DefaultDialer.DialContext(dialCtx, base.String(), nil)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	min, _ := strconv.ParseFloat(config.Min, 64)
+	err = conn.WriteJSON(map[string]interface{}{
+		"type":          "subscribe_alerts",
+		"id":            "whalesummary",
+		"min_value_usd": min,
+		"tx_types":      []string{"transfer", "mint", "burn", "lock", "unlock"},
+	})
+	if err != nil {
+		return false, err
+	}
+	for {
+		var alert Alert
+		err = conn.ReadJSON(&alert)
+		if err != nil {
+			return true, err
+		}
+		// confirmations and other messages have no amounts
+		handle(alert.Transactions())
+	}
+}
@@ -0,0 +1,227 @@
+// Package whalealert fetches large transactions from the whale alert api
+package whalealert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/enzosv/whalesummary/retry"
+)
+
+// Response is a page of the transactions endpoint
+type Response struct {
+	Result       string        `json:"result"`
+	Message      string        `json:"message"`
+	Cursor       string        `json:"cursor"`
+	Count        int           `json:"count"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// Transaction is a single movement of one symbol between two wallets
+type Transaction struct {
+	Blockchain       string  `json:"blockchain"`
+	Symbol           string  `json:"symbol"`
+	ID               string  `json:"id"`
+	TransactionType  string  `json:"transaction_type"`
+	Hash             string  `json:"hash"`
+	From             Wallet  `json:"from"`
+	To               Wallet  `json:"to"`
+	Timestamp        int     `json:"timestamp"`
+	Amount           float64 `json:"amount"`
+	AmountUsd        float64 `json:"amount_usd"`
+	TransactionCount int     `json:"transaction_count"`
+	// set by sources that report on chain base units instead of whole coins
+	Contract  string `json:"contract,omitempty"`
+	RawAmount string `json:"raw_amount,omitempty"`
+}
+
+// Wallet is an address and whoever whale alert attributes it to
+type Wallet struct {
+	Address   string `json:"address"`
+	Owner     string `json:"owner"`
+	OwnerType string `json:"owner_type"`
+}
+
+// Config is how to call whale alert
+type Config struct {
+	APIKey string       `json:"api_key"`
+	Min    string       `json:"min"`   //minimum usd value of transaction
+	Limit  int          `json:"limit"` //page limit
+	Retry  retry.Config `json:"retry"`
+	Budget int          `json:"budget"` //max api calls per run including retries. 0 for unlimited
+	URL    string       `json:"url"`    //defaults to WHALEURL
+	Stream StreamConfig `json:"stream"`
+	State  string       `json:"state"` //file to resume an interrupted fetch of the same window from. disabled if empty
+	// sub-windows fetched at the same time. defaults to 1 for a single sequential fetch
+	Workers   int          `json:"workers"`
+	SubWindow string       `json:"sub_window"` //length of each sub-window like 10m. defaults to the window split evenly among workers
+	Rate      int          `json:"rate"`       //max calls per minute across workers. 0 for unlimited
+	History   string       `json:"history"`    //how far back the plan can fetch like 720h. import stops there. defaults to 30 days
+	Policy    retry.Policy `json:"-"`          //resolved from Retry by the caller
+}
+
+// BudgetError is returned with the transactions fetched before the api call budget ran out
+type BudgetError struct {
+	Budget int
+	Cutoff int64 // timestamp of the latest fetched transaction
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("stopped after %d whale alert calls. transactions after %s were not fetched",
+		e.Budget, time.Unix(e.Cutoff, 0).Format("Jan 2 3:04:05PM"))
+}
+
+// StatusError is an unsuccessful response from whale alert
+type StatusError struct {
+	Status string
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	body := e.Body
+	if len(body) > 200 {
+		body = body[:200] + "…"
+	}
+	return fmt.Sprintf("whale alert returned %s: %s", e.Status, body)
+}
+
+// TransactionType is what whale alert calls a movement
+type TransactionType int
+
+const (
+	MINT TransactionType = iota
+	BURN
+	TRANSFER
+	LOCK
+	UNLOCK
+)
+
+func (t TransactionType) String() string {
+	return [...]string{"mint", "burn", "transfer", "lock", "unlock"}[t]
+}
+
+const WHALEURL = "https://api.whale-alert.io/v1/transactions"
+
+func (config Config) baseURL() string {
+	if config.URL != "" {
+		return config.URL
+	}
+	return WHALEURL
+}
+
+// FetchTransactions pages through whale alert from start until but excluding end
+// with more than one worker, sub-windows of the range are fetched concurrently
+func FetchTransactions(config Config, start, end int64) ([]Transaction, error) {
+	f := &fetcher{config: config}
+	if config.Workers > 1 {
+		return f.fetchParallel(start, end)
+	}
+	progress := resumeFetch(config.State, start, end)
+	transactions, err := f.fetchRange(start, end, progress.Cursor, progress.Transactions, func(transactions []Transaction, cursor string) {
+		err := saveFetch(config.State, fetchProgress{Start: start, End: end, Cursor: cursor, Transactions: transactions})
+		if err != nil {
+			fmt.Println(err)
+		}
+	})
+	if err == nil {
+		clearFetch(config.State)
+	}
+	return transactions, err
+}
+
+// fetchRange pages through one range from cursor and appends to the transactions already fetched
+// paged is called after every page that has a next one
+func (f *fetcher) fetchRange(start, end int64, cursor string, transactions []Transaction, paged func([]Transaction, string)) ([]Transaction, error) {
+	for {
+		f.wait()
+		var response Response
+		err := f.config.Policy.Do(context.Background(), func(ctx context.Context) error {
+			if !f.spend() {
+				return retry.Permanent(&BudgetError{Budget: f.config.Budget, Cutoff: latest(transactions, start)})
+			}
+			var err error
+			response, err = fetchPage(ctx, f.config, cursor, start, end)
+			return err
+		})
+		if err != nil {
+			return transactions, err
+		}
+		transactions = append(transactions, response.Transactions...)
+		if response.Count < f.config.Limit {
+			return transactions, nil
+		}
+		// for pagination
+		cursor = response.Cursor
+		if paged != nil {
+			paged(transactions, cursor)
+		}
+	}
+}
+
+// latest is the timestamp of the newest transaction or start if there are none
+func latest(transactions []Transaction, start int64) int64 {
+	cutoff := start
+	for _, transaction := range transactions {
+		if int64(transaction.Timestamp) > cutoff {
+			cutoff = int64(transaction.Timestamp)
+		}
+	}
+	return cutoff
+}
+
+func fetchPage(ctx context.Context, config Config, cursor string, start, end int64) (Response, error) {
+	var response Response
+	base, err := url.Parse(config.baseURL())
+	if err != nil {
+		return response, err
+	}
+	params := url.Values{}
+	params.Add("api_key", config.APIKey)
+	params.Add("min_value", config.Min)
+	params.Add("start", fmt.Sprintf("%d", start))
+	// minus one second because whale alert end is inclusive
+	params.Add("end", fmt.Sprintf("%d", end-1))
+	params.Add("limit", strconv.Itoa(config.Limit))
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	base.RawQuery = params.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", base.String(), nil)
+	if err != nil {
+		return response, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return response, err
+	}
+	if res.StatusCode != http.StatusOK {
+		err = &StatusError{Status: res.Status, Body: string(body)}
+		switch {
+		case res.StatusCode == http.StatusTooManyRequests:
+			return response, retry.After(err, retry.RetryAfter(res.Header))
+		case res.StatusCode >= 500:
+			return response, err
+		}
+		// the same request won't succeed by trying again
+		return response, retry.Permanent(err)
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return response, err
+	}
+	if response.Result != "success" {
+		return response, fmt.Errorf(response.Message)
+	}
+	return response, nil
+}
@@ -0,0 +1,28 @@
+package whalealert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/enzosv/whalesummary/retry"
+)
+
+// the window end is exclusive and whale alert's is inclusive
+func TestFetchTransactionsEndsBeforeWindowEnd(t *testing.T) {
+	var start, end string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end = r.URL.Query().Get("start"), r.URL.Query().Get("end")
+		w.Write([]byte(`{"result": "success", "count": 0}`))
+	}))
+	defer server.Close()
+
+	_, err := FetchTransactions(Config{URL: server.URL, Limit: 100, Policy: retry.Policy{Attempts: 1, Timeout: time.Second}}, 1672531200, 1672534080)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != "1672531200" || end != "1672534079" {
+		t.Errorf("requested start %s and end %s, want 1672531200 and 1672534079", start, end)
+	}
+}